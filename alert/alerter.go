@@ -0,0 +1,205 @@
+package alert
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+)
+
+// Evaluates alert rules against every snapshot and sends
+// new alerts to webhooks. A route is alerted once per rule
+// until it is missing from snapshots for the grace period.
+type Alerter struct {
+	rules    []model.AlertRule
+	webhooks []*webhook
+	client   *http.Client
+
+	retries  int
+	backoff  time.Duration
+	interval time.Duration
+	grace    time.Duration
+
+	mu   sync.Mutex
+	sent map[string]*sentRoute // by cycle key
+}
+
+// Alerted rules of a route and the last snapshot time it was in
+type sentRoute struct {
+	rules map[string]bool
+	seen  time.Time
+}
+
+// Rule names must be unique
+func NewAlerter(client *http.Client, urls []string, rules ...model.AlertRule) *Alerter {
+
+	webhooks := make([]*webhook, 0, len(urls))
+	for _, url := range urls {
+		webhooks = append(webhooks, newWebhook(url))
+	}
+
+	return &Alerter{
+		rules:    rules,
+		webhooks: webhooks,
+		client:   client,
+		retries:  3,
+		backoff:  time.Second,
+		interval: 10 * time.Second,
+		grace:    30 * time.Second,
+		sent:     map[string]*sentRoute{},
+	}
+
+}
+
+// Set delivery attempts after the failed one and delay before the first of them,
+// the delay is doubled on every next attempt
+func (a *Alerter) SetRetry(retries int, backoff time.Duration) {
+	a.retries = retries
+	a.backoff = backoff
+}
+
+// Set min time between requests to a webhook, alerts found meanwhile are sent together
+func (a *Alerter) SetInterval(interval time.Duration) {
+	a.interval = interval
+}
+
+// Set time a missing route is remembered for, it is alerted again
+// when it appears later. 0 - forgotten after one missing snapshot.
+func (a *Alerter) SetGrace(grace time.Duration) {
+	a.grace = grace
+}
+
+// Alert on every snapshot of notifier until its updates are closed or ctx is done.
+// Alerts waiting for delivery are sent before return unless ctx is done.
+func (a *Alerter) Run(ctx context.Context, notifier service.Notifier) {
+
+	done := make(chan struct{})
+
+	var wg sync.WaitGroup
+	for _, w := range a.webhooks {
+		wg.Add(1)
+		go func(w *webhook) {
+			defer wg.Done()
+			a.deliver(ctx, w, done)
+		}(w)
+	}
+	defer wg.Wait()
+	defer close(done)
+
+	updates, unsubscribe := notifier.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+
+		case <-ctx.Done():
+			return
+
+		case snapshot, ok := <-updates:
+			if !ok {
+				return
+			}
+
+			alerts := a.Evaluate(snapshot)
+			if len(alerts) == 0 {
+				continue
+			}
+
+			for _, w := range a.webhooks {
+				w.add(alerts)
+			}
+
+		}
+	}
+
+}
+
+// New alerts of the snapshot
+func (a *Alerter) Evaluate(snapshot *model.Snapshot) (alerts []model.Alert) {
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, arbitrage := range snapshot.Arbitrage {
+
+		key := service.CycleKey(arbitrage.Route)
+
+		route, ok := a.sent[key]
+		if !ok {
+			route = &sentRoute{rules: map[string]bool{}}
+			a.sent[key] = route
+		}
+		route.seen = snapshot.Time
+
+		for _, rule := range a.rules {
+
+			if route.rules[rule.Name] || !matchRule(rule, arbitrage) {
+				continue
+			}
+			route.rules[rule.Name] = true
+
+			alert := model.Alert{
+				Rule:      rule.Name,
+				Time:      snapshot.Time,
+				Route:     arbitrage.Route,
+				Profit:    arbitrage.Profit,
+				NetProfit: arbitrage.NetProfit,
+				Volume:    arbitrage.Volume,
+			}
+			if arbitrage.Lifetime != nil {
+				firstSeen := arbitrage.Lifetime.FirstSeen
+				alert.FirstSeen = &firstSeen
+			}
+
+			alerts = append(alerts, alert)
+
+		}
+
+	}
+
+	// Routes missing longer than grace are alerted again when they appear
+	for key, route := range a.sent {
+		if route.seen.Before(snapshot.Time) && snapshot.Time.Sub(route.seen) >= a.grace {
+			delete(a.sent, key)
+		}
+	}
+
+	return
+
+}
+
+func matchRule(rule model.AlertRule, arbitrage model.Arbitrage) bool {
+
+	if (arbitrage.NetProfit-1)*100 < rule.MinProfit {
+		return false
+	}
+
+	if arbitrage.Volume < rule.MinVolume {
+		return false
+	}
+
+	if len(rule.Currencies) > 0 && !throughAny(arbitrage.Route, rule.Currencies) {
+		return false
+	}
+
+	// Not tracked route has no lifetime
+	if rule.Persistence > 0 && (arbitrage.Lifetime == nil || arbitrage.Lifetime.Duration() < rule.Persistence) {
+		return false
+	}
+
+	return true
+
+}
+
+func throughAny(route []model.Currency, currencies []model.Currency) bool {
+	for _, currency := range route {
+		for _, item := range currencies {
+			if currency == item {
+				return true
+			}
+		}
+	}
+	return false
+}
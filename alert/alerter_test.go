@@ -0,0 +1,204 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+type testNotifier struct {
+	updates chan *model.Snapshot
+}
+
+func (n *testNotifier) Subscribe() (<-chan *model.Snapshot, func()) {
+	return n.updates, func() {}
+}
+
+var (
+	testStart = time.Date(2019, 2, 1, 10, 0, 0, 0, time.UTC)
+	testBTC   = []model.Currency{"USD", "BTC", "USD"}
+	testETH   = []model.Currency{"USD", "ETH", "BTC", "USD"}
+)
+
+func testArbitrage(route []model.Currency, netProfit, volume float64, seen time.Duration) model.Arbitrage {
+	return model.Arbitrage{
+		Route:     route,
+		Profit:    netProfit + 0.004,
+		NetProfit: netProfit,
+		Volume:    volume,
+		Lifetime: &model.Lifetime{
+			FirstSeen: testStart,
+			LastSeen:  testStart.Add(seen),
+		},
+	}
+}
+
+func TestAlerter_Evaluate(t *testing.T) {
+
+	alerter := NewAlerter(http.DefaultClient, nil,
+		model.AlertRule{Name: "profit", MinProfit: 0.5, MinVolume: 10},
+		model.AlertRule{Name: "eth", Currencies: []model.Currency{"ETH"}, Persistence: 5 * time.Second},
+	)
+	alerter.SetGrace(10 * time.Second)
+
+	alerts := alerter.Evaluate(&model.Snapshot{
+		Time: testStart,
+		Arbitrage: []model.Arbitrage{
+			testArbitrage(testBTC, 1.01, 100, 0),
+			testArbitrage(testETH, 1.001, 5, 0),
+		},
+	})
+	if assert.Len(t, alerts, 1) {
+		assert.Equal(t, "profit", alerts[0].Rule)
+		assert.Equal(t, testBTC, alerts[0].Route)
+		if assert.NotNil(t, alerts[0].FirstSeen) {
+			assert.Equal(t, testStart, *alerts[0].FirstSeen)
+		}
+	}
+
+	// Already alerted route from another start currency, eth route persisted
+	alerts = alerter.Evaluate(&model.Snapshot{
+		Time: testStart.Add(5 * time.Second),
+		Arbitrage: []model.Arbitrage{
+			testArbitrage([]model.Currency{"BTC", "USD", "BTC"}, 1.02, 100, 5*time.Second),
+			testArbitrage(testETH, 1.001, 5, 5*time.Second),
+		},
+	})
+	if assert.Len(t, alerts, 1) {
+		assert.Equal(t, "eth", alerts[0].Rule)
+	}
+
+	// Route missing for less than grace is not alerted again
+	alerts = alerter.Evaluate(&model.Snapshot{Time: testStart.Add(10 * time.Second)})
+	assert.Empty(t, alerts)
+
+	alerts = alerter.Evaluate(&model.Snapshot{
+		Time:      testStart.Add(12 * time.Second),
+		Arbitrage: []model.Arbitrage{testArbitrage(testBTC, 1.01, 100, 0)},
+	})
+	assert.Empty(t, alerts)
+
+	// Forgotten after grace, alerted again
+	alerts = alerter.Evaluate(&model.Snapshot{Time: testStart.Add(22 * time.Second)})
+	assert.Empty(t, alerts)
+
+	untracked := testArbitrage(testBTC, 1.01, 100, 0)
+	untracked.Lifetime = nil
+
+	alerts = alerter.Evaluate(&model.Snapshot{
+		Time:      testStart.Add(25 * time.Second),
+		Arbitrage: []model.Arbitrage{untracked},
+	})
+	if assert.Len(t, alerts, 1) {
+		assert.Nil(t, alerts[0].FirstSeen)
+	}
+
+}
+
+func TestAlerter_EvaluateNoGrace(t *testing.T) {
+
+	alerter := NewAlerter(http.DefaultClient, nil, model.AlertRule{Name: "profit"})
+	alerter.SetGrace(0)
+
+	snapshot := &model.Snapshot{
+		Time:      testStart,
+		Arbitrage: []model.Arbitrage{testArbitrage(testBTC, 1.01, 100, 0)},
+	}
+	assert.Len(t, alerter.Evaluate(snapshot), 1)
+	assert.Empty(t, alerter.Evaluate(&model.Snapshot{Time: testStart.Add(time.Second)}))
+
+	snapshot.Time = testStart.Add(2 * time.Second)
+	assert.Len(t, alerter.Evaluate(snapshot), 1)
+
+}
+
+func TestAlerter_Run(t *testing.T) {
+
+	var mu sync.Mutex
+	var payloads []Payload
+	requests := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		// First request fails
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		var payload Payload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		payloads = append(payloads, payload)
+
+	}))
+	defer server.Close()
+
+	notifier := &testNotifier{updates: make(chan *model.Snapshot, 3)}
+	notifier.updates <- &model.Snapshot{
+		Time:      testStart,
+		Arbitrage: []model.Arbitrage{testArbitrage(testBTC, 1.01, 100, 0)},
+	}
+	notifier.updates <- &model.Snapshot{
+		Time:      testStart.Add(time.Second),
+		Arbitrage: []model.Arbitrage{testArbitrage(testBTC, 1.01, 100, time.Second)},
+	}
+	notifier.updates <- &model.Snapshot{
+		Time: testStart.Add(2 * time.Second),
+		Arbitrage: []model.Arbitrage{
+			testArbitrage(testBTC, 1.01, 100, 2*time.Second),
+			testArbitrage(testETH, 1.02, 100, 0),
+		},
+	}
+	close(notifier.updates)
+
+	alerter := NewAlerter(server.Client(), []string{server.URL}, model.AlertRule{Name: "default"})
+	alerter.SetRetry(1, time.Millisecond)
+	alerter.SetInterval(10 * time.Millisecond)
+	alerter.Run(context.Background(), notifier)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Each route once, in one or two requests after the retried one
+	var routes [][]model.Currency
+	for _, payload := range payloads {
+		for _, alert := range payload.Alerts {
+			routes = append(routes, alert.Route)
+		}
+	}
+	assert.Equal(t, [][]model.Currency{testBTC, testETH}, routes)
+	assert.True(t, requests >= 2 && requests <= 3, requests)
+
+}
+
+func TestAlerter_send(t *testing.T) {
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	alerter := NewAlerter(server.Client(), nil)
+	alerter.SetRetry(3, time.Millisecond)
+
+	// Client errors are not retried
+	err := alerter.send(context.Background(), server.URL, []model.Alert{{Rule: "default"}})
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, requests)
+
+}
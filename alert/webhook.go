@@ -0,0 +1,166 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Max alerts waiting for delivery to a webhook, the oldest are dropped
+const maxPending = 1000
+
+// Webhook request body
+type Payload struct {
+	Time   time.Time     `json:"time"`
+	Alerts []model.Alert `json:"alerts"`
+}
+
+// Webhook url with alerts waiting for delivery
+type webhook struct {
+	url  string
+	wake chan struct{}
+
+	mu      sync.Mutex
+	pending []model.Alert
+}
+
+func newWebhook(url string) *webhook {
+	return &webhook{
+		url:  url,
+		wake: make(chan struct{}, 1),
+	}
+}
+
+func (w *webhook) add(alerts []model.Alert) {
+
+	w.mu.Lock()
+	w.pending = append(w.pending, alerts...)
+	if len(w.pending) > maxPending {
+		w.pending = w.pending[len(w.pending)-maxPending:]
+	}
+	w.mu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+
+}
+
+func (w *webhook) take() (alerts []model.Alert) {
+
+	w.mu.Lock()
+	alerts, w.pending = w.pending, nil
+	w.mu.Unlock()
+
+	return
+
+}
+
+// Send pending alerts not more often than interval until done or ctx is done
+func (a *Alerter) deliver(ctx context.Context, w *webhook, done <-chan struct{}) {
+
+	for {
+
+		stop := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			stop = true
+		case <-w.wake:
+		}
+
+		if alerts := w.take(); len(alerts) > 0 {
+
+			if err := a.send(ctx, w.url, alerts); err != nil {
+				log.Printf("Alert webhook %s error: %v\n", w.url, err)
+			}
+
+			// Rate limit
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(a.interval):
+			}
+
+		}
+
+		if stop {
+			// Alerts added while the last request was sent
+			if alerts := w.take(); len(alerts) > 0 {
+				if err := a.send(ctx, w.url, alerts); err != nil {
+					log.Printf("Alert webhook %s error: %v\n", w.url, err)
+				}
+			}
+			return
+		}
+
+	}
+
+}
+
+// Post alerts, retry on network errors, 429 and 5xx responses
+func (a *Alerter) send(ctx context.Context, url string, alerts []model.Alert) error {
+
+	body, err := json.Marshal(Payload{
+		Time:   time.Now(),
+		Alerts: alerts,
+	})
+	if err != nil {
+		return err
+	}
+
+	backoff := a.backoff
+	for attempt := 0; ; attempt++ {
+
+		retry, err := a.post(ctx, url, body)
+		if err == nil || !retry || attempt >= a.retries {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+
+	}
+
+}
+
+func (a *Alerter) post(ctx context.Context, url string, body []byte) (retry bool, err error) {
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return
+	}
+
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	err = fmt.Errorf("response status %s", resp.Status)
+
+	return
+
+}
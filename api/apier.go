@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"time"
 	"github.com/tusupov/exmoarbitrage/model"
 )
 
@@ -10,3 +11,19 @@ type Apier interface {
 	GetPairList(ctx context.Context) (model.PairSettings, error)
 	GetOrders(ctx context.Context, pairs ...model.Pair) (model.PairOrders, error)
 }
+
+// Market data source of another time, as replayed history
+type Clock interface {
+	Now() time.Time
+}
+
+// Market data source moving in steps, as replayed history.
+// Reads return the same data until the next step.
+type Stepper interface {
+	Step() bool
+}
+
+// Streaming market data source, requested data is current when received
+type Updater interface {
+	Updated() time.Time
+}
@@ -0,0 +1,514 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+const (
+	BinanceBaseUrl = "https://api.binance.com/api/v3" // public api url
+
+	binanceDepthWorkers = 8    // order books loaded at once
+	binanceWeightLimit  = 1200 // request weight per minute
+	binanceTickerWeight = 4    // weight of the best levels of all pairs
+)
+
+// Binance order book limits, depth 1 is the best levels of all pairs in one request
+var binanceDepths = []int{1, 5, 10, 20, 50, 100, 500, 1000, 5000}
+
+var ErrBinanceWeight = errors.New("Превышен лимит веса запросов Binance, уменьшите DEPTH или число валют")
+
+// Binance symbols which differ from the common ones
+var binanceSymbols = Symbols{
+	"BCHABC": "BCH",
+	"BCHSV":  "BSV",
+}
+
+type BinanceError struct {
+	Code    int
+	Message string
+}
+
+func (e *BinanceError) Error() string {
+	return fmt.Sprintf("binance: error %d: %s", e.Code, e.Message)
+}
+
+// Binance public market data
+type binance struct {
+	baseUrl string
+	client  *http.Client
+	symbols Symbols
+
+	orderDepth int
+
+	mu           sync.Mutex
+	weightUsed   int // request weight used in weightMinute
+	weightMinute time.Time
+	pairsList    model.PairSettings
+	pairSymbols  map[model.Pair]string // model pair -> binance symbol
+	currencyList []model.Currency
+	infoTime     time.Time
+}
+
+func init() {
+	RegisterExchange("binance", BinanceBaseUrl, func(baseUrl string, client *http.Client) Exchange {
+		return NewBinance(baseUrl, client)
+	})
+}
+
+func NewBinance(baseUrl string, client *http.Client) *binance {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &binance{
+		baseUrl:    baseUrl,
+		client:     client,
+		symbols:    binanceSymbols,
+		orderDepth: DefaultOrderDepth,
+	}
+}
+
+func (b *binance) Name() string {
+	return "binance"
+}
+
+// Set order book depth, rounded up to a Binance limit.
+// Depth 1 loads the best levels only, larger depth loads
+// the order book of every pair within the request weight limit.
+func (b *binance) SetOrderDepth(depth int) error {
+
+	if depth < 1 || depth > binanceDepths[len(binanceDepths)-1] {
+		return ErrOrderDepth
+	}
+
+	i := sort.SearchInts(binanceDepths, depth)
+	b.orderDepth = binanceDepths[i]
+
+	return nil
+
+}
+
+// Currencies of trading pairs
+func (b *binance) GetCurrencyList(ctx context.Context) ([]model.Currency, error) {
+
+	if err := b.loadInfo(ctx); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.currencyList, nil
+
+}
+
+// Trading pairs with filters as settings
+func (b *binance) GetPairList(ctx context.Context) (model.PairSettings, error) {
+
+	if err := b.loadInfo(ctx); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.pairsList, nil
+
+}
+
+// Order books of the pairs, best levels with depth 1
+func (b *binance) GetOrders(ctx context.Context, pairs ...model.Pair) (pairOrders model.PairOrders, err error) {
+
+	if len(pairs) == 0 {
+		err = ErrPairMustNotEmpty
+		return
+	}
+
+	if err = b.loadInfo(ctx); err != nil {
+		return
+	}
+
+	b.mu.Lock()
+	symbols := make(map[string]model.Pair, len(pairs))
+	for _, pair := range pairs {
+		if symbol, ok := b.pairSymbols[pair]; ok {
+			symbols[symbol] = pair
+		}
+	}
+	b.mu.Unlock()
+
+	pairOrders = model.PairOrders{}
+
+	if b.orderDepth == 1 {
+		if err = b.reserveWeight(binanceTickerWeight); err != nil {
+			return
+		}
+		err = b.loadBookTicker(ctx, symbols, pairOrders)
+		return
+	}
+
+	if err = b.reserveWeight(binanceDepthWeight(b.orderDepth) * len(symbols)); err != nil {
+		return
+	}
+
+	err = b.loadDepths(ctx, symbols, pairOrders)
+
+	return
+
+}
+
+// Order books of the symbols, binanceDepthWorkers requests at once
+func (b *binance) loadDepths(ctx context.Context, symbols map[string]model.Pair, pairOrders model.PairOrders) (err error) {
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		workers = make(chan struct{}, binanceDepthWorkers)
+	)
+
+	for symbol, pair := range symbols {
+
+		workers <- struct{}{}
+		wg.Add(1)
+
+		go func(symbol string, pair model.Pair) {
+
+			defer wg.Done()
+			defer func() { <-workers }()
+
+			order, ok, loadErr := b.loadDepth(ctx, symbol)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if loadErr != nil {
+				if err == nil {
+					err = loadErr
+					cancel()
+				}
+				return
+			}
+
+			if ok {
+				pairOrders[pair] = order
+			}
+
+		}(symbol, pair)
+
+	}
+
+	wg.Wait()
+
+	return
+
+}
+
+// Reserve request weight in the current minute,
+// the used weight is updated from the responses
+func (b *binance) reserveWeight(weight int) error {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	minute := time.Now().Truncate(time.Minute)
+	if !minute.Equal(b.weightMinute) {
+		b.weightMinute = minute
+		b.weightUsed = 0
+	}
+
+	if b.weightUsed+weight > binanceWeightLimit {
+		return ErrBinanceWeight
+	}
+	b.weightUsed += weight
+
+	return nil
+
+}
+
+// Request weight of an order book
+func binanceDepthWeight(depth int) int {
+	switch {
+	case depth <= 100:
+		return 5
+	case depth <= 500:
+		return 25
+	case depth <= 1000:
+		return 50
+	default:
+		return 250
+	}
+}
+
+type binanceExchangeInfo struct {
+	Symbols []struct {
+		Symbol     string `json:"symbol"`
+		Status     string `json:"status"`
+		BaseAsset  string `json:"baseAsset"`
+		QuoteAsset string `json:"quoteAsset"`
+		Filters    []struct {
+			FilterType  string `json:"filterType"`
+			MinPrice    string `json:"minPrice"`
+			MaxPrice    string `json:"maxPrice"`
+			TickSize    string `json:"tickSize"`
+			MinQty      string `json:"minQty"`
+			MaxQty      string `json:"maxQty"`
+			MinNotional string `json:"minNotional"`
+		} `json:"filters"`
+	} `json:"symbols"`
+}
+
+// Load pairs and currencies, cached for CacheTime
+func (b *binance) loadInfo(ctx context.Context) error {
+
+	b.mu.Lock()
+	cached := time.Since(b.infoTime).Seconds() < CacheTime
+	b.mu.Unlock()
+
+	if cached {
+		return nil
+	}
+
+	var info binanceExchangeInfo
+	if err := b.get(ctx, "/exchangeInfo", nil, &info); err != nil {
+		return err
+	}
+
+	pairs := model.PairSettings{}
+	pairSymbols := map[model.Pair]string{}
+	currencySet := map[model.Currency]bool{}
+
+	for _, s := range info.Symbols {
+
+		if s.Status != "TRADING" {
+			continue
+		}
+
+		var setting model.Setting
+		for _, filter := range s.Filters {
+			switch filter.FilterType {
+			case "PRICE_FILTER":
+				setting.MinPrice = parseNumber(filter.MinPrice)
+				setting.MaxPrice = parseNumber(filter.MaxPrice)
+				setting.PricePrecision = decimalPlaces(filter.TickSize)
+			case "LOT_SIZE":
+				setting.MinQuantity = parseNumber(filter.MinQty)
+				setting.MaxQuantity = parseNumber(filter.MaxQty)
+			case "MIN_NOTIONAL":
+				setting.MinAmount = parseNumber(filter.MinNotional)
+			}
+		}
+
+		pair := b.symbols.Pair(s.BaseAsset, s.QuoteAsset)
+		pairs[pair] = setting
+		pairSymbols[pair] = s.Symbol
+		currencySet[b.symbols.Currency(s.BaseAsset)] = true
+		currencySet[b.symbols.Currency(s.QuoteAsset)] = true
+
+	}
+
+	if len(pairs) == 0 {
+		return ErrPairEmpty
+	}
+
+	currencies := make([]model.Currency, 0, len(currencySet))
+	for currency := range currencySet {
+		currencies = append(currencies, currency)
+	}
+	sort.Slice(currencies, func(i, j int) bool {
+		return currencies[i] < currencies[j]
+	})
+
+	b.mu.Lock()
+	b.pairsList = pairs
+	b.pairSymbols = pairSymbols
+	b.currencyList = currencies
+	b.infoTime = time.Now()
+	b.mu.Unlock()
+
+	return nil
+
+}
+
+// Order book of one symbol, not ok if a side is empty
+func (b *binance) loadDepth(ctx context.Context, symbol string) (order model.Order, ok bool, err error) {
+
+	var depth struct {
+		Bids [][]string `json:"bids"`
+		Asks [][]string `json:"asks"`
+	}
+
+	params := url.Values{}
+	params.Set("symbol", symbol)
+	params.Set("limit", strconv.Itoa(b.orderDepth))
+
+	if err = b.get(ctx, "/depth", params, &depth); err != nil {
+		return
+	}
+
+	asks := parseBinanceOffers(depth.Asks)
+	bids := parseBinanceOffers(depth.Bids)
+	if len(asks) == 0 || len(bids) == 0 {
+		return
+	}
+
+	order = model.Order{
+		Ask:  asks[0],
+		Bid:  bids[0],
+		Asks: asks,
+		Bids: bids,
+	}
+	ok = true
+
+	return
+
+}
+
+// Best levels of the symbols
+func (b *binance) loadBookTicker(ctx context.Context, symbols map[string]model.Pair, pairOrders model.PairOrders) error {
+
+	var tickers []struct {
+		Symbol   string `json:"symbol"`
+		BidPrice string `json:"bidPrice"`
+		BidQty   string `json:"bidQty"`
+		AskPrice string `json:"askPrice"`
+		AskQty   string `json:"askQty"`
+	}
+
+	if err := b.get(ctx, "/ticker/bookTicker", nil, &tickers); err != nil {
+		return err
+	}
+
+	for _, ticker := range tickers {
+
+		pair, ok := symbols[ticker.Symbol]
+		if !ok {
+			continue
+		}
+
+		asks := parseBinanceOffers([][]string{{ticker.AskPrice, ticker.AskQty}})
+		bids := parseBinanceOffers([][]string{{ticker.BidPrice, ticker.BidQty}})
+		if len(asks) == 0 || len(bids) == 0 {
+			continue
+		}
+
+		pairOrders[pair] = model.Order{
+			Ask:  asks[0],
+			Bid:  bids[0],
+			Asks: asks,
+			Bids: bids,
+		}
+
+	}
+
+	return nil
+
+}
+
+func (b *binance) get(ctx context.Context, path string, params url.Values, v interface{}) error {
+
+	u := b.baseUrl + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := b.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if used, err := strconv.Atoi(resp.Header.Get("X-MBX-USED-WEIGHT-1M")); err == nil {
+		b.mu.Lock()
+		if b.weightUsed < used {
+			b.weightUsed = used
+		}
+		b.mu.Unlock()
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var body struct {
+			Code int    `json:"code"`
+			Msg  string `json:"msg"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil || body.Msg == "" {
+			return &BinanceError{Code: resp.StatusCode, Message: resp.Status}
+		}
+		return &BinanceError{Code: body.Code, Message: body.Msg}
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+
+}
+
+// Parse order book side [[price, quantity], ...], empty levels are skipped
+func parseBinanceOffers(levels [][]string) (offers []model.Offer) {
+
+	offers = make([]model.Offer, 0, len(levels))
+
+	for _, level := range levels {
+
+		if len(level) < 2 {
+			break
+		}
+
+		price, err := strconv.ParseFloat(level[0], 64)
+		if err != nil {
+			break
+		}
+
+		quantity, err := strconv.ParseFloat(level[1], 64)
+		if err != nil {
+			break
+		}
+
+		if price <= 0 || quantity <= 0 {
+			continue
+		}
+
+		offers = append(offers, model.Offer{
+			Price:    price,
+			Quantity: quantity,
+			Amount:   price * quantity,
+		})
+
+	}
+
+	return
+
+}
+
+func parseNumber(s string) float64 {
+	n, _ := strconv.ParseFloat(s, 64)
+	return n
+}
+
+// Decimal places of a step as "0.01000000", 0 for whole steps
+func decimalPlaces(step string) int {
+
+	i := strings.Index(step, ".")
+	if i < 0 {
+		return 0
+	}
+
+	return len(strings.TrimRight(step[i+1:], "0"))
+
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+const testBinanceInfo = `{"symbols":[
+{"symbol":"BTCUSDT","status":"TRADING","baseAsset":"BTC","quoteAsset":"USDT","filters":[
+	{"filterType":"PRICE_FILTER","minPrice":"0.01000000","maxPrice":"10000000.00000000","tickSize":"0.01000000"},
+	{"filterType":"LOT_SIZE","minQty":"0.00000100","maxQty":"9000.00000000","stepSize":"0.00000100"},
+	{"filterType":"MIN_NOTIONAL","minNotional":"10.00000000"}]},
+{"symbol":"BCHABCBTC","status":"TRADING","baseAsset":"BCHABC","quoteAsset":"BTC","filters":[]},
+{"symbol":"ETHBTC","status":"BREAK","baseAsset":"ETH","quoteAsset":"BTC","filters":[]}
+]}`
+
+// Binance stand-in, counts requests per path
+func newTestBinance() (*httptest.Server, map[string]int, *sync.Mutex) {
+
+	var mu sync.Mutex
+	requests := map[string]int{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+
+		switch r.URL.Path {
+
+		case "/exchangeInfo":
+			w.Write([]byte(testBinanceInfo))
+
+		case "/depth":
+			w.Header().Set("X-MBX-USED-WEIGHT-1M", "1190")
+			if r.URL.Query().Get("symbol") != "BTCUSDT" || r.URL.Query().Get("limit") != "10" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"code":-1121,"msg":"Invalid symbol."}`))
+				return
+			}
+			w.Write([]byte(`{"lastUpdateId":1,"bids":[["3600.00","0.5"],["3590.00","1"]],"asks":[["3610.00","0.2"],["3620.00","0"],["3630.00","2"]]}`))
+
+		case "/ticker/bookTicker":
+			w.Write([]byte(`[{"symbol":"BTCUSDT","bidPrice":"3600.00","bidQty":"0.5","askPrice":"3610.00","askQty":"0.2"},{"symbol":"BCHABCBTC","bidPrice":"0.03","bidQty":"10","askPrice":"0.031","askQty":"5"},{"symbol":"XRPBTC","bidPrice":"0.0001","bidQty":"1","askPrice":"0.0002","askQty":"1"}]`))
+
+		default:
+			w.WriteHeader(http.StatusNotFound)
+
+		}
+
+	}))
+
+	return server, requests, &mu
+
+}
+
+func TestBinance_GetPairList(t *testing.T) {
+
+	server, requests, mu := newTestBinance()
+	defer server.Close()
+
+	binance := NewBinance(server.URL, server.Client())
+	ctx := context.Background()
+
+	pairs, err := binance.GetPairList(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, model.PairSettings{
+		"BTC_USDT": model.Setting{
+			MinQuantity:    0.000001,
+			MaxQuantity:    9000,
+			MinPrice:       0.01,
+			MaxPrice:       10000000,
+			MinAmount:      10,
+			PricePrecision: 2,
+		},
+		"BCH_BTC": model.Setting{},
+	}, pairs)
+
+	currencies, err := binance.GetCurrencyList(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []model.Currency{"BCH", "BTC", "USDT"}, currencies)
+
+	mu.Lock()
+	assert.Equal(t, 1, requests["/exchangeInfo"])
+	mu.Unlock()
+
+}
+
+func TestBinance_GetOrders(t *testing.T) {
+
+	server, requests, mu := newTestBinance()
+	defer server.Close()
+
+	binance := NewBinance(server.URL, server.Client())
+	assert.Nil(t, binance.SetOrderDepth(7))
+	assert.Equal(t, ErrOrderDepth, binance.SetOrderDepth(0))
+
+	ctx := context.Background()
+
+	// Full order book of a few pairs
+	pairOrders, err := binance.GetOrders(ctx, "BTC_USDT")
+	assert.Nil(t, err)
+	assert.Equal(t, model.PairOrders{
+		"BTC_USDT": model.Order{
+			Ask:  model.Offer{Price: 3610, Quantity: 0.2, Amount: 722},
+			Bid:  model.Offer{Price: 3600, Quantity: 0.5, Amount: 1800},
+			Asks: []model.Offer{{Price: 3610, Quantity: 0.2, Amount: 722}, {Price: 3630, Quantity: 2, Amount: 7260}},
+			Bids: []model.Offer{{Price: 3600, Quantity: 0.5, Amount: 1800}, {Price: 3590, Quantity: 1, Amount: 3590}},
+		},
+	}, pairOrders)
+
+	// Used weight of the minute is taken from the response
+	binance.mu.Lock()
+	assert.Equal(t, 1190, binance.weightUsed)
+	binance.weightUsed = 0
+	binance.mu.Unlock()
+
+	// Exchange error of any pair
+	_, err = binance.GetOrders(ctx, "BTC_USDT", "BCH_BTC")
+	assert.Equal(t, &BinanceError{Code: -1121, Message: "Invalid symbol."}, err)
+
+	// Order books of too many pairs are not requested
+	binance.mu.Lock()
+	binance.weightUsed = 0
+	pairs := make([]model.Pair, 0, 300)
+	for i := 0; i < 300; i++ {
+		pair := model.Pair(fmt.Sprintf("C%d_BTC", i))
+		binance.pairSymbols[pair] = string(pair)
+		pairs = append(pairs, pair)
+	}
+	binance.mu.Unlock()
+
+	mu.Lock()
+	depthRequests := requests["/depth"]
+	mu.Unlock()
+
+	_, err = binance.GetOrders(ctx, pairs...)
+	assert.Equal(t, ErrBinanceWeight, err)
+
+	mu.Lock()
+	assert.Equal(t, depthRequests, requests["/depth"])
+	mu.Unlock()
+
+	// Best levels of all pairs in one request with depth 1
+	assert.Nil(t, binance.SetOrderDepth(1))
+	pairOrders, err = binance.GetOrders(ctx, append(pairs, "BTC_USDT", "BCH_BTC")...)
+	assert.Nil(t, err)
+	assert.Len(t, pairOrders, 2)
+	assert.Equal(t, []model.Offer{{Price: 0.031, Quantity: 5, Amount: 0.155}}, pairOrders["BCH_BTC"].Asks)
+
+	mu.Lock()
+	assert.Equal(t, 1, requests["/ticker/bookTicker"])
+	mu.Unlock()
+
+	_, err = binance.GetOrders(ctx)
+	assert.Equal(t, ErrPairMustNotEmpty, err)
+
+}
+
+func TestNewExchange(t *testing.T) {
+
+	exchange, err := NewExchange("Binance", "http://localhost", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "binance", exchange.Name())
+
+	exchange, err = NewExchange("exmo", "", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, ExmoBaseUrl, exchange.(*exmo).baseUrl)
+
+	_, err = NewExchange("unknown", "", nil)
+	assert.Equal(t, ErrUnknownExchange, err)
+
+	assert.Equal(t, []string{"binance", "exmo"}, ExchangeNames())
+
+}
+
+func TestSymbols(t *testing.T) {
+	assert.Equal(t, model.Currency("BCH"), binanceSymbols.Currency("bchabc"))
+	assert.Equal(t, model.Currency("BTC"), binanceSymbols.Currency("BTC"))
+	assert.Equal(t, model.Pair("BSV_USDT"), binanceSymbols.Pair("BCHSV", "USDT"))
+}
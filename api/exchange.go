@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+var ErrUnknownExchange = errors.New("Неизвестная биржа")
+
+// Market data adapter of an exchange, pairs and currencies
+// are normalized to model symbols, e.g. BTC_USD
+type Exchange interface {
+	Apier
+	Name() string
+}
+
+// Market with configurable order book depth
+type DepthSetter interface {
+	SetOrderDepth(depth int) error
+}
+
+// Creates exchange adapter with api url and http client
+type ExchangeFactory func(baseUrl string, client *http.Client) Exchange
+
+type exchangeEntry struct {
+	baseUrl string
+	factory ExchangeFactory
+}
+
+var (
+	exchangesMu sync.RWMutex
+	exchanges   = map[string]exchangeEntry{}
+)
+
+// Register exchange adapter by lower case name with its default api url,
+// adapters register themselves in init
+func RegisterExchange(name, baseUrl string, factory ExchangeFactory) {
+
+	exchangesMu.Lock()
+	defer exchangesMu.Unlock()
+
+	exchanges[strings.ToLower(name)] = exchangeEntry{
+		baseUrl: baseUrl,
+		factory: factory,
+	}
+
+}
+
+// New adapter of the registered exchange, empty baseUrl - default api url
+func NewExchange(name, baseUrl string, client *http.Client) (Exchange, error) {
+
+	exchangesMu.RLock()
+	entry, ok := exchanges[strings.ToLower(name)]
+	exchangesMu.RUnlock()
+
+	if !ok {
+		return nil, ErrUnknownExchange
+	}
+
+	if baseUrl == "" {
+		baseUrl = entry.baseUrl
+	}
+
+	return entry.factory(baseUrl, client), nil
+
+}
+
+// Registered exchange names, sorted
+func ExchangeNames() []string {
+
+	exchangesMu.RLock()
+	defer exchangesMu.RUnlock()
+
+	names := make([]string, 0, len(exchanges))
+	for name := range exchanges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+
+}
+
+// Exchange symbol to model currency normalization, the exchange
+// symbols which differ from the common ones, e.g. "XBT": "BTC"
+type Symbols map[string]model.Currency
+
+func (s Symbols) Currency(symbol string) model.Currency {
+
+	symbol = strings.ToUpper(symbol)
+	if currency, ok := s[symbol]; ok {
+		return currency
+	}
+
+	return model.Currency(symbol)
+
+}
+
+// Model pair of exchange base and quote symbols
+func (s Symbols) Pair(base, quote string) model.Pair {
+	return model.Pair(string(s.Currency(base)) + "_" + string(s.Currency(quote)))
+}
@@ -4,28 +4,62 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"github.com/tusupov/exmoarbitrage/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 const (
 	ExmoBaseUrl = "https://api.exmo.com/v1" // api url
 	CacheTime   = 24 * 60 * 60              // 24 hour
+
+	DefaultOrderDepth = 100  // order book levels per side
+	MaxOrderDepth     = 1000 // exmo order_book limit
 )
 
 var (
 	ErrPairEmpty        = errors.New("Валютные пары пустые")
 	ErrPairMustNotEmpty = errors.New("`pairs` не должен быть пустым")
+	ErrOrderDepth       = fmt.Errorf("Глубина стакана должна быть от 1 до %d", MaxOrderDepth)
+)
+
+var (
+	exmoRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "exmo_request_duration_seconds",
+		Help:    "Exmo API request latency",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint"})
+	exmoRequestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "exmo_request_errors_total",
+		Help: "Exmo API requests failed, answered with error status or with error result",
+	}, []string{"endpoint"})
+	exmoCacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "exmo_cache_hits_total",
+		Help: "Exmo lists served from cache",
+	}, []string{"list"})
+	exmoCacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "exmo_cache_misses_total",
+		Help: "Exmo lists loaded from the exchange",
+	}, []string{"list"})
 )
 
 type exmo struct {
 	baseUrl string
 	client  *http.Client
 
+	orderDepth int
+
+	// Lists cache, the adapter is shared by the poller, executor and cross search
+	mu        sync.Mutex
 	pairsList model.PairSettings
 	pairsTime time.Time
 
@@ -33,24 +67,54 @@ type exmo struct {
 	currencyTime time.Time
 }
 
+func init() {
+	RegisterExchange("exmo", ExmoBaseUrl, func(baseUrl string, client *http.Client) Exchange {
+		return NewExmo(baseUrl, client)
+	})
+}
+
 func NewExmo(baseUrl string, client *http.Client) *exmo {
 	if client == nil {
 		client = http.DefaultClient
 	}
 
 	return &exmo{
-		baseUrl: baseUrl,
-		client:  client,
+		baseUrl:    baseUrl,
+		client:     client,
+		orderDepth: DefaultOrderDepth,
+	}
+}
+
+// Exmo symbols are the model ones
+func (e *exmo) Name() string {
+	return "exmo"
+}
+
+// Set order book depth (levels per side) for GetOrders
+func (e *exmo) SetOrderDepth(depth int) error {
+
+	if depth < 1 || depth > MaxOrderDepth {
+		return ErrOrderDepth
 	}
+
+	e.orderDepth = depth
+
+	return nil
 }
 
 // Get currency list
 func (e *exmo) GetCurrencyList(ctx context.Context) (list []model.Currency, err error) {
 
 	// Load from cache
+	e.mu.Lock()
 	if time.Since(e.currencyTime).Seconds() < CacheTime {
-		return e.currencyList, nil
+		list = e.currencyList
+		e.mu.Unlock()
+		exmoCacheHits.WithLabelValues("currency").Inc()
+		return
 	}
+	e.mu.Unlock()
+	exmoCacheMisses.WithLabelValues("currency").Inc()
 
 	resp, err := e.doRequest(ctx, http.MethodGet, e.baseUrl+"/currency/", nil)
 	if err != nil {
@@ -58,14 +122,16 @@ func (e *exmo) GetCurrencyList(ctx context.Context) (list []model.Currency, err
 	}
 	defer resp.Body.Close()
 
-	err = json.NewDecoder(resp.Body).Decode(&list)
+	err = e.decode(resp, "currency", &list)
 	if err != nil {
 		return
 	}
 
 	// Caching
+	e.mu.Lock()
 	e.currencyList = list
 	e.currencyTime = time.Now()
+	e.mu.Unlock()
 
 	return
 }
@@ -74,9 +140,15 @@ func (e *exmo) GetCurrencyList(ctx context.Context) (list []model.Currency, err
 func (e *exmo) GetPairList(ctx context.Context) (pairs model.PairSettings, err error) {
 
 	// Load from cache
+	e.mu.Lock()
 	if time.Since(e.pairsTime).Seconds() < CacheTime {
-		return e.pairsList, nil
+		pairs = e.pairsList
+		e.mu.Unlock()
+		exmoCacheHits.WithLabelValues("pair_settings").Inc()
+		return
 	}
+	e.mu.Unlock()
+	exmoCacheMisses.WithLabelValues("pair_settings").Inc()
 
 	resp, err := e.doRequest(ctx, http.MethodGet, e.baseUrl+"/pair_settings/", nil)
 	if err != nil {
@@ -88,7 +160,7 @@ func (e *exmo) GetPairList(ctx context.Context) (pairs model.PairSettings, err e
 		return
 	}
 
-	err = json.NewDecoder(resp.Body).Decode(&pairs)
+	err = e.decode(resp, "pair_settings", &pairs)
 	if err != nil {
 		return
 	}
@@ -99,8 +171,10 @@ func (e *exmo) GetPairList(ctx context.Context) (pairs model.PairSettings, err e
 	}
 
 	// Caching
+	e.mu.Lock()
 	e.pairsList = pairs
 	e.pairsTime = time.Now()
+	e.mu.Unlock()
 
 	return
 }
@@ -119,7 +193,7 @@ func (e *exmo) GetOrders(ctx context.Context, pairs ...model.Pair) (pairOrders m
 	}
 	pairParam = strings.TrimRight(pairParam, ",")
 
-	resp, err := e.doRequest(ctx, http.MethodGet, e.baseUrl+"/order_book/?limit=1&pair="+pairParam, nil)
+	resp, err := e.doRequest(ctx, http.MethodGet, e.baseUrl+"/order_book/?limit="+strconv.Itoa(e.orderDepth)+"&pair="+pairParam, nil)
 	if err != nil {
 		return
 	}
@@ -129,7 +203,7 @@ func (e *exmo) GetOrders(ctx context.Context, pairs ...model.Pair) (pairOrders m
 		Ask [][]string
 		Bid [][]string
 	}
-	err = json.NewDecoder(resp.Body).Decode(&bodyStruct)
+	err = e.decode(resp, "order_book", &bodyStruct)
 	if err != nil {
 		return
 	}
@@ -138,33 +212,59 @@ func (e *exmo) GetOrders(ctx context.Context, pairs ...model.Pair) (pairOrders m
 
 	for bodyPair, bodyOrder := range bodyStruct {
 
-		if len(bodyOrder.Ask) == 0 || len(bodyOrder.Bid) == 0 {
+		asks := parseOffers(bodyOrder.Ask)
+		bids := parseOffers(bodyOrder.Bid)
+
+		if len(asks) == 0 || len(bids) == 0 {
 			continue
 		}
 
-		if len(bodyOrder.Ask[0]) != 3 || len(bodyOrder.Bid[0]) != 3 {
-			continue
+		pairOrders[bodyPair] = model.Order{
+			Ask:  asks[0],
+			Bid:  bids[0],
+			Asks: asks,
+			Bids: bids,
 		}
 
-		askPrice, errParse := strconv.ParseFloat(bodyOrder.Ask[0][0], 64)
-		if errParse != nil {
-			continue
+	}
+
+	return
+
+}
+
+// Parse order book side [[price, quantity, amount], ...]
+// Stops at the first malformed level, so the ladder stays contiguous
+func parseOffers(levels [][]string) (offers []model.Offer) {
+
+	offers = make([]model.Offer, 0, len(levels))
+
+	for _, level := range levels {
+
+		if len(level) != 3 {
+			break
 		}
 
-		bidPrice, errParse := strconv.ParseFloat(bodyOrder.Bid[0][0], 64)
-		if errParse != nil {
-			continue
+		price, err := strconv.ParseFloat(level[0], 64)
+		if err != nil {
+			break
 		}
 
-		pairOrders[bodyPair] = model.Order{
-			Ask: model.Offer{
-				Price: askPrice,
-			},
-			Bid: model.Offer{
-				Price: bidPrice,
-			},
+		quantity, err := strconv.ParseFloat(level[1], 64)
+		if err != nil {
+			break
+		}
+
+		amount, err := strconv.ParseFloat(level[2], 64)
+		if err != nil {
+			break
 		}
 
+		offers = append(offers, model.Offer{
+			Price:    price,
+			Quantity: quantity,
+			Amount:   amount,
+		})
+
 	}
 
 	return
@@ -175,12 +275,54 @@ func (e *exmo) doRequest(ctx context.Context, method string, url string, body io
 
 	req, err := http.NewRequest(method, url, body)
 	if err != nil {
+		e.mu.Lock()
 		e.pairsTime = time.Time{}
+		e.mu.Unlock()
 		return
 	}
 
 	req = req.WithContext(ctx)
 
-	return e.client.Do(req)
+	endpoint := e.endpoint(url)
+	start := time.Now()
+
+	resp, err = e.client.Do(req)
+
+	exmoRequestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	if err != nil || resp.StatusCode >= http.StatusBadRequest {
+		exmoRequestErrors.WithLabelValues(endpoint).Inc()
+	}
+
+	return
+
+}
+
+// Decode response body into v. Errors answered with status 200
+// as {"result":false,"error":"..."} are counted and returned.
+func (e *exmo) decode(resp *http.Response, endpoint string, v interface{}) error {
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if err = exmoStatus(data); err != nil {
+		exmoRequestErrors.WithLabelValues(endpoint).Inc()
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+
+}
+
+// Api method of request url, "order_book" for ".../order_book/?pair=BTC_USD"
+func (e *exmo) endpoint(url string) string {
+
+	url = strings.TrimPrefix(url, e.baseUrl)
+	if i := strings.Index(url, "?"); i >= 0 {
+		url = url[:i]
+	}
+
+	return strings.Trim(url, "/")
 
 }
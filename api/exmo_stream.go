@@ -0,0 +1,419 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"github.com/gorilla/websocket"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+const (
+	ExmoStreamUrl = "wss://ws-api.exmo.com:443/v1/public" // public websocket url
+
+	exmoOrderBookTopic = "spot/order_book_updates:"
+)
+
+// Exmo websocket order book client.
+// Every pair gets a snapshot after subscribe and diffs after it,
+// the book is resubscribed when diffs are out of order or stop.
+// The connection is pinged, it is dropped without messages or pongs for readTimeout.
+type exmoStream struct {
+	url    string
+	dialer *websocket.Dialer
+	depth  int
+
+	minBackoff   time.Duration
+	maxBackoff   time.Duration
+	readTimeout  time.Duration
+	pingInterval time.Duration
+	maxAge       time.Duration // max time without messages of a book
+
+	mu      sync.RWMutex
+	books   map[model.Pair]*streamBook
+	updated time.Time // last message or pong
+
+	writeMu   sync.Mutex
+	requestId int
+}
+
+// Local order book: price -> level
+type streamBook struct {
+	asks    map[float64]model.Offer
+	bids    map[float64]model.Offer
+	synced  bool
+	ts      int64     // last message time
+	seq     uint64    // last message sequence, if sent by exchange
+	updated time.Time // last message receive time
+}
+
+type exmoStreamRequest struct {
+	Id     int      `json:"id"`
+	Method string   `json:"method"`
+	Topics []string `json:"topics"`
+}
+
+type exmoStreamMessage struct {
+	Ts      int64  `json:"ts"`
+	Event   string `json:"event"`
+	Topic   string `json:"topic"`
+	Seq     uint64 `json:"seq"`
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    struct {
+		Ask [][]string `json:"ask"`
+		Bid [][]string `json:"bid"`
+	} `json:"data"`
+}
+
+func NewExmoStream(url string, depth int) *exmoStream {
+	return &exmoStream{
+		url:          url,
+		dialer:       websocket.DefaultDialer,
+		depth:        depth,
+		minBackoff:   time.Second,
+		maxBackoff:   30 * time.Second,
+		readTimeout:  30 * time.Second,
+		pingInterval: 10 * time.Second,
+		maxAge:       5 * time.Minute,
+		books:        make(map[model.Pair]*streamBook),
+	}
+}
+
+// Keep order books of pairs in sync until ctx is done,
+// reconnecting with exponential backoff
+func (s *exmoStream) Run(ctx context.Context, pairs ...model.Pair) error {
+
+	if len(pairs) == 0 {
+		return ErrPairMustNotEmpty
+	}
+
+	backoff := s.minBackoff
+
+	for {
+
+		synced, err := s.session(ctx, pairs)
+		s.reset()
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		log.Printf("Exmo stream error: %v, reconnect in %s\n", err, backoff)
+
+		if synced {
+			backoff = s.minBackoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+
+	}
+
+}
+
+// Order books of synced pairs, stale books are skipped
+func (s *exmoStream) GetOrders(ctx context.Context, pairs ...model.Pair) (pairOrders model.PairOrders, err error) {
+
+	if len(pairs) == 0 {
+		err = ErrPairMustNotEmpty
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pairOrders = model.PairOrders{}
+
+	for _, pair := range pairs {
+
+		book, ok := s.books[pair]
+		if !ok || !book.synced || time.Since(book.updated) > s.maxAge {
+			continue
+		}
+
+		asks := sortOffers(book.asks, false, s.depth)
+		bids := sortOffers(book.bids, true, s.depth)
+		if len(asks) == 0 || len(bids) == 0 {
+			continue
+		}
+
+		pairOrders[pair] = model.Order{
+			Ask:  asks[0],
+			Bid:  bids[0],
+			Asks: asks,
+			Bids: bids,
+		}
+
+	}
+
+	return
+
+}
+
+// Time the books were last known current, zero before the first connection
+func (s *exmoStream) Updated() time.Time {
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.updated
+
+}
+
+// One connection lifetime, synced is true if any snapshot was received
+func (s *exmoStream) session(ctx context.Context, pairs []model.Pair) (synced bool, err error) {
+
+	conn, _, err := s.dialer.DialContext(ctx, s.url, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// Unblock read on cancel
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	// Every message and pong extends the read deadline
+	// and shows the books are current
+	alive := func(string) error {
+		s.mu.Lock()
+		s.updated = time.Now()
+		s.mu.Unlock()
+		return conn.SetReadDeadline(time.Now().Add(s.readTimeout))
+	}
+	conn.SetReadDeadline(time.Now().Add(s.readTimeout))
+	conn.SetPongHandler(alive)
+
+	// Books wait for snapshots
+	now := time.Now()
+	topics := make([]string, 0, len(pairs))
+	s.mu.Lock()
+	for _, pair := range pairs {
+		s.books[pair] = &streamBook{updated: now}
+		topics = append(topics, exmoOrderBookTopic+string(pair))
+	}
+	s.mu.Unlock()
+
+	if err = s.send(conn, "subscribe", topics...); err != nil {
+		return
+	}
+
+	go s.keepAlive(conn, done)
+
+	for {
+
+		var msg exmoStreamMessage
+		if err = conn.ReadJSON(&msg); err != nil {
+			return
+		}
+		alive("")
+
+		switch msg.Event {
+
+		case "snapshot":
+			s.snapshot(msg)
+			synced = true
+
+		case "update":
+			if !s.update(msg) {
+				if err = s.resync(conn, msg.Topic); err != nil {
+					return
+				}
+			}
+
+		case "error":
+			err = fmt.Errorf("exmo stream: %d %s", msg.Code, msg.Message)
+			return
+
+		}
+
+	}
+
+}
+
+// Ping the connection and resubscribe stale books until done,
+// a failed write leaves the read to time out
+func (s *exmoStream) keepAlive(conn *websocket.Conn, done <-chan struct{}) {
+
+	ticker := time.NewTicker(s.pingInterval)
+	defer ticker.Stop()
+
+	for {
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.pingInterval)); err != nil {
+			return
+		}
+
+		for _, pair := range s.stale() {
+			if err := s.resync(conn, exmoOrderBookTopic+string(pair)); err != nil {
+				return
+			}
+		}
+
+	}
+
+}
+
+// Pairs without messages for maxAge, they wait for a new snapshot
+func (s *exmoStream) stale() (pairs []model.Pair) {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for pair, book := range s.books {
+		if now.Sub(book.updated) > s.maxAge {
+			book.synced = false
+			book.updated = now
+			pairs = append(pairs, pair)
+		}
+	}
+
+	return
+
+}
+
+// Write request, safe for the reader and keepAlive
+func (s *exmoStream) send(conn *websocket.Conn, method string, topics ...string) error {
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.requestId++
+	return conn.WriteJSON(exmoStreamRequest{
+		Id:     s.requestId,
+		Method: method,
+		Topics: topics,
+	})
+
+}
+
+// Subscribe again to get a new snapshot
+func (s *exmoStream) resync(conn *websocket.Conn, topic string) error {
+
+	if err := s.send(conn, "unsubscribe", topic); err != nil {
+		return err
+	}
+
+	return s.send(conn, "subscribe", topic)
+
+}
+
+func (s *exmoStream) snapshot(msg exmoStreamMessage) {
+
+	book := &streamBook{
+		asks:    make(map[float64]model.Offer),
+		bids:    make(map[float64]model.Offer),
+		synced:  true,
+		ts:      msg.Ts,
+		seq:     msg.Seq,
+		updated: time.Now(),
+	}
+
+	applyLevels(book.asks, msg.Data.Ask)
+	applyLevels(book.bids, msg.Data.Bid)
+
+	s.mu.Lock()
+	s.books[topicPair(msg.Topic)] = book
+	s.mu.Unlock()
+
+}
+
+// Apply diff, false if the book must be resynced.
+// Diffs of books waiting for a snapshot are skipped.
+func (s *exmoStream) update(msg exmoStreamMessage) bool {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	book, ok := s.books[topicPair(msg.Topic)]
+	if !ok || !book.synced {
+		return true
+	}
+
+	// Out of order or missed diff
+	if msg.Ts < book.ts || (msg.Seq != 0 && book.seq != 0 && msg.Seq != book.seq+1) {
+		book.synced = false
+		return false
+	}
+
+	applyLevels(book.asks, msg.Data.Ask)
+	applyLevels(book.bids, msg.Data.Bid)
+	book.ts, book.seq = msg.Ts, msg.Seq
+	book.updated = time.Now()
+
+	return true
+
+}
+
+// Forget all books, they are not updated without connection
+func (s *exmoStream) reset() {
+	s.mu.Lock()
+	s.books = make(map[model.Pair]*streamBook)
+	s.mu.Unlock()
+}
+
+// Set levels, zero quantity removes the price level
+func applyLevels(side map[float64]model.Offer, levels [][]string) {
+
+	for _, offer := range parseOffers(levels) {
+		if offer.Quantity == 0 {
+			delete(side, offer.Price)
+			continue
+		}
+		side[offer.Price] = offer
+	}
+
+}
+
+// Levels sorted by price, descending for bids, limited by depth
+func sortOffers(side map[float64]model.Offer, desc bool, depth int) []model.Offer {
+
+	offers := make([]model.Offer, 0, len(side))
+	for _, offer := range side {
+		offers = append(offers, offer)
+	}
+
+	sort.Slice(offers, func(i, j int) bool {
+		if desc {
+			return offers[i].Price > offers[j].Price
+		}
+		return offers[i].Price < offers[j].Price
+	})
+
+	if depth > 0 && len(offers) > depth {
+		offers = offers[:depth]
+	}
+
+	return offers
+
+}
+
+func topicPair(topic string) model.Pair {
+	return model.Pair(strings.TrimPrefix(topic, exmoOrderBookTopic))
+}
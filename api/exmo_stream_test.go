@@ -0,0 +1,238 @@
+package api
+
+import (
+	"context"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Local websocket stand-in: accepted connections and received requests
+func newTestStreamServer() (*httptest.Server, chan *websocket.Conn, chan exmoStreamRequest) {
+
+	conns := make(chan *websocket.Conn, 10)
+	requests := make(chan exmoStreamRequest, 100)
+	upgrader := websocket.Upgrader{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conns <- conn
+
+		for {
+			var req exmoStreamRequest
+			if err := conn.ReadJSON(&req); err != nil {
+				return
+			}
+			requests <- req
+		}
+
+	}))
+
+	return server, conns, requests
+
+}
+
+// Wait until condition is true or fail after a second
+func waitFor(t *testing.T, condition func() bool) bool {
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Error("condition is not met")
+	return false
+
+}
+
+func TestExmoStream(t *testing.T) {
+
+	server, conns, requests := newTestStreamServer()
+	defer server.Close()
+
+	stream := NewExmoStream("ws"+strings.TrimPrefix(server.URL, "http"), 10)
+	stream.minBackoff = 10 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- stream.Run(ctx, "BTC_USD")
+	}()
+
+	topic := "spot/order_book_updates:BTC_USD"
+	getOrder := func() (model.Order, bool) {
+		pairOrders, _ := stream.GetOrders(ctx, "BTC_USD")
+		return pairOrders.GetOrder("BTC_USD")
+	}
+
+	conn := <-conns
+	req := <-requests
+	assert.Equal(t, "subscribe", req.Method)
+	assert.Equal(t, []string{topic}, req.Topics)
+
+	// Snapshot and diff
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"ts":1,"seq":1,"event":"snapshot","topic":"`+topic+`","data":{"ask":[["100","1","100"],["101","2","202"]],"bid":[["99","1","99"]]}}`))
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"ts":2,"seq":2,"event":"update","topic":"`+topic+`","data":{"ask":[["100","0","0"]],"bid":[["99.5","1","99.5"]]}}`))
+
+	waitFor(t, func() bool {
+		order, ok := getOrder()
+		return ok && order.Bid.Price == 99.5
+	})
+
+	assert.False(t, stream.Updated().IsZero())
+
+	order, _ := getOrder()
+	assert.Equal(t, []model.Offer{{Price: 101, Quantity: 2, Amount: 202}}, order.Asks)
+	assert.Equal(t, []model.Offer{{Price: 99.5, Quantity: 1, Amount: 99.5}, {Price: 99, Quantity: 1, Amount: 99}}, order.Bids)
+	assert.Equal(t, order.Asks[0], order.Ask)
+
+	// Sequence gap resubscribes the pair
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"ts":3,"seq":4,"event":"update","topic":"`+topic+`","data":{"ask":[["105","1","105"]],"bid":[]}}`))
+
+	req = <-requests
+	assert.Equal(t, "unsubscribe", req.Method)
+	assert.Equal(t, []string{topic}, req.Topics)
+	req = <-requests
+	assert.Equal(t, "subscribe", req.Method)
+
+	_, ok := getOrder()
+	assert.False(t, ok)
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"ts":4,"seq":10,"event":"snapshot","topic":"`+topic+`","data":{"ask":[["102","1","102"]],"bid":[["98","1","98"]]}}`))
+	waitFor(t, func() bool {
+		order, ok := getOrder()
+		return ok && order.Ask.Price == 102
+	})
+
+	// Reconnect after connection loss
+	conn.Close()
+
+	conn = <-conns
+	req = <-requests
+	assert.Equal(t, "subscribe", req.Method)
+
+	_, ok = getOrder()
+	assert.False(t, ok)
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"ts":5,"event":"snapshot","topic":"`+topic+`","data":{"ask":[["103","1","103"]],"bid":[["97","1","97"]]}}`))
+	waitFor(t, func() bool {
+		order, ok := getOrder()
+		return ok && order.Ask.Price == 103
+	})
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+
+}
+
+func TestExmoStream_keepAlive(t *testing.T) {
+
+	server, conns, requests := newTestStreamServer()
+	defer server.Close()
+
+	stream := NewExmoStream("ws"+strings.TrimPrefix(server.URL, "http"), 10)
+	stream.minBackoff = 10 * time.Millisecond
+	stream.readTimeout = 100 * time.Millisecond
+	stream.pingInterval = 20 * time.Millisecond
+	stream.maxAge = 200 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go stream.Run(ctx, "BTC_USD")
+
+	topic := "spot/order_book_updates:BTC_USD"
+	synced := func() bool {
+		pairOrders, _ := stream.GetOrders(ctx, "BTC_USD")
+		return pairOrders.Exists("BTC_USD")
+	}
+
+	// Pongs keep the quiet connection alive
+	conn := <-conns
+	<-requests
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"ts":1,"event":"snapshot","topic":"`+topic+`","data":{"ask":[["100","1","100"]],"bid":[["99","1","99"]]}}`))
+	waitFor(t, synced)
+
+	// Book without messages is dropped and resubscribed
+	req := <-requests
+	assert.Equal(t, "unsubscribe", req.Method)
+	assert.False(t, synced())
+	req = <-requests
+	assert.Equal(t, "subscribe", req.Method)
+	assert.Equal(t, []string{topic}, req.Topics)
+
+	select {
+	case <-conns:
+		t.Error("connection is not kept alive")
+	default:
+	}
+
+}
+
+func TestExmoStream_readTimeout(t *testing.T) {
+
+	// Server neither reads nor answers pings
+	conns := make(chan *websocket.Conn, 10)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if conn, err := upgrader.Upgrade(w, r, nil); err == nil {
+			conns <- conn
+		}
+	}))
+	defer server.Close()
+
+	stream := NewExmoStream("ws"+strings.TrimPrefix(server.URL, "http"), 10)
+	stream.minBackoff = 10 * time.Millisecond
+	stream.readTimeout = 50 * time.Millisecond
+	stream.pingInterval = 20 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go stream.Run(ctx, "BTC_USD")
+
+	<-conns
+	select {
+	case <-conns:
+	case <-time.After(time.Second):
+		t.Error("silent connection is not reconnected")
+	}
+
+}
+
+func TestStreamApi_GetOrders(t *testing.T) {
+
+	server, client, baseUrl := newTestClient()
+	defer server.Close()
+
+	stream := NewExmoStream("", 10)
+	stream.books["BTC_USD"] = &streamBook{
+		asks:    map[float64]model.Offer{100: {Price: 100, Quantity: 1, Amount: 100}},
+		bids:    map[float64]model.Offer{99: {Price: 99, Quantity: 1, Amount: 99}},
+		synced:  true,
+		updated: time.Now(),
+	}
+
+	api := NewStreamApi(NewExmo(baseUrl, client), stream)
+	ctx := context.Background()
+
+	list, err := api.GetCurrencyList(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, list, 3)
+
+	pairOrders, err := api.GetOrders(ctx, "BTC_USD", "BTC_EUR")
+	assert.Nil(t, err)
+	assert.Len(t, pairOrders, 1)
+	assert.True(t, pairOrders.Exists("BTC_USD"))
+
+}
@@ -7,8 +7,13 @@ import (
 	"net/http/httptest"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"github.com/tusupov/exmoarbitrage/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 )
 
 func newTestClient() (*httptest.Server, *http.Client, string) {
@@ -34,6 +39,13 @@ func newTestClient() (*httptest.Server, *http.Client, string) {
 				return pairList[i] > pairList[j]
 			})
 
+			// Exmo errors come with status 200
+			if len(pairList) == 1 && pairList[0] == "BTC_ERR" {
+				w.WriteHeader(http.StatusOK)
+				w.Write([]byte(`{"result":false,"error":"Error 40015: API function do not exist"}`))
+				return
+			}
+
 			if len(pairList) == 3 && assert.ObjectsAreEqual(pairList, []string{"BTC_USD", "BTC_RUB", "BTC_EUR"}) {
 				w.WriteHeader(http.StatusOK)
 				w.Write([]byte(`{"BTC_USD":{"ask_quantity":"322.85129185","ask_amount":"2506250.14134118","ask_top":"3681.40738965","bid_quantity":"11732.67600564","bid_amount":"579061.75794034","bid_top":"3670.00211426","ask":[["3681.40738965","0.0230784","84.9609923"],["3764.996235","0.0013876","5.22430877"]],"bid":[["3670.00211426","0.03715396","136.35511175"],["3618.201094","0.01","36.18201094"]]},"BTC_EUR":{"ask_quantity":"97.51416943","ask_amount":"511716.00372549","ask_top":"3286.27812155","bid_quantity":"123.61959283","bid_amount":"281835.26698855","bid_top":"3267.85080247","ask":[["3286.27812155","0.01787873","58.75447924"],["3749.59155","0.0011","4.1245507"]],"bid":[["3267.85080247","1.1883","3883.18710857"],["3224.91052006","0.01","32.2491052"]]},"BTC_RUB":{"ask_quantity":"157.70795936","ask_amount":"66710379.06824972","ask_top":"246638.52016029","bid_quantity":"5113086.66286215","bid_amount":"24941065.74845995","bid_top":"245953","ask":[["246638.52016029","0.27315","67369.31178178"],["255130","0.34685","88491.8405"]],"bid":[["245953","0.0034964","859.9500692"],["237700","0.50783","120711.191"]]}}`))
@@ -59,11 +71,31 @@ func TestExmo_GetCurrencyList(t *testing.T) {
 	api := NewExmo(baseUrl, client)
 
 	ctx := context.Background()
+	hits, misses := testutil.ToFloat64(exmoCacheHits.WithLabelValues("currency")), testutil.ToFloat64(exmoCacheMisses.WithLabelValues("currency"))
+	requests := sampleCount(exmoRequestDuration.WithLabelValues("currency"))
+
 	list, err := api.GetCurrencyList(ctx)
 
 	assert.Nil(t, err)
 	assert.ElementsMatch(t, list, []model.Currency{"USD", "EUR", "RUB"})
 
+	// Second call is served from cache
+	_, err = api.GetCurrencyList(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, hits+1, testutil.ToFloat64(exmoCacheHits.WithLabelValues("currency")))
+	assert.Equal(t, misses+1, testutil.ToFloat64(exmoCacheMisses.WithLabelValues("currency")))
+	assert.Equal(t, requests+1, sampleCount(exmoRequestDuration.WithLabelValues("currency")))
+
+}
+
+// Observations of a histogram series
+func sampleCount(observer prometheus.Observer) uint64 {
+
+	var metric dto.Metric
+	observer.(prometheus.Metric).Write(&metric)
+
+	return metric.GetHistogram().GetSampleCount()
+
 }
 
 func TestExmo_GetPairList(t *testing.T) {
@@ -87,6 +119,30 @@ func TestExmo_GetPairList(t *testing.T) {
 
 }
 
+// Adapter is shared by several consumers, run with -race
+func TestExmo_Concurrent(t *testing.T) {
+
+	server, client, baseUrl := newTestClient()
+	defer server.Close()
+
+	api := NewExmo(baseUrl, client)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errCurrency := api.GetCurrencyList(ctx)
+			_, errPairs := api.GetPairList(ctx)
+			assert.Nil(t, errCurrency)
+			assert.Nil(t, errPairs)
+		}()
+	}
+	wg.Wait()
+
+}
+
 func TestExmo_GetOrders(t *testing.T) {
 
 	server, client, baseUrl := newTestClient()
@@ -103,8 +159,53 @@ func TestExmo_GetOrders(t *testing.T) {
 		assert.True(t, pairOrders.Exists(pair))
 	}
 
+	order, _ := pairOrders.GetOrder("BTC_USD")
+	assert.Equal(t, model.Offer{Price: 3681.40738965, Quantity: 0.0230784, Amount: 84.9609923}, order.Ask)
+	assert.Equal(t, model.Offer{Price: 3670.00211426, Quantity: 0.03715396, Amount: 136.35511175}, order.Bid)
+	assert.Equal(t, []model.Offer{
+		{Price: 3681.40738965, Quantity: 0.0230784, Amount: 84.9609923},
+		{Price: 3764.996235, Quantity: 0.0013876, Amount: 5.22430877},
+	}, order.Asks)
+	assert.Equal(t, []model.Offer{
+		{Price: 3670.00211426, Quantity: 0.03715396, Amount: 136.35511175},
+		{Price: 3618.201094, Quantity: 0.01, Amount: 36.18201094},
+	}, order.Bids)
+
 	pairList = append(pairList, model.Pair("FAKE_PAIR"))
 	_, err = api.GetOrders(ctx, pairList...)
 	assert.NotNil(t, err)
 
+	// Error result is counted as a failed request
+	errors := testutil.ToFloat64(exmoRequestErrors.WithLabelValues("order_book"))
+	_, err = api.GetOrders(ctx, "BTC_ERR")
+	assert.Equal(t, &ExmoError{Code: 40015, Message: "API function do not exist"}, err)
+	assert.Equal(t, errors+1, testutil.ToFloat64(exmoRequestErrors.WithLabelValues("order_book")))
+
+}
+
+func TestExmo_SetOrderDepth(t *testing.T) {
+
+	var limit string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit = r.URL.Query().Get("limit")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	api := NewExmo(server.URL, server.Client())
+	ctx := context.Background()
+
+	_, err := api.GetOrders(ctx, "BTC_USD")
+	assert.Nil(t, err)
+	assert.Equal(t, "100", limit)
+
+	assert.Equal(t, ErrOrderDepth, api.SetOrderDepth(0))
+	assert.Equal(t, ErrOrderDepth, api.SetOrderDepth(MaxOrderDepth+1))
+	assert.Nil(t, api.SetOrderDepth(MaxOrderDepth))
+
+	_, err = api.GetOrders(ctx, "BTC_USD")
+	assert.Nil(t, err)
+	assert.Equal(t, "1000", limit)
+
 }
@@ -0,0 +1,382 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Max trades per user_trades request
+const exmoTradesLimit = 10000
+
+var (
+	ErrTradeKeyEmpty     = errors.New("Ключ и секрет API не заданы")
+	ErrTradeAuth         = errors.New("Ошибка авторизации API")
+	ErrTradeNonce        = errors.New("Неверный nonce запроса")
+	ErrInsufficientFunds = errors.New("Недостаточно средств")
+	ErrOrderNotFound     = errors.New("Ордер не найден")
+)
+
+// Exmo error codes mapped to errors callers can compare with
+var exmoErrors = map[int]error{
+	40005: ErrTradeAuth,
+	40009: ErrTradeNonce,
+	40017: ErrTradeAuth,
+	50052: ErrInsufficientFunds,
+	50304: ErrOrderNotFound,
+}
+
+// "Error 50052: Insufficient funds"
+var exmoErrorFormat = regexp.MustCompile(`^Error (\d+):\s*(.*)$`)
+
+// Exmo error without mapping
+type ExmoError struct {
+	Code    int
+	Message string
+}
+
+func (e *ExmoError) Error() string {
+	return fmt.Sprintf("exmo: error %d: %s", e.Code, e.Message)
+}
+
+// Exmo signed API client
+type exmoTrader struct {
+	baseUrl string
+	client  *http.Client
+
+	key    string
+	secret string
+
+	mu    sync.Mutex
+	nonce int64
+}
+
+func NewExmoTrader(baseUrl string, client *http.Client, key, secret string) *exmoTrader {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &exmoTrader{
+		baseUrl: baseUrl,
+		client:  client,
+		key:     key,
+		secret:  secret,
+	}
+}
+
+// Account balances
+func (e *exmoTrader) GetUserInfo(ctx context.Context) (info model.UserInfo, err error) {
+
+	var body struct {
+		Uid        exmoNumber            `json:"uid"`
+		ServerDate exmoNumber            `json:"server_date"`
+		Balances   map[string]exmoNumber `json:"balances"`
+		Reserved   map[string]exmoNumber `json:"reserved"`
+	}
+
+	if err = e.post(ctx, "user_info", url.Values{}, &body); err != nil {
+		return
+	}
+
+	info = model.UserInfo{
+		UserId:     int64(body.Uid),
+		ServerTime: time.Unix(int64(body.ServerDate), 0),
+		Balances:   currencyAmounts(body.Balances),
+		Reserved:   currencyAmounts(body.Reserved),
+	}
+
+	return
+
+}
+
+// Place order, returns exchange order id
+func (e *exmoTrader) CreateOrder(ctx context.Context, order model.OrderRequest) (orderId int64, err error) {
+
+	params := url.Values{}
+	params.Set("pair", string(order.Pair))
+	params.Set("type", string(order.Type))
+	params.Set("quantity", formatNumber(order.Quantity))
+	params.Set("price", formatNumber(order.Price))
+
+	var body struct {
+		OrderId exmoNumber `json:"order_id"`
+	}
+
+	if err = e.post(ctx, "order_create", params, &body); err != nil {
+		return
+	}
+
+	orderId = int64(body.OrderId)
+
+	return
+
+}
+
+func (e *exmoTrader) CancelOrder(ctx context.Context, orderId int64) error {
+
+	params := url.Values{}
+	params.Set("order_id", strconv.FormatInt(orderId, 10))
+
+	return e.post(ctx, "order_cancel", params, nil)
+
+}
+
+// Open orders of all pairs, oldest first
+func (e *exmoTrader) GetOpenOrders(ctx context.Context) (orders []model.OpenOrder, err error) {
+
+	var body map[model.Pair][]struct {
+		OrderId  exmoNumber      `json:"order_id"`
+		Created  exmoNumber      `json:"created"`
+		Type     model.OrderType `json:"type"`
+		Pair     model.Pair      `json:"pair"`
+		Price    exmoNumber      `json:"price"`
+		Quantity exmoNumber      `json:"quantity"`
+		Amount   exmoNumber      `json:"amount"`
+	}
+
+	if err = e.post(ctx, "user_open_orders", url.Values{}, &body); err != nil {
+		return
+	}
+
+	orders = []model.OpenOrder{}
+	for _, list := range body {
+		for _, item := range list {
+			orders = append(orders, model.OpenOrder{
+				OrderId:  int64(item.OrderId),
+				Created:  time.Unix(int64(item.Created), 0),
+				Type:     item.Type,
+				Pair:     item.Pair,
+				Price:    float64(item.Price),
+				Quantity: float64(item.Quantity),
+				Amount:   float64(item.Amount),
+			})
+		}
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].OrderId < orders[j].OrderId
+	})
+
+	return
+
+}
+
+// Latest trades of pairs, oldest first
+func (e *exmoTrader) GetTrades(ctx context.Context, pairs ...model.Pair) (trades []model.Trade, err error) {
+
+	if len(pairs) == 0 {
+		err = ErrPairMustNotEmpty
+		return
+	}
+
+	pairParam := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		pairParam = append(pairParam, string(pair))
+	}
+
+	params := url.Values{}
+	params.Set("pair", strings.Join(pairParam, ","))
+	params.Set("limit", strconv.Itoa(exmoTradesLimit))
+
+	var body map[model.Pair][]struct {
+		TradeId  exmoNumber      `json:"trade_id"`
+		Date     exmoNumber      `json:"date"`
+		Type     model.OrderType `json:"type"`
+		Pair     model.Pair      `json:"pair"`
+		OrderId  exmoNumber      `json:"order_id"`
+		Price    exmoNumber      `json:"price"`
+		Quantity exmoNumber      `json:"quantity"`
+		Amount   exmoNumber      `json:"amount"`
+	}
+
+	if err = e.post(ctx, "user_trades", params, &body); err != nil {
+		return
+	}
+
+	trades = []model.Trade{}
+	for _, list := range body {
+		for _, item := range list {
+			trades = append(trades, model.Trade{
+				TradeId:  int64(item.TradeId),
+				Date:     time.Unix(int64(item.Date), 0),
+				Type:     item.Type,
+				Pair:     item.Pair,
+				OrderId:  int64(item.OrderId),
+				Price:    float64(item.Price),
+				Quantity: float64(item.Quantity),
+				Amount:   float64(item.Amount),
+			})
+		}
+	}
+
+	sort.Slice(trades, func(i, j int) bool {
+		return trades[i].TradeId < trades[j].TradeId
+	})
+
+	return
+
+}
+
+// Signed POST request, result is decoded into v if it is not nil
+func (e *exmoTrader) post(ctx context.Context, method string, params url.Values, v interface{}) (err error) {
+
+	if e.key == "" || e.secret == "" {
+		return ErrTradeKeyEmpty
+	}
+
+	params.Set("nonce", strconv.FormatInt(e.nextNonce(), 10))
+	body := params.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, e.baseUrl+"/"+method+"/", strings.NewReader(body))
+	if err != nil {
+		return
+	}
+
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Key", e.key)
+	req.Header.Set("Sign", e.sign(body))
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		exmoRequestErrors.WithLabelValues(method).Inc()
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		exmoRequestErrors.WithLabelValues(method).Inc()
+		return fmt.Errorf("exmo: %s: %s", method, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+
+	if err = exmoStatus(data); err != nil {
+		exmoRequestErrors.WithLabelValues(method).Inc()
+		return
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.Unmarshal(data, v)
+
+}
+
+// HMAC-SHA512 of the request body
+func (e *exmoTrader) sign(body string) string {
+	mac := hmac.New(sha512.New, []byte(e.secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Exmo rejects nonce not greater than the previous one
+func (e *exmoTrader) nextNonce() int64 {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	nonce := time.Now().UnixNano() / int64(time.Millisecond)
+	if nonce <= e.nonce {
+		nonce = e.nonce + 1
+	}
+	e.nonce = nonce
+
+	return nonce
+
+}
+
+// Error of a response answered with status 200,
+// user_info and lists have no result field
+func exmoStatus(data []byte) error {
+
+	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return nil
+	}
+
+	var status struct {
+		Result *bool  `json:"result"`
+		Error  string `json:"error"`
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return err
+	}
+
+	if status.Error != "" || (status.Result != nil && !*status.Result) {
+		return exmoError(status.Error)
+	}
+
+	return nil
+
+}
+
+func exmoError(message string) error {
+
+	match := exmoErrorFormat.FindStringSubmatch(message)
+	if match == nil {
+		return &ExmoError{Message: message}
+	}
+
+	code, _ := strconv.Atoi(match[1])
+	if err, ok := exmoErrors[code]; ok {
+		return err
+	}
+
+	return &ExmoError{
+		Code:    code,
+		Message: match[2],
+	}
+
+}
+
+// Exmo number, sent either as a string or as a json number
+type exmoNumber float64
+
+func (n *exmoNumber) UnmarshalJSON(data []byte) error {
+
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*n = 0
+		return nil
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	*n = exmoNumber(v)
+
+	return nil
+
+}
+
+func currencyAmounts(amounts map[string]exmoNumber) map[model.Currency]float64 {
+	result := make(map[model.Currency]float64, len(amounts))
+	for currency, amount := range amounts {
+		result[model.Currency(currency)] = float64(amount)
+	}
+	return result
+}
+
+func formatNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+const (
+	testTradeKey    = "K-test"
+	testTradeSecret = "S-test"
+)
+
+// Signed API stand-in, checks signature and nonce of every request
+func newTestTradeClient(t *testing.T) (*httptest.Server, *http.Client, string) {
+
+	var lastNonce int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		body, _ := ioutil.ReadAll(r.Body)
+
+		mac := hmac.New(sha512.New, []byte(testTradeSecret))
+		mac.Write(body)
+
+		if r.Method != http.MethodPost || r.Header.Get("Key") != testTradeKey ||
+			r.Header.Get("Sign") != hex.EncodeToString(mac.Sum(nil)) {
+			w.Write([]byte(`{"result":false,"error":"Error 40005: Authorization error, incorrect signature"}`))
+			return
+		}
+
+		params, _ := url.ParseQuery(string(body))
+		nonce, _ := strconv.ParseInt(params.Get("nonce"), 10, 64)
+		assert.True(t, nonce > lastNonce, "nonce must increase")
+		lastNonce = nonce
+
+		switch r.URL.Path {
+
+		case "/user_info/":
+			w.Write([]byte(`{"uid":10542,"server_date":1435518576,"balances":{"BTC":"970.994","USD":"949.47"},"reserved":{"BTC":"3","USD":"0.5"}}`))
+			return
+
+		case "/order_create/":
+			if params.Get("pair") != "BTC_USD" || params.Get("type") != "buy" ||
+				params.Get("quantity") != "0.5" || params.Get("price") != "3600.25" {
+				w.Write([]byte(`{"result":false,"error":"Error 50277: Incorrect order parameters"}`))
+				return
+			}
+			w.Write([]byte(`{"result":true,"error":"","order_id":123456}`))
+			return
+
+		case "/order_cancel/":
+			if params.Get("order_id") != "123456" {
+				w.Write([]byte(`{"result":false,"error":"Error 50304: Order was not found"}`))
+				return
+			}
+			w.Write([]byte(`{"result":true,"error":""}`))
+			return
+
+		case "/user_open_orders/":
+			w.Write([]byte(`{"BTC_USD":[{"order_id":"15","created":"1435517311","type":"sell","pair":"BTC_USD","price":"3700","quantity":"1","amount":"3700"}],"BTC_EUR":[{"order_id":"14","created":"1435517300","type":"buy","pair":"BTC_EUR","price":"3200","quantity":"0.5","amount":"1600"}]}`))
+			return
+
+		case "/user_trades/":
+			if params.Get("pair") != "BTC_USD,BTC_EUR" {
+				w.Write([]byte(`{}`))
+				return
+			}
+			w.Write([]byte(`{"BTC_USD":[{"trade_id":4,"date":1435488248,"type":"buy","pair":"BTC_USD","order_id":7,"quantity":1,"price":100,"amount":100}],"BTC_EUR":[{"trade_id":3,"date":1435488200,"type":"sell","pair":"BTC_EUR","order_id":6,"quantity":"2","price":"90","amount":"180"}]}`))
+			return
+
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+
+	}))
+
+	return server, server.Client(), server.URL
+
+}
+
+func TestExmoTrader_GetUserInfo(t *testing.T) {
+
+	server, client, baseUrl := newTestTradeClient(t)
+	defer server.Close()
+
+	trader := NewExmoTrader(baseUrl, client, testTradeKey, testTradeSecret)
+
+	info, err := trader.GetUserInfo(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, int64(10542), info.UserId)
+	assert.Equal(t, time.Unix(1435518576, 0), info.ServerTime)
+	assert.Equal(t, map[model.Currency]float64{"BTC": 970.994, "USD": 949.47}, info.Balances)
+	assert.Equal(t, map[model.Currency]float64{"BTC": 3, "USD": 0.5}, info.Reserved)
+
+}
+
+func TestExmoTrader_CreateOrder(t *testing.T) {
+
+	server, client, baseUrl := newTestTradeClient(t)
+	defer server.Close()
+
+	trader := NewExmoTrader(baseUrl, client, testTradeKey, testTradeSecret)
+	ctx := context.Background()
+
+	orderId, err := trader.CreateOrder(ctx, model.OrderRequest{
+		Pair:     "BTC_USD",
+		Type:     model.OrderBuy,
+		Quantity: 0.5,
+		Price:    3600.25,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(123456), orderId)
+
+	// Unmapped exchange error
+	_, err = trader.CreateOrder(ctx, model.OrderRequest{
+		Pair:     "BTC_USD",
+		Type:     model.OrderSell,
+		Quantity: 0.5,
+		Price:    3600.25,
+	})
+	assert.Equal(t, &ExmoError{Code: 50277, Message: "Incorrect order parameters"}, err)
+
+}
+
+func TestExmoTrader_CancelOrder(t *testing.T) {
+
+	server, client, baseUrl := newTestTradeClient(t)
+	defer server.Close()
+
+	trader := NewExmoTrader(baseUrl, client, testTradeKey, testTradeSecret)
+	ctx := context.Background()
+
+	assert.Nil(t, trader.CancelOrder(ctx, 123456))
+	assert.Equal(t, ErrOrderNotFound, trader.CancelOrder(ctx, 1))
+
+}
+
+func TestExmoTrader_GetOpenOrders(t *testing.T) {
+
+	server, client, baseUrl := newTestTradeClient(t)
+	defer server.Close()
+
+	trader := NewExmoTrader(baseUrl, client, testTradeKey, testTradeSecret)
+
+	orders, err := trader.GetOpenOrders(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, []model.OpenOrder{
+		{OrderId: 14, Created: time.Unix(1435517300, 0), Type: model.OrderBuy, Pair: "BTC_EUR", Price: 3200, Quantity: 0.5, Amount: 1600},
+		{OrderId: 15, Created: time.Unix(1435517311, 0), Type: model.OrderSell, Pair: "BTC_USD", Price: 3700, Quantity: 1, Amount: 3700},
+	}, orders)
+
+}
+
+func TestExmoTrader_GetTrades(t *testing.T) {
+
+	server, client, baseUrl := newTestTradeClient(t)
+	defer server.Close()
+
+	trader := NewExmoTrader(baseUrl, client, testTradeKey, testTradeSecret)
+	ctx := context.Background()
+
+	trades, err := trader.GetTrades(ctx, "BTC_USD", "BTC_EUR")
+	assert.Nil(t, err)
+	assert.Equal(t, []model.Trade{
+		{TradeId: 3, Date: time.Unix(1435488200, 0), Type: model.OrderSell, Pair: "BTC_EUR", OrderId: 6, Price: 90, Quantity: 2, Amount: 180},
+		{TradeId: 4, Date: time.Unix(1435488248, 0), Type: model.OrderBuy, Pair: "BTC_USD", OrderId: 7, Price: 100, Quantity: 1, Amount: 100},
+	}, trades)
+
+	_, err = trader.GetTrades(ctx)
+	assert.Equal(t, ErrPairMustNotEmpty, err)
+
+}
+
+func TestExmoTrader_Auth(t *testing.T) {
+
+	server, client, baseUrl := newTestTradeClient(t)
+	defer server.Close()
+
+	ctx := context.Background()
+
+	_, err := NewExmoTrader(baseUrl, client, testTradeKey, "wrong").GetUserInfo(ctx)
+	assert.Equal(t, ErrTradeAuth, err)
+
+	_, err = NewExmoTrader(baseUrl, client, "", "").GetUserInfo(ctx)
+	assert.Equal(t, ErrTradeKeyEmpty, err)
+
+}
+
+func TestExmoError(t *testing.T) {
+
+	assert.Equal(t, ErrInsufficientFunds, exmoError("Error 50052: Insufficient funds"))
+	assert.Equal(t, ErrTradeNonce, exmoError("Error 40009: The nonce parameter is less or equal than what was used before"))
+	assert.Equal(t, &ExmoError{Code: 40016, Message: "Maintenance work in progress"}, exmoError("Error 40016: Maintenance work in progress"))
+	assert.Equal(t, &ExmoError{Message: "unknown"}, exmoError("unknown"))
+
+}
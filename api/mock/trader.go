@@ -0,0 +1,40 @@
+package mock
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+type trader struct {
+	mock.Mock
+}
+
+func NewTrader() *trader {
+	return &trader{}
+}
+
+func (m *trader) GetUserInfo(ctx context.Context) (model.UserInfo, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(model.UserInfo), args.Error(1)
+}
+
+func (m *trader) CreateOrder(ctx context.Context, order model.OrderRequest) (int64, error) {
+	args := m.Called(ctx, order)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *trader) CancelOrder(ctx context.Context, orderId int64) error {
+	args := m.Called(ctx, orderId)
+	return args.Error(0)
+}
+
+func (m *trader) GetOpenOrders(ctx context.Context) ([]model.OpenOrder, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]model.OpenOrder), args.Error(1)
+}
+
+func (m *trader) GetTrades(ctx context.Context, pairs ...model.Pair) ([]model.Trade, error) {
+	args := m.Called(ctx, pairs)
+	return args.Get(0).([]model.Trade), args.Error(1)
+}
@@ -0,0 +1,494 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Filled quantity precision of simulated orders
+const paperEPS = 1e-12
+
+var ErrPaperOrder = errors.New("Неверные параметры ордера")
+
+// Simulated exchange: virtual balances, orders are matched
+// against the latest order books of the market api
+type paperTrader struct {
+	market  Apier
+	fee     model.FeeSettings
+	latency time.Duration
+
+	mu       sync.Mutex
+	balances map[model.Currency]float64
+	reserved map[model.Currency]float64
+	orders   []*paperOrder
+	trades   []model.Trade
+	lastId   int64
+}
+
+// Open limit order with its unfilled part
+type paperOrder struct {
+	model.OpenOrder
+	remain  float64 // unfilled quantity, quote amount for *_total orders
+	reserve float64 // funds held for the unfilled part
+
+	// Book quantity already filled by the order per price, the same
+	// liquidity is not matched twice while the book snapshot is not updated
+	taken   map[float64]float64
+	takenAt time.Time // book snapshot time of taken
+}
+
+func NewPaperTrader(market Apier, balances map[model.Currency]float64) *paperTrader {
+
+	p := &paperTrader{
+		market:   market,
+		balances: make(map[model.Currency]float64, len(balances)),
+		reserved: make(map[model.Currency]float64),
+	}
+
+	for currency, amount := range balances {
+		p.balances[currency] = amount
+	}
+
+	return p
+
+}
+
+// Set commission, taker for matched on placement, maker for matched later
+func (p *paperTrader) SetFee(fee model.FeeSettings) {
+	p.fee = fee
+}
+
+// Set delay of every request, as the exchange round trip
+func (p *paperTrader) SetLatency(latency time.Duration) {
+	p.latency = latency
+}
+
+func (p *paperTrader) GetUserInfo(ctx context.Context) (info model.UserInfo, err error) {
+
+	if err = p.delay(ctx); err != nil {
+		return
+	}
+
+	p.matchOpen(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info = model.UserInfo{
+		ServerTime: time.Now(),
+		Balances:   copyAmounts(p.balances),
+		Reserved:   copyAmounts(p.reserved),
+	}
+
+	return
+
+}
+
+// Match order against the book, the rest of a limit order stays open,
+// the rest of a market order is cancelled
+func (p *paperTrader) CreateOrder(ctx context.Context, req model.OrderRequest) (orderId int64, err error) {
+
+	if err = p.delay(ctx); err != nil {
+		return
+	}
+
+	base, quote, ok := req.Pair.Split()
+	if !ok || req.Quantity <= 0 || (isLimitOrder(req.Type) && req.Price <= 0) {
+		return 0, ErrPaperOrder
+	}
+
+	pairOrders, err := p.market.GetOrders(ctx, req.Pair)
+	if err != nil {
+		return
+	}
+
+	book, ok := pairOrders.GetOrder(req.Pair)
+	if !ok {
+		return 0, ErrPaperOrder
+	}
+	bookTime := p.bookTime()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Funds of limit order are reserved for the full quantity
+	need, currency := req.Quantity, base
+	if req.Type == model.OrderBuy {
+		need, currency = req.Quantity*req.Price, quote
+	}
+
+	if isLimitOrder(req.Type) {
+		if p.balances[currency] < need-paperEPS {
+			return 0, ErrInsufficientFunds
+		}
+		p.balances[currency] -= need
+		p.reserved[currency] += need
+	}
+
+	p.lastId++
+	order := &paperOrder{
+		OpenOrder: model.OpenOrder{
+			OrderId:  p.lastId,
+			Created:  time.Now(),
+			Type:     req.Type,
+			Pair:     req.Pair,
+			Price:    req.Price,
+			Quantity: req.Quantity,
+			Amount:   req.Quantity * req.Price,
+		},
+		remain: req.Quantity,
+		taken:  map[float64]float64{},
+	}
+
+	if isLimitOrder(req.Type) {
+		order.reserve = need
+	}
+
+	if err = p.match(order, book, bookTime, p.fee.GetFee(req.Pair).Taker); err != nil {
+		return 0, err
+	}
+
+	if isLimitOrder(req.Type) && order.remain > paperEPS {
+		p.orders = append(p.orders, order)
+	}
+
+	return order.OrderId, nil
+
+}
+
+func (p *paperTrader) CancelOrder(ctx context.Context, orderId int64) error {
+
+	if err := p.delay(ctx); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for i, order := range p.orders {
+		if order.OrderId == orderId {
+			p.release(order)
+			p.orders = append(p.orders[:i], p.orders[i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrOrderNotFound
+
+}
+
+func (p *paperTrader) GetOpenOrders(ctx context.Context) (orders []model.OpenOrder, err error) {
+
+	if err = p.delay(ctx); err != nil {
+		return
+	}
+
+	p.matchOpen(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	orders = make([]model.OpenOrder, 0, len(p.orders))
+	for _, order := range p.orders {
+		open := order.OpenOrder
+		open.Quantity = order.remain
+		open.Amount = order.remain * order.Price
+		orders = append(orders, open)
+	}
+
+	return
+
+}
+
+func (p *paperTrader) GetTrades(ctx context.Context, pairs ...model.Pair) (trades []model.Trade, err error) {
+
+	if len(pairs) == 0 {
+		err = ErrPairMustNotEmpty
+		return
+	}
+
+	if err = p.delay(ctx); err != nil {
+		return
+	}
+
+	p.matchOpen(ctx)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	trades = []model.Trade{}
+	for _, trade := range p.trades {
+		for _, pair := range pairs {
+			if trade.Pair == pair {
+				trades = append(trades, trade)
+				break
+			}
+		}
+	}
+
+	return
+
+}
+
+// All fills, oldest first
+func (p *paperTrader) Ledger() []model.Trade {
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return append([]model.Trade(nil), p.trades...)
+
+}
+
+// Fill open limit orders which the current books cross, at maker fee
+func (p *paperTrader) matchOpen(ctx context.Context) {
+
+	p.mu.Lock()
+	pairs := make([]model.Pair, 0, len(p.orders))
+	for _, order := range p.orders {
+		pairs = append(pairs, order.Pair)
+	}
+	p.mu.Unlock()
+
+	if len(pairs) == 0 {
+		return
+	}
+
+	pairOrders, err := p.market.GetOrders(ctx, pairs...)
+	if err != nil {
+		return
+	}
+	bookTime := p.bookTime()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	open := p.orders[:0]
+	for _, order := range p.orders {
+
+		if book, ok := pairOrders.GetOrder(order.Pair); ok {
+			p.match(order, book, bookTime, p.fee.GetFee(order.Pair).Maker)
+		}
+
+		if order.remain > paperEPS {
+			open = append(open, order)
+		}
+
+	}
+	p.orders = open
+
+}
+
+// Walk the opposite book side and settle fills, fee is taken from the received currency
+func (p *paperTrader) match(order *paperOrder, book model.Order, bookTime time.Time, fee float64) error {
+
+	base, quote, _ := order.Pair.Split()
+
+	// Liquidity of a changed book is not taken yet
+	if !bookTime.Equal(order.takenAt) {
+		order.taken = map[float64]float64{}
+		order.takenAt = bookTime
+	}
+
+	sell := order.Type == model.OrderSell || order.Type == model.OrderMarketSell || order.Type == model.OrderMarketSellTotal
+	offers := book.Asks
+	if sell {
+		offers = book.Bids
+	}
+
+	// Market orders spend balance directly, check it on the whole fill
+	if !isLimitOrder(order.Type) {
+		currency := quote
+		if sell {
+			currency = base
+		}
+		if p.balances[currency] < paperCost(order, offers)-paperEPS {
+			return ErrInsufficientFunds
+		}
+	}
+
+	for _, offer := range offers {
+
+		if order.remain <= paperEPS {
+			break
+		}
+
+		if isLimitOrder(order.Type) && ((sell && offer.Price < order.Price) || (!sell && offer.Price > order.Price)) {
+			break
+		}
+
+		available := offer.Quantity - order.taken[offer.Price]
+		if available <= paperEPS {
+			continue
+		}
+
+		quantity := math.Min(available, order.remain)
+		switch order.Type {
+		case model.OrderMarketBuyTotal, model.OrderMarketSellTotal:
+			// Remain is quote amount
+			quantity = math.Min(available, order.remain/offer.Price)
+			order.remain -= quantity * offer.Price
+		default:
+			order.remain -= quantity
+		}
+
+		if quantity <= 0 {
+			continue
+		}
+
+		order.taken[offer.Price] += quantity
+		amount := quantity * offer.Price
+
+		if sell {
+			p.settle(order, base, quantity, quote, amount*(1-fee))
+		} else {
+			p.settle(order, quote, amount, base, quantity*(1-fee))
+		}
+
+		tradeType := model.OrderBuy
+		if sell {
+			tradeType = model.OrderSell
+		}
+
+		p.trades = append(p.trades, model.Trade{
+			TradeId:  int64(len(p.trades) + 1),
+			Date:     time.Now(),
+			Type:     tradeType,
+			Pair:     order.Pair,
+			OrderId:  order.OrderId,
+			Price:    offer.Price,
+			Quantity: quantity,
+			Amount:   amount,
+		})
+
+	}
+
+	if order.remain < paperEPS {
+		order.remain = 0
+	}
+
+	// Buy at a better price than the limit leaves a part of the reserve
+	if order.remain == 0 {
+		p.release(order)
+	}
+
+	return nil
+
+}
+
+// Move spent funds out of reserve or balance and add the received ones
+func (p *paperTrader) settle(order *paperOrder, spentCurrency model.Currency, spent float64, receivedCurrency model.Currency, received float64) {
+
+	if isLimitOrder(order.Type) {
+		p.reserved[spentCurrency] -= spent
+		order.reserve -= spent
+	} else {
+		p.balances[spentCurrency] -= spent
+	}
+
+	p.balances[receivedCurrency] += received
+
+}
+
+// Return reserve of the unfilled part to balance
+func (p *paperTrader) release(order *paperOrder) {
+
+	base, quote, _ := order.Pair.Split()
+
+	currency := base
+	if order.Type == model.OrderBuy {
+		currency = quote
+	}
+
+	p.reserved[currency] -= order.reserve
+	p.balances[currency] += order.reserve
+	order.reserve = 0
+
+}
+
+// Time of the market books snapshot: the clock of a replay,
+// the last update of a stream, now for requested books
+func (p *paperTrader) bookTime() time.Time {
+
+	if clock, ok := p.market.(Clock); ok {
+		return clock.Now()
+	}
+
+	if updater, ok := p.market.(Updater); ok {
+		return updater.Updated()
+	}
+
+	return time.Now()
+
+}
+
+func (p *paperTrader) delay(ctx context.Context) error {
+
+	if p.latency <= 0 {
+		return ctx.Err()
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(p.latency):
+		return nil
+	}
+
+}
+
+// Amount of the spent currency a market order takes from the book
+func paperCost(order *paperOrder, offers []model.Offer) (spend float64) {
+
+	remain := order.remain
+	for _, offer := range offers {
+
+		if remain <= paperEPS {
+			break
+		}
+
+		switch order.Type {
+		case model.OrderMarketBuy:
+			quantity := math.Min(offer.Quantity, remain)
+			spend += quantity * offer.Price
+			remain -= quantity
+		case model.OrderMarketSell:
+			quantity := math.Min(offer.Quantity, remain)
+			spend += quantity
+			remain -= quantity
+		case model.OrderMarketBuyTotal:
+			amount := math.Min(offer.Quantity*offer.Price, remain)
+			spend += amount
+			remain -= amount
+		case model.OrderMarketSellTotal:
+			amount := math.Min(offer.Quantity*offer.Price, remain)
+			spend += amount / offer.Price
+			remain -= amount
+		}
+
+	}
+
+	return
+
+}
+
+func isLimitOrder(orderType model.OrderType) bool {
+	return orderType == model.OrderBuy || orderType == model.OrderSell
+}
+
+func copyAmounts(amounts map[model.Currency]float64) map[model.Currency]float64 {
+
+	result := make(map[model.Currency]float64, len(amounts))
+	for currency, amount := range amounts {
+		if math.Abs(amount) > paperEPS {
+			result[currency] = amount
+		}
+	}
+
+	return result
+
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Market with order books set by the test
+type testMarket struct {
+	pairOrders model.PairOrders
+	updated    time.Time
+}
+
+func (m *testMarket) GetCurrencyList(ctx context.Context) ([]model.Currency, error) {
+	return []model.Currency{"BTC", "USD"}, nil
+}
+
+func (m *testMarket) GetPairList(ctx context.Context) (model.PairSettings, error) {
+	return model.PairSettings{"BTC_USD": model.Setting{}}, nil
+}
+
+func (m *testMarket) GetOrders(ctx context.Context, pairs ...model.Pair) (model.PairOrders, error) {
+	return m.pairOrders, nil
+}
+
+func (m *testMarket) Updated() time.Time {
+	return m.updated
+}
+
+func TestPaperTrader(t *testing.T) {
+
+	market := &testMarket{
+		pairOrders: model.PairOrders{
+			"BTC_USD": model.Order{
+				Asks: []model.Offer{{Price: 4000, Quantity: 0.5}, {Price: 4100, Quantity: 1}},
+				Bids: []model.Offer{{Price: 3900, Quantity: 1}},
+			},
+		},
+	}
+
+	trader := NewPaperTrader(market, map[model.Currency]float64{"USD": 10000})
+	trader.SetFee(model.FeeSettings{Fee: model.Fee{Taker: 0.002, Maker: 0.001}})
+	ctx := context.Background()
+
+	// Limit buy is filled up to its price, the rest stays open
+	orderId, err := trader.CreateOrder(ctx, model.OrderRequest{Pair: "BTC_USD", Type: model.OrderBuy, Quantity: 1, Price: 4050})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), orderId)
+
+	info, err := trader.GetUserInfo(ctx)
+	assert.Nil(t, err)
+	assert.InDelta(t, 5950, info.Balances["USD"], 1e-9)
+	assert.InDelta(t, 0.499, info.Balances["BTC"], 1e-9)
+	assert.InDelta(t, 2050, info.Reserved["USD"], 1e-9)
+
+	// Filled liquidity is not matched again
+	orders, err := trader.GetOpenOrders(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, orders, 1)
+	assert.InDelta(t, 0.5, orders[0].Quantity, 1e-9)
+
+	// Market sell of everything bought
+	_, err = trader.CreateOrder(ctx, model.OrderRequest{Pair: "BTC_USD", Type: model.OrderMarketSell, Quantity: 0.499})
+	assert.Nil(t, err)
+
+	assert.Nil(t, trader.CancelOrder(ctx, orderId))
+	assert.Equal(t, ErrOrderNotFound, trader.CancelOrder(ctx, orderId))
+
+	info, _ = trader.GetUserInfo(ctx)
+	assert.InDelta(t, 10000-2000+0.499*3900*0.998, info.Balances["USD"], 1e-9)
+	assert.InDelta(t, 0, info.Balances["BTC"], 1e-9)
+	assert.InDelta(t, 0, info.Reserved["USD"], 1e-9)
+
+	trades, err := trader.GetTrades(ctx, "BTC_USD")
+	assert.Nil(t, err)
+	assert.Equal(t, []model.Trade{
+		{TradeId: 1, Date: trades[0].Date, Type: model.OrderBuy, Pair: "BTC_USD", OrderId: 1, Price: 4000, Quantity: 0.5, Amount: 2000},
+		{TradeId: 2, Date: trades[1].Date, Type: model.OrderSell, Pair: "BTC_USD", OrderId: 2, Price: 3900, Quantity: 0.499, Amount: 0.499 * 3900},
+	}, trades)
+	assert.Equal(t, trades, trader.Ledger())
+
+	// Balance checks
+	_, err = trader.CreateOrder(ctx, model.OrderRequest{Pair: "BTC_USD", Type: model.OrderMarketSell, Quantity: 0.5})
+	assert.Equal(t, ErrInsufficientFunds, err)
+
+	_, err = trader.CreateOrder(ctx, model.OrderRequest{Pair: "BTC_USD", Type: model.OrderSell, Quantity: 1, Price: 3900})
+	assert.Equal(t, ErrInsufficientFunds, err)
+
+	_, err = trader.CreateOrder(ctx, model.OrderRequest{Pair: "BTCUSD", Type: model.OrderBuy, Quantity: 1, Price: 1})
+	assert.Equal(t, ErrPaperOrder, err)
+
+}
+
+func TestPaperTrader_resting(t *testing.T) {
+
+	market := &testMarket{
+		pairOrders: model.PairOrders{
+			"BTC_USD": model.Order{
+				Asks: []model.Offer{{Price: 4000, Quantity: 1}},
+				Bids: []model.Offer{{Price: 3900, Quantity: 1}},
+			},
+		},
+	}
+
+	trader := NewPaperTrader(market, map[model.Currency]float64{"USD": 1000})
+	trader.SetFee(model.FeeSettings{Fee: model.Fee{Taker: 0.002, Maker: 0.001}})
+	ctx := context.Background()
+
+	orderId, err := trader.CreateOrder(ctx, model.OrderRequest{Pair: "BTC_USD", Type: model.OrderBuy, Quantity: 0.2, Price: 3950})
+	assert.Nil(t, err)
+
+	orders, _ := trader.GetOpenOrders(ctx)
+	assert.Len(t, orders, 1)
+
+	// Book moves through the limit, order is filled at maker fee
+	market.pairOrders = model.PairOrders{
+		"BTC_USD": model.Order{
+			Asks: []model.Offer{{Price: 3940, Quantity: 1}},
+			Bids: []model.Offer{{Price: 3930, Quantity: 1}},
+		},
+	}
+
+	orders, _ = trader.GetOpenOrders(ctx)
+	assert.Empty(t, orders)
+
+	info, _ := trader.GetUserInfo(ctx)
+	assert.InDelta(t, 1000-0.2*3940, info.Balances["USD"], 1e-9)
+	assert.InDelta(t, 0.2*0.999, info.Balances["BTC"], 1e-9)
+	assert.Empty(t, info.Reserved)
+
+	assert.Equal(t, ErrOrderNotFound, trader.CancelOrder(ctx, orderId))
+
+}
+
+func TestPaperTrader_taken(t *testing.T) {
+
+	market := &testMarket{
+		pairOrders: model.PairOrders{
+			"BTC_USD": model.Order{
+				Asks: []model.Offer{{Price: 4000, Quantity: 1}},
+			},
+		},
+		updated: time.Now(),
+	}
+
+	trader := NewPaperTrader(market, map[model.Currency]float64{"USD": 8000})
+	ctx := context.Background()
+
+	_, err := trader.CreateOrder(ctx, model.OrderRequest{Pair: "BTC_USD", Type: model.OrderBuy, Quantity: 2, Price: 4000})
+	assert.Nil(t, err)
+
+	// Liquidity of the same book is taken already
+	orders, _ := trader.GetOpenOrders(ctx)
+	if assert.Len(t, orders, 1) {
+		info, _ := trader.GetUserInfo(ctx)
+		assert.InDelta(t, 1, info.Balances["BTC"], 1e-9)
+	}
+
+	// Updated book with the same offer is matched again
+	market.updated = market.updated.Add(time.Second)
+
+	orders, _ = trader.GetOpenOrders(ctx)
+	assert.Empty(t, orders)
+
+	info, _ := trader.GetUserInfo(ctx)
+	assert.InDelta(t, 2, info.Balances["BTC"], 1e-9)
+	assert.InDelta(t, 0, info.Balances["USD"], 1e-9)
+
+}
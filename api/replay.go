@@ -0,0 +1,195 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+var ErrReplayEmpty = errors.New("Нет записей для воспроизведения")
+
+// Market records in time order, io.EOF at the end
+type RecordReader interface {
+	Next() (model.MarketRecord, error)
+}
+
+// Serves recorded market records in time order, read as they are reached.
+// Reads return the same record until Step, called once per snapshot.
+// With speed > 0 Step moves to the record of the time passed since start
+// multiplied by speed: 1 - real time, 10 - ten times faster.
+// With speed 0 every Step moves to the next record.
+type replay struct {
+	records RecordReader
+	speed   float64
+
+	mu      sync.Mutex
+	start   time.Time
+	first   time.Time // time of the first record
+	current model.MarketRecord
+	next    *model.MarketRecord // nil at the end
+	err     error               // read error after the next record
+	failed  bool                // the read error is reached
+	started bool
+}
+
+func NewReplay(records RecordReader, speed float64) (*replay, error) {
+
+	first, err := records.Next()
+	if err == io.EOF {
+		return nil, ErrReplayEmpty
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r := &replay{
+		records: records,
+		speed:   speed,
+		first:   first.Time,
+		current: first,
+	}
+
+	if r.read(); r.next == nil && r.err != nil {
+		return nil, r.err
+	}
+
+	return r, nil
+
+}
+
+func (r *replay) GetCurrencyList(ctx context.Context) ([]model.Currency, error) {
+	record, err := r.record()
+	return record.Currencies, err
+}
+
+func (r *replay) GetPairList(ctx context.Context) (model.PairSettings, error) {
+	record, err := r.record()
+	return record.Pairs, err
+}
+
+func (r *replay) GetOrders(ctx context.Context, pairs ...model.Pair) (pairOrders model.PairOrders, err error) {
+
+	if len(pairs) == 0 {
+		err = ErrPairMustNotEmpty
+		return
+	}
+
+	record, err := r.record()
+	if err != nil {
+		return
+	}
+
+	pairOrders = make(model.PairOrders, len(pairs))
+	for _, pair := range pairs {
+		if order, ok := record.Orders.GetOrder(pair); ok {
+			pairOrders[pair] = order
+		}
+	}
+
+	return
+
+}
+
+// Time of the current record
+func (r *replay) Now() time.Time {
+	record, _ := r.record()
+	return record.Time
+}
+
+// Move to the next record or the record of the clock, false at the end
+func (r *replay) Step() bool {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.speed > 0 {
+		r.seek()
+		return !r.failed
+	}
+
+	if !r.started {
+		r.started = true
+		return true
+	}
+
+	return r.advance()
+
+}
+
+// Last record is reached
+func (r *replay) Finished() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.next == nil
+}
+
+// Current record, the same until Step
+func (r *replay) record() (model.MarketRecord, error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failed {
+		return r.current, r.err
+	}
+
+	return r.current, nil
+
+}
+
+// Move to the record of the clock, the clock starts with the first step
+func (r *replay) seek() {
+
+	if r.speed <= 0 {
+		return
+	}
+
+	if r.start.IsZero() {
+		r.start = time.Now()
+	}
+
+	elapsed := time.Duration(float64(time.Since(r.start)) * r.speed)
+	at := r.first.Add(elapsed)
+
+	// Last record not after at
+	for r.next != nil && !r.next.Time.After(at) {
+		r.advance()
+	}
+
+	r.failed = r.next == nil && r.err != nil
+
+}
+
+// Move to the read ahead record, false at the end or read error
+func (r *replay) advance() bool {
+
+	if r.next == nil {
+		r.failed = r.err != nil
+		return false
+	}
+
+	r.current = *r.next
+	r.read()
+
+	return true
+
+}
+
+// Read ahead the next record
+func (r *replay) read() {
+
+	record, err := r.records.Next()
+	if err != nil {
+		if err != io.EOF {
+			r.err = err
+		}
+		r.next = nil
+		return
+	}
+
+	r.next = &record
+
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Records of a slice, as read from files
+type testRecordReader struct {
+	records []model.MarketRecord
+	err     error // after the records, io.EOF if nil
+}
+
+func (r *testRecordReader) Next() (record model.MarketRecord, err error) {
+
+	if len(r.records) == 0 {
+		if err = r.err; err == nil {
+			err = io.EOF
+		}
+		return
+	}
+
+	record, r.records = r.records[0], r.records[1:]
+
+	return
+
+}
+
+func testReplayRecords() *testRecordReader {
+
+	start := time.Date(2019, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	records := make([]model.MarketRecord, 0, 3)
+	for i, price := range []float64{3700, 3710, 3720} {
+		records = append(records, model.MarketRecord{
+			Time:       start.Add(time.Duration(i) * 10 * time.Second),
+			Currencies: []model.Currency{"BTC", "USD"},
+			Pairs:      model.PairSettings{"BTC_USD": model.Setting{}},
+			Orders: model.PairOrders{
+				"BTC_USD": model.Order{Ask: model.Offer{Price: price}, Bid: model.Offer{Price: price - 10}},
+			},
+		})
+	}
+
+	return &testRecordReader{records: records}
+
+}
+
+func TestReplay_step(t *testing.T) {
+
+	replay, err := NewReplay(testReplayRecords(), 0)
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+
+	list, err := replay.GetCurrencyList(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, []model.Currency{"BTC", "USD"}, list)
+
+	for i, price := range []float64{3700, 3710, 3720, 3720} {
+
+		assert.Equal(t, i < 3, replay.Step())
+
+		// Reads do not move the replay
+		for j := 0; j < 2; j++ {
+			pairOrders, err := replay.GetOrders(ctx, "BTC_USD", "ETH_USD")
+			assert.Nil(t, err)
+			assert.Len(t, pairOrders, 1)
+			assert.Equal(t, price, pairOrders["BTC_USD"].Ask.Price)
+		}
+
+	}
+
+	assert.True(t, replay.Finished())
+	assert.Equal(t, time.Date(2019, 1, 10, 12, 0, 20, 0, time.UTC), replay.Now())
+
+	_, err = NewReplay(&testRecordReader{}, 1)
+	assert.Equal(t, ErrReplayEmpty, err)
+
+	// Read error stops the replay
+	records := testReplayRecords()
+	records.err = errors.New("unexpected EOF")
+	replay, err = NewReplay(records, 0)
+	assert.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		assert.True(t, replay.Step())
+		_, err = replay.GetOrders(ctx, "BTC_USD")
+		assert.Nil(t, err)
+	}
+	assert.False(t, replay.Step())
+	_, err = replay.GetOrders(ctx, "BTC_USD")
+	assert.EqualError(t, err, "unexpected EOF")
+
+}
+
+func TestReplay_speed(t *testing.T) {
+
+	replay, err := NewReplay(testReplayRecords(), 10)
+	assert.Nil(t, err)
+
+	// 1.5s at 10x is 15s of history
+	replay.start = time.Now().Add(-1500 * time.Millisecond)
+	assert.True(t, replay.Step())
+
+	pairOrders, err := replay.GetOrders(context.Background(), "BTC_USD")
+	assert.Nil(t, err)
+	assert.Equal(t, 3710.0, pairOrders["BTC_USD"].Ask.Price)
+	assert.Equal(t, time.Date(2019, 1, 10, 12, 0, 10, 0, time.UTC), replay.Now())
+	assert.False(t, replay.Finished())
+
+	// Clock moves with the step only
+	replay.start = time.Now().Add(-time.Hour)
+	assert.False(t, replay.Finished())
+	assert.True(t, replay.Step())
+	assert.True(t, replay.Finished())
+
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"context"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Order books kept in sync by a streaming connection
+type OrderStreamer interface {
+	Run(ctx context.Context, pairs ...model.Pair) error
+	GetOrders(ctx context.Context, pairs ...model.Pair) (model.PairOrders, error)
+	Updated() time.Time
+}
+
+// Apier with orders taken from a streamer
+type streamApi struct {
+	Apier
+	stream OrderStreamer
+}
+
+func NewStreamApi(api Apier, stream OrderStreamer) *streamApi {
+	return &streamApi{
+		Apier:  api,
+		stream: stream,
+	}
+}
+
+func (s *streamApi) GetOrders(ctx context.Context, pairs ...model.Pair) (model.PairOrders, error) {
+	return s.stream.GetOrders(ctx, pairs...)
+}
+
+func (s *streamApi) Updated() time.Time {
+	return s.stream.Updated()
+}
@@ -0,0 +1,15 @@
+package api
+
+import (
+	"context"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Private account and order methods
+type Trader interface {
+	GetUserInfo(ctx context.Context) (model.UserInfo, error)
+	CreateOrder(ctx context.Context, order model.OrderRequest) (int64, error)
+	CancelOrder(ctx context.Context, orderId int64) error
+	GetOpenOrders(ctx context.Context) ([]model.OpenOrder, error)
+	GetTrades(ctx context.Context, pairs ...model.Pair) ([]model.Trade, error)
+}
@@ -0,0 +1,262 @@
+package backtest
+
+import (
+	"io"
+	"sort"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+)
+
+// When and how much to trade
+type Strategy struct {
+	Base      model.Currency // route start and end currency, empty - any
+	MinProfit float64        // min net profit, percent
+	MaxLegs   int            // max route legs, 0 - any
+	MaxSize   float64        // max trade volume in route start currency, 0 - profitable order books depth
+	Cooldown  time.Duration  // pause after a trade
+	Latency   time.Duration  // delay from detection to execution
+}
+
+type Report struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Records int       `json:"records"`
+
+	Opportunities int           `json:"opportunities"` // profitable routes appeared
+	Duration      DurationStats `json:"duration"`      // how long opportunities lived
+
+	Trades  int     `json:"trades"`
+	Hits    int     `json:"hits"`     // trades still profitable after latency
+	HitRate float64 `json:"hit_rate"` // hits / trades
+
+	PnL        map[model.Currency]float64 `json:"pnl"`         // profit at detection, by start currency
+	LatencyPnL map[model.Currency]float64 `json:"latency_pnl"` // profit after latency, by start currency
+	Exposure   map[model.Currency]float64 `json:"exposure"`    // max amount held during a trade
+}
+
+// Opportunity lifetime distribution, seconds
+type DurationStats struct {
+	Min    float64 `json:"min"`
+	Median float64 `json:"median"`
+	P90    float64 `json:"p90"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+}
+
+// Market records in time order, io.EOF at the end
+type RecordReader interface {
+	Next() (model.MarketRecord, error)
+}
+
+// Trade waiting for the market at execution time
+type pendingTrade struct {
+	at    time.Time
+	route []model.Currency
+	size  float64
+}
+
+// Run strategy over records in [from, to], zero time is no bound.
+// Records are read one by one, reading stops after to.
+// Trades are taken at the detection record and checked again
+// at the first record after latency.
+func Run(records RecordReader, arbitrage *service.ArbitrageService, strategy Strategy, from, to time.Time) (report Report, err error) {
+
+	report = Report{
+		PnL:        map[model.Currency]float64{},
+		LatencyPnL: map[model.Currency]float64{},
+		Exposure:   map[model.Currency]float64{},
+	}
+
+	minProfit := strategy.MinProfit
+	query := service.ArbitrageQuery{
+		Base:      strategy.Base,
+		MaxLegs:   strategy.MaxLegs,
+		MinProfit: &minProfit,
+	}
+
+	open := map[string]time.Time{} // route -> first seen
+	var durations []time.Duration
+	var nextTrade time.Time
+	var pending []pendingTrade
+	var record model.MarketRecord
+
+	for {
+
+		next, readErr := records.Next()
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			err = readErr
+			return
+		}
+
+		if !from.IsZero() && next.Time.Before(from) {
+			continue
+		}
+		if !to.IsZero() && next.Time.After(to) {
+			break
+		}
+
+		record = next
+		if report.Records == 0 {
+			report.From = record.Time
+		}
+		report.To = record.Time
+		report.Records++
+
+		list := arbitrage.Search(&model.Snapshot{
+			Time:       record.Time,
+			Currencies: record.Currencies,
+			Pairs:      record.Pairs,
+			Orders:     record.Orders,
+		}, query)
+
+		seen := map[string]bool{}
+		orders := record.Orders // left after the trades of the record
+
+		for _, arb := range list {
+
+			// Cycles search lists unprofitable routes too
+			if arb.NetProfit <= 1 {
+				continue
+			}
+
+			key := service.CycleKey(arb.Route)
+			seen[key] = true
+
+			if _, ok := open[key]; !ok {
+				open[key] = record.Time
+				report.Opportunities++
+			}
+
+			if !arb.Executable() || record.Time.Before(nextTrade) {
+				continue
+			}
+
+			size := arb.Volume
+			if strategy.MaxSize > 0 && size > strategy.MaxSize {
+				size = strategy.MaxSize
+			}
+
+			// Earlier trades of the record took a part of the books
+			amounts, ok := arbitrage.RouteAmounts(orders, arb.Route, size)
+			if !ok || amounts[0] <= 0 || amounts[len(amounts)-1] <= amounts[0] {
+				continue
+			}
+			orders = arbitrage.ConsumeOrders(orders, arb.Route, size)
+
+			start := arb.Route[0]
+			report.Trades++
+			report.PnL[start] += amounts[len(amounts)-1] - amounts[0]
+
+			for j, currency := range arb.Route[:len(arb.Route)-1] {
+				if amounts[j] > report.Exposure[currency] {
+					report.Exposure[currency] = amounts[j]
+				}
+			}
+
+			pending = append(pending, pendingTrade{
+				at:    record.Time.Add(strategy.Latency),
+				route: arb.Route,
+				size:  size,
+			})
+
+			nextTrade = record.Time.Add(strategy.Cooldown)
+
+		}
+
+		pending = settle(pending, record, arbitrage, &report, false)
+
+		// Closed opportunities
+		for key, first := range open {
+			if !seen[key] {
+				durations = append(durations, record.Time.Sub(first))
+				delete(open, key)
+			}
+		}
+
+	}
+
+	if report.Records == 0 {
+		return
+	}
+
+	// Market after latency is the last record
+	settle(pending, record, arbitrage, &report, true)
+
+	// Still open at the end
+	for _, first := range open {
+		durations = append(durations, report.To.Sub(first))
+	}
+
+	report.Duration = durationStats(durations)
+
+	if report.Trades > 0 {
+		report.HitRate = float64(report.Hits) / float64(report.Trades)
+	}
+
+	return
+
+}
+
+// Check trades at the market of the record, the first one not before their
+// execution time or the last one, every trade takes a part of the books.
+// Trades waiting for a later record are returned.
+func settle(pending []pendingTrade, record model.MarketRecord, arbitrage *service.ArbitrageService, report *Report, last bool) []pendingTrade {
+
+	orders := record.Orders
+	waiting := pending[:0]
+	for _, trade := range pending {
+
+		if !last && record.Time.Before(trade.at) {
+			waiting = append(waiting, trade)
+			continue
+		}
+
+		if amounts, ok := arbitrage.RouteAmounts(orders, trade.route, trade.size); ok {
+			profit := amounts[len(amounts)-1] - amounts[0]
+			report.LatencyPnL[trade.route[0]] += profit
+			if profit > 0 {
+				report.Hits++
+			}
+			orders = arbitrage.ConsumeOrders(orders, trade.route, trade.size)
+		}
+
+	}
+
+	return waiting
+
+}
+
+func durationStats(durations []time.Duration) (stats DurationStats) {
+
+	if len(durations) == 0 {
+		return
+	}
+
+	sort.Slice(durations, func(i, j int) bool {
+		return durations[i] < durations[j]
+	})
+
+	var sum time.Duration
+	for _, d := range durations {
+		sum += d
+	}
+
+	percentile := func(p float64) float64 {
+		return durations[int(p*float64(len(durations)-1))].Seconds()
+	}
+
+	stats = DurationStats{
+		Min:    durations[0].Seconds(),
+		Median: percentile(0.5),
+		P90:    percentile(0.9),
+		Max:    durations[len(durations)-1].Seconds(),
+		Mean:   (sum / time.Duration(len(durations))).Seconds(),
+	}
+
+	return
+
+}
@@ -0,0 +1,170 @@
+package backtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/recorder"
+	"github.com/tusupov/exmoarbitrage/service"
+)
+
+var testStart = time.Date(2019, 1, 10, 12, 0, 0, 0, time.UTC)
+
+// Records of a slice
+type testRecordReader struct {
+	records []model.MarketRecord
+}
+
+func (r *testRecordReader) Next() (record model.MarketRecord, err error) {
+
+	if len(r.records) == 0 {
+		err = io.EOF
+		return
+	}
+
+	record, r.records = r.records[0], r.records[1:]
+
+	return
+
+}
+
+// Records every 2 seconds with BTC_USD ask and bid
+func testRecords() []model.MarketRecord {
+
+	books := [][2]float64{{3700, 3800}, {3700, 3800}, {3800, 3700}, {3700, 3750}}
+
+	records := make([]model.MarketRecord, 0, len(books))
+	for i, book := range books {
+		ask := model.Offer{Price: book[0], Quantity: 1, Amount: book[0]}
+		bid := model.Offer{Price: book[1], Quantity: 1, Amount: book[1]}
+		records = append(records, model.MarketRecord{
+			Time:       testStart.Add(time.Duration(i) * 2 * time.Second),
+			Currencies: []model.Currency{"BTC", "USD"},
+			Pairs:      model.PairSettings{"BTC_USD": model.Setting{}},
+			Orders: model.PairOrders{
+				"BTC_USD": model.Order{Ask: ask, Bid: bid, Asks: []model.Offer{ask}, Bids: []model.Offer{bid}},
+			},
+		})
+	}
+
+	return records
+
+}
+
+func TestRun(t *testing.T) {
+
+	strategy := Strategy{
+		Base:     "USD",
+		MaxSize:  370,
+		Cooldown: 3 * time.Second,
+		Latency:  time.Second,
+	}
+
+	report, err := Run(&testRecordReader{records: testRecords()}, service.NewArbitrage(nil), strategy, time.Time{}, time.Time{})
+	assert.Nil(t, err)
+
+	assert.Equal(t, 4, report.Records)
+	assert.Equal(t, testStart, report.From)
+	assert.Equal(t, testStart.Add(6*time.Second), report.To)
+	assert.Equal(t, 2, report.Opportunities)
+	assert.Equal(t, DurationStats{Min: 0, Median: 0, P90: 0, Max: 4, Mean: 2}, report.Duration)
+
+	// Second record is skipped by cooldown
+	assert.Equal(t, 2, report.Trades)
+	assert.Equal(t, 2, report.Hits)
+	assert.Equal(t, 1.0, report.HitRate)
+	assert.InDelta(t, 15, report.PnL["USD"], 1e-9)
+	assert.InDelta(t, 15, report.LatencyPnL["USD"], 1e-9)
+	assert.InDelta(t, 370, report.Exposure["USD"], 1e-9)
+	assert.InDelta(t, 0.1, report.Exposure["BTC"], 1e-9)
+
+	// Opportunity is gone after latency
+	strategy.Latency = 3 * time.Second
+	report, err = Run(&testRecordReader{records: testRecords()}, service.NewArbitrage(nil), strategy, time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Hits)
+	assert.Equal(t, 0.5, report.HitRate)
+	assert.InDelta(t, 5+370/3800.0*3700-370, report.LatencyPnL["USD"], 1e-9)
+
+	// Range
+	report, err = Run(&testRecordReader{records: testRecords()}, service.NewArbitrage(nil), strategy, testStart.Add(3*time.Second), time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Records)
+	assert.Equal(t, 1, report.Trades)
+
+	// Reading stops after the range
+	records := &testRecordReader{records: testRecords()}
+	report, err = Run(records, service.NewArbitrage(nil), strategy, time.Time{}, testStart.Add(time.Second))
+	assert.Nil(t, err)
+	assert.Equal(t, 1, report.Records)
+	assert.Len(t, records.records, 2)
+
+}
+
+func TestRun_sharedBooks(t *testing.T) {
+
+	offer := func(price, quantity float64) model.Offer {
+		return model.Offer{Price: price, Quantity: quantity, Amount: price * quantity}
+	}
+	order := func(ask, bid model.Offer) model.Order {
+		return model.Order{Ask: ask, Bid: bid, Asks: []model.Offer{ask}, Bids: []model.Offer{bid}}
+	}
+
+	// Both routes buy the only BTC ask
+	record := model.MarketRecord{
+		Time:       testStart,
+		Currencies: []model.Currency{"USD", "BTC", "EUR"},
+		Pairs:      model.PairSettings{},
+		Orders: model.PairOrders{
+			"BTC_USD": order(offer(3700, 1), offer(3800, 1)),
+			"BTC_EUR": order(offer(3600, 2), offer(3500, 2)),
+			"EUR_USD": order(offer(1.25, 10000), offer(1.2, 10000)),
+		},
+	}
+
+	report, err := Run(&testRecordReader{records: []model.MarketRecord{record}}, service.NewArbitrage(nil), Strategy{Base: "USD", MaxLegs: 3}, time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, report.Opportunities)
+	assert.Equal(t, 1, report.Trades)
+	assert.InDelta(t, 500, report.PnL["USD"], 1e-9)
+	assert.InDelta(t, 500, report.LatencyPnL["USD"], 1e-9)
+
+}
+
+func TestCommand(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "backtest")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	history := recorder.NewRecorder(dir)
+	for _, record := range testRecords() {
+		assert.Nil(t, history.Write(record))
+	}
+	assert.Nil(t, history.Close())
+
+	var out bytes.Buffer
+	err = Command([]string{"-data", dir, "-base", "usd", "-max-size", "370", "-cooldown", "3s", "-fee-taker", "0", "-format", "json"}, &out)
+	assert.Nil(t, err)
+
+	var report Report
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &report))
+	assert.Equal(t, 2, report.Trades)
+	assert.InDelta(t, 15, report.PnL["USD"], 1e-9)
+
+	out.Reset()
+	err = Command([]string{"-data", dir, "-base", "USD", "-fee-taker", "0"}, &out)
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), "Opportunities: 2\n")
+	assert.Contains(t, out.String(), "USD ")
+
+	err = Command([]string{"-data", dir, "-format", "xml"}, &out)
+	assert.NotNil(t, err)
+
+}
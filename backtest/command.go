@@ -0,0 +1,105 @@
+package backtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"github.com/tusupov/exmoarbitrage/config"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/recorder"
+	"github.com/tusupov/exmoarbitrage/service"
+)
+
+// Run backtest subcommand with command line args, report is written to out
+func Command(args []string, out io.Writer) error {
+
+	cfg, err := config.InitBacktest(args)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Format != "text" && cfg.Format != "json" {
+		return fmt.Errorf("unknown format `%s`, expected text or json", cfg.Format)
+	}
+
+	files, err := recorder.Files(cfg.Data)
+	if err != nil {
+		return err
+	}
+
+	records := recorder.OpenFiles(recorder.Between(files, cfg.From, cfg.To)...)
+	defer records.Close()
+
+	arbitrage := service.NewArbitrage(nil)
+	arbitrage.SetFee(cfg.Fee)
+
+	report, err := Run(records, arbitrage, Strategy{
+		Base:      cfg.Base,
+		MinProfit: cfg.MinProfit,
+		MaxLegs:   cfg.MaxLegs,
+		MaxSize:   cfg.MaxSize,
+		Cooldown:  cfg.Cooldown,
+		Latency:   cfg.Latency,
+	}, cfg.From, cfg.To)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Format == "json" {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	}
+
+	return report.WriteText(out)
+
+}
+
+// Human readable report
+func (r Report) WriteText(out io.Writer) (err error) {
+
+	write := func(format string, args ...interface{}) {
+		if err == nil {
+			_, err = fmt.Fprintf(out, format, args...)
+		}
+	}
+
+	write("Period:        %s - %s\n", r.From.Format("2006-01-02 15:04:05"), r.To.Format("2006-01-02 15:04:05"))
+	write("Records:       %d\n", r.Records)
+	write("Opportunities: %d\n", r.Opportunities)
+	write("Duration, sec: min %.1f, median %.1f, p90 %.1f, max %.1f, mean %.1f\n",
+		r.Duration.Min, r.Duration.Median, r.Duration.P90, r.Duration.Max, r.Duration.Mean)
+	write("Trades:        %d\n", r.Trades)
+	write("Hit rate:      %.2f%% (%d after latency)\n", r.HitRate*100, r.Hits)
+
+	write("\n%-10s %18s %18s %18s\n", "Currency", "PnL", "PnL after latency", "Max exposure")
+	for _, currency := range reportCurrencies(r) {
+		write("%-10s %18.8f %18.8f %18.8f\n", currency, r.PnL[currency], r.LatencyPnL[currency], r.Exposure[currency])
+	}
+
+	return
+
+}
+
+func reportCurrencies(r Report) []model.Currency {
+
+	set := map[model.Currency]bool{}
+	for _, amounts := range []map[model.Currency]float64{r.PnL, r.LatencyPnL, r.Exposure} {
+		for currency := range amounts {
+			set[currency] = true
+		}
+	}
+
+	list := make([]model.Currency, 0, len(set))
+	for currency := range set {
+		list = append(list, currency)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i] < list[j]
+	})
+
+	return list
+
+}
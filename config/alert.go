@@ -0,0 +1,54 @@
+package config
+
+import (
+	"strings"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Flag value for webhook urls "https://a.example/hook,https://b.example/hook"
+type urlsValue struct {
+	urls *[]string
+	raw  string
+}
+
+func (v *urlsValue) String() string {
+	return v.raw
+}
+
+func (v *urlsValue) Set(s string) error {
+
+	*v.urls = nil
+	for _, url := range strings.Split(s, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			*v.urls = append(*v.urls, url)
+		}
+	}
+	v.raw = s
+
+	return nil
+
+}
+
+// Flag value for currency list "BTC,ETH"
+type currenciesValue struct {
+	currencies *[]model.Currency
+	raw        string
+}
+
+func (v *currenciesValue) String() string {
+	return v.raw
+}
+
+func (v *currenciesValue) Set(s string) error {
+
+	*v.currencies = nil
+	for _, currency := range strings.Split(s, ",") {
+		if currency = strings.ToUpper(strings.TrimSpace(currency)); currency != "" {
+			*v.currencies = append(*v.currencies, model.Currency(currency))
+		}
+	}
+	v.raw = s
+
+	return nil
+
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"strings"
+	"time"
+	"github.com/namsral/flag"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Backtest command config
+type BacktestConfig struct {
+	Data string // recorded market file or directory
+	From time.Time
+	To   time.Time
+
+	Base      model.Currency
+	MinProfit float64
+	MaxLegs   int
+	MaxSize   float64
+	Cooldown  time.Duration
+	Latency   time.Duration
+
+	Fee    model.FeeSettings
+	Format string // text or json
+}
+
+func InitBacktest(args []string) (*BacktestConfig, error) {
+
+	cfg := &BacktestConfig{}
+	var base string
+
+	fs := flag.NewFlagSet("backtest", flag.ContinueOnError)
+	fs.StringVar(&cfg.Data, "data", "./history", "Recorded market file or directory")
+	fs.Var(&timeValue{time: &cfg.From}, "from", "Range start, 2006-01-02 or RFC3339")
+	fs.Var(&timeValue{time: &cfg.To, end: true}, "to", "Range end, 2006-01-02 includes the day, or RFC3339")
+	fs.StringVar(&base, "base", "", "Route start and end currency")
+	fs.Float64Var(&cfg.MinProfit, "min-profit", 0, "Min net profit to trade, percent")
+	fs.IntVar(&cfg.MaxLegs, "max-legs", 0, "Max route legs, 0 - any")
+	fs.Float64Var(&cfg.MaxSize, "max-size", 0, "Max trade volume in route start currency, 0 - profitable order books depth")
+	fs.DurationVar(&cfg.Cooldown, "cooldown", 0, "Pause after a trade")
+	fs.DurationVar(&cfg.Latency, "latency", 0, "Delay from detection to execution")
+	fs.Float64Var(&cfg.Fee.Taker, "fee-taker", 0.002, "Taker commission rate")
+	fs.Var(&pairFeeValue{fee: &cfg.Fee}, "fee-pairs", "Per-pair commission rate, BTC_USD:0.001,...")
+	fs.Var(&withdrawFeeValue{fee: &cfg.Fee}, "fee-withdraw", "Withdrawal fee, BTC:0.0005,...")
+	fs.StringVar(&cfg.Format, "format", "text", "Report format, text or json")
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg.Base = model.Currency(strings.ToUpper(base))
+
+	return cfg, nil
+
+}
+
+// Flag value for time "2006-01-02" or RFC3339
+type timeValue struct {
+	time *time.Time
+	end  bool // date is the last nanosecond of the day, as in the history query
+	raw  string
+}
+
+func (v *timeValue) String() string {
+	return v.raw
+}
+
+func (v *timeValue) Set(s string) (err error) {
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		if t, err = time.Parse("2006-01-02", s); err != nil {
+			return
+		}
+		if v.end {
+			t = t.Add(24*time.Hour - time.Nanosecond)
+		}
+	}
+
+	*v.time = t
+	v.raw = s
+
+	return
+
+}
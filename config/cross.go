@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Flag value for exchange list "exmo,binance"
+type exchangesValue struct {
+	exchanges *[]string
+	raw       string
+}
+
+func (v *exchangesValue) String() string {
+	return v.raw
+}
+
+func (v *exchangesValue) Set(s string) error {
+
+	*v.exchanges = nil
+	for _, exchange := range strings.Split(s, ",") {
+		if exchange = strings.ToLower(strings.TrimSpace(exchange)); exchange != "" {
+			*v.exchanges = append(*v.exchanges, exchange)
+		}
+	}
+	v.raw = s
+
+	return nil
+
+}
+
+// Flag value for per-exchange commission "binance:0.001,exmo:0.002"
+type exchangeFeeValue struct {
+	fees *map[string]float64
+	raw  string
+}
+
+func (v *exchangeFeeValue) String() string {
+	return v.raw
+}
+
+func (v *exchangeFeeValue) Set(s string) error {
+
+	rates, err := parseRates(s)
+	if err != nil {
+		return err
+	}
+
+	*v.fees = make(map[string]float64, len(rates))
+	for exchange, rate := range rates {
+		(*v.fees)[strings.ToLower(exchange)] = rate
+	}
+	v.raw = s
+
+	return nil
+
+}
+
+// Flag value for per-exchange withdrawal fee "binance:BTC:0.0005,exmo:BTC:0.001"
+type exchangeWithdrawValue struct {
+	fees *map[string]map[model.Currency]float64
+	raw  string
+}
+
+func (v *exchangeWithdrawValue) String() string {
+	return v.raw
+}
+
+func (v *exchangeWithdrawValue) Set(s string) error {
+
+	*v.fees = make(map[string]map[model.Currency]float64)
+
+	for _, item := range strings.Split(s, ",") {
+
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.Split(item, ":")
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid item `%s`, expected EXCHANGE:CURRENCY:FEE", item)
+		}
+
+		fee, err := strconv.ParseFloat(parts[2], 64)
+		if err != nil {
+			return fmt.Errorf("invalid value in `%s`: %v", item, err)
+		}
+
+		exchange := strings.ToLower(parts[0])
+		if (*v.fees)[exchange] == nil {
+			(*v.fees)[exchange] = make(map[model.Currency]float64)
+		}
+		(*v.fees)[exchange][model.Currency(strings.ToUpper(parts[1]))] = fee
+
+	}
+	v.raw = s
+
+	return nil
+
+}
+
+// Flag value for transfer time "BTC:30m,ETH:5m"
+type transferTimeValue struct {
+	times *map[model.Currency]time.Duration
+	raw   string
+}
+
+func (v *transferTimeValue) String() string {
+	return v.raw
+}
+
+func (v *transferTimeValue) Set(s string) error {
+
+	*v.times = make(map[model.Currency]time.Duration)
+
+	for _, item := range strings.Split(s, ",") {
+
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		kv := strings.Split(item, ":")
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid item `%s`, expected CURRENCY:DURATION", item)
+		}
+
+		d, err := time.ParseDuration(kv[1])
+		if err != nil {
+			return fmt.Errorf("invalid value in `%s`: %v", item, err)
+		}
+
+		(*v.times)[model.Currency(strings.ToUpper(kv[0]))] = d
+
+	}
+	v.raw = s
+
+	return nil
+
+}
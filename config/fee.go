@@ -0,0 +1,92 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Parse list "KEY:VALUE,KEY:VALUE"
+func parseRates(s string) (rates map[string]float64, err error) {
+
+	rates = make(map[string]float64)
+
+	for _, item := range strings.Split(s, ",") {
+
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		kv := strings.Split(item, ":")
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid item `%s`, expected KEY:VALUE", item)
+		}
+
+		rate, err := strconv.ParseFloat(kv[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value in `%s`: %v", item, err)
+		}
+
+		rates[strings.ToUpper(kv[0])] = rate
+
+	}
+
+	return
+
+}
+
+// Flag value for per-pair commission "BTC_USD:0.001,ETH_BTC:0.0015"
+type pairFeeValue struct {
+	fee *model.FeeSettings
+	raw string
+}
+
+func (v *pairFeeValue) String() string {
+	return v.raw
+}
+
+func (v *pairFeeValue) Set(s string) error {
+
+	rates, err := parseRates(s)
+	if err != nil {
+		return err
+	}
+
+	v.fee.Pairs = make(map[model.Pair]model.Fee, len(rates))
+	for pair, rate := range rates {
+		v.fee.Pairs[model.Pair(pair)] = model.Fee{Taker: rate, Maker: rate}
+	}
+	v.raw = s
+
+	return nil
+
+}
+
+// Flag value for withdrawal fee "BTC:0.0005,USD:1"
+type withdrawFeeValue struct {
+	fee *model.FeeSettings
+	raw string
+}
+
+func (v *withdrawFeeValue) String() string {
+	return v.raw
+}
+
+func (v *withdrawFeeValue) Set(s string) error {
+
+	rates, err := parseRates(s)
+	if err != nil {
+		return err
+	}
+
+	v.fee.Withdraw = make(map[model.Currency]float64, len(rates))
+	for currency, rate := range rates {
+		v.fee.Withdraw[model.Currency(currency)] = rate
+	}
+	v.raw = s
+
+	return nil
+
+}
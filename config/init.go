@@ -1,22 +1,125 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"time"
 	"github.com/namsral/flag"
+	"github.com/tusupov/exmoarbitrage/model"
 )
 
 type Config struct {
 	TemplateDirectory string
 	ServerPort        int
+	Exchange          string
+	ExchangeUrl       string
+	OrderDepth        int
+	PollInterval      time.Duration
+	ReadyMaxAge       time.Duration
+	Stream            bool
+	RecordDirectory   string
+	Replay            string
+	ReplaySpeed       float64
+	History           string
+	HistoryRetention  time.Duration
+	Fee               model.FeeSettings
+	TradeKey          string
+	TradeSecret       string
+	TradeApi          bool
+	TradeApiToken     string
+	Paper             bool
+	PaperBalances     map[model.Currency]float64
+	PaperLatency      time.Duration
+	AlertWebhooks     []string
+	AlertRule         model.AlertRule
+	AlertInterval     time.Duration
+	AlertGrace        time.Duration
+	AlertRetries      int
+	TelegramUrl       string
+	TelegramToken     string
+	TelegramChat      string
+	TelegramMinProfit float64
+	CrossExchanges    []string
+	CrossFees         map[string]float64
+	CrossWithdraw     map[string]map[model.Currency]float64
+	CrossTransferTime map[model.Currency]time.Duration
 }
 
-func Init() *Config {
+// Min market data refresh interval, a snapshot takes a few requests
+const MinPollInterval = 500 * time.Millisecond
+
+var (
+	errTradeApiToken  = errors.New("trade-api requires trade-api-token")
+	errTradeApiTrader = errors.New("trade-api requires trade-key or paper")
+	errPollInterval   = fmt.Errorf("poll-interval must be at least %s", MinPollInterval)
+	errReplayTrade    = errors.New("replay with trade-key requires paper, recorded prices are not tradable")
+)
+
+func Init() (*Config, error) {
 
 	cfg := &Config{}
 
 	flag.StringVar(&cfg.TemplateDirectory, "template", "./route/", "Server port")
 	flag.IntVar(&cfg.ServerPort, "port", 8080, "Server port")
+	flag.StringVar(&cfg.Exchange, "exchange", "exmo", "Market data exchange, exmo or binance")
+	flag.StringVar(&cfg.ExchangeUrl, "exchange-url", "", "Exchange API base url, empty - default")
+	flag.IntVar(&cfg.OrderDepth, "depth", 100, "Order book depth")
+	flag.DurationVar(&cfg.PollInterval, "poll-interval", 2*time.Second, "Market data refresh interval")
+	flag.DurationVar(&cfg.ReadyMaxAge, "ready-max-age", 30*time.Second, "Max age of the last successful market data fetch for readiness")
+	flag.StringVar(&cfg.RecordDirectory, "record", "", "Directory to record market data to, empty - no recording")
+	flag.StringVar(&cfg.Replay, "replay", "", "Recorded market file or directory to serve instead of the exchange")
+	flag.Float64Var(&cfg.ReplaySpeed, "replay-speed", 1, "Replay speed, 1 - real time, 0 - next record on every refresh")
+	flag.StringVar(&cfg.History, "history", "", "Opportunity history database file, empty - no history")
+	flag.DurationVar(&cfg.HistoryRetention, "history-retention", 30*24*time.Hour, "Opportunity history retention, 0 - forever")
+	flag.BoolVar(&cfg.Stream, "stream", false, "Order books from Exmo websocket instead of REST")
+	flag.Float64Var(&cfg.Fee.Taker, "fee-taker", 0.002, "Taker commission rate")
+	flag.Float64Var(&cfg.Fee.Maker, "fee-maker", 0.002, "Maker commission rate")
+	flag.Var(&pairFeeValue{fee: &cfg.Fee}, "fee-pairs", "Per-pair commission rate, BTC_USD:0.001,...")
+	flag.Var(&withdrawFeeValue{fee: &cfg.Fee}, "fee-withdraw", "Withdrawal fee, BTC:0.0005,...")
+	flag.StringVar(&cfg.TradeKey, "trade-key", "", "Exmo API key, enables route execution")
+	flag.StringVar(&cfg.TradeSecret, "trade-secret", "", "Exmo API secret")
+	flag.BoolVar(&cfg.TradeApi, "trade-api", false, "Enable route execution requests POST /api/v1/executions")
+	flag.StringVar(&cfg.TradeApiToken, "trade-api-token", "", "Bearer token of route execution requests")
+	flag.BoolVar(&cfg.Paper, "paper", false, "Execute routes on a simulated exchange")
+	flag.Var(&balancesValue{balances: &cfg.PaperBalances}, "paper-balances", "Paper trading balances, USD:1000,BTC:0.1")
+	flag.DurationVar(&cfg.PaperLatency, "paper-latency", 100*time.Millisecond, "Paper trading request latency")
+	flag.Var(&urlsValue{urls: &cfg.AlertWebhooks}, "alert-webhooks", "Comma separated webhook urls for alerts, empty - no alerts")
+	flag.Float64Var(&cfg.AlertRule.MinProfit, "alert-min-profit", 0, "Min net profit to alert, percent")
+	flag.Float64Var(&cfg.AlertRule.MinVolume, "alert-min-volume", 0, "Min available volume to alert, route start currency")
+	flag.Var(&currenciesValue{currencies: &cfg.AlertRule.Currencies}, "alert-currencies", "Alert on routes through any of the currencies, BTC,ETH")
+	flag.DurationVar(&cfg.AlertRule.Persistence, "alert-persistence", 0, "Min time a route has been seen for to alert")
+	flag.DurationVar(&cfg.AlertInterval, "alert-interval", 10*time.Second, "Min time between requests to a webhook")
+	flag.DurationVar(&cfg.AlertGrace, "alert-grace", 30*time.Second, "Time a missing route is remembered for before it is alerted again")
+	flag.IntVar(&cfg.AlertRetries, "alert-retries", 3, "Webhook request retries")
+	flag.StringVar(&cfg.TelegramUrl, "telegram-url", "https://api.telegram.org", "Telegram Bot API base url")
+	flag.StringVar(&cfg.TelegramToken, "telegram-token", "", "Telegram bot token, empty - no bot")
+	flag.StringVar(&cfg.TelegramChat, "telegram-chat", "", "Telegram chat id or @channel for notifications and commands")
+	flag.Float64Var(&cfg.TelegramMinProfit, "telegram-min-profit", 0.5, "Min net profit of notified routes, percent")
+	flag.Var(&exchangesValue{exchanges: &cfg.CrossExchanges}, "cross-exchanges", "Exchanges for cross-exchange arbitrage, exmo,binance, empty - disabled")
+	flag.Var(&exchangeFeeValue{fees: &cfg.CrossFees}, "cross-fees", "Per-exchange taker commission rate, binance:0.001,...")
+	flag.Var(&exchangeWithdrawValue{fees: &cfg.CrossWithdraw}, "cross-withdraw", "Per-exchange withdrawal fee, binance:BTC:0.0005,..., currencies without fee are not transferred")
+	flag.Var(&transferTimeValue{times: &cfg.CrossTransferTime}, "cross-transfer-time", "Transfer time between exchanges, BTC:30m,...")
 	flag.Parse()
 
-	return cfg
+	cfg.AlertRule.Name = "default"
+
+	if cfg.PollInterval < MinPollInterval {
+		return nil, errPollInterval
+	}
+
+	if cfg.TradeApi && cfg.TradeApiToken == "" {
+		return nil, errTradeApiToken
+	}
+
+	if cfg.TradeApi && !cfg.Paper && cfg.TradeKey == "" {
+		return nil, errTradeApiTrader
+	}
+
+	// Orders planned from recorded books must not reach the account
+	if cfg.Replay != "" && !cfg.Paper && cfg.TradeKey != "" {
+		return nil, errReplayTrade
+	}
+
+	return cfg, nil
 
 }
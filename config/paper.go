@@ -0,0 +1,32 @@
+package config
+
+import (
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Flag value for paper trading balances "USD:1000,BTC:0.1"
+type balancesValue struct {
+	balances *map[model.Currency]float64
+	raw      string
+}
+
+func (v *balancesValue) String() string {
+	return v.raw
+}
+
+func (v *balancesValue) Set(s string) error {
+
+	amounts, err := parseRates(s)
+	if err != nil {
+		return err
+	}
+
+	*v.balances = make(map[model.Currency]float64, len(amounts))
+	for currency, amount := range amounts {
+		(*v.balances)[model.Currency(currency)] = amount
+	}
+	v.raw = s
+
+	return nil
+
+}
@@ -0,0 +1,341 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	bucketName         = []byte("opportunities")
+	currencyBucketName = []byte("currencies") // currency -> keys of its opportunities
+)
+
+// Old opportunities are pruned at most once per interval
+const pruneInterval = time.Hour
+
+// Opportunity history in an embedded bbolt database.
+// Keys are the opportunity time in unix nanoseconds followed
+// by a sequence number, so the bucket is ordered by time.
+// Every currency bucket has the keys of the opportunities through it.
+type Store struct {
+	db        *bolt.DB
+	retention time.Duration
+
+	last   map[string]model.Opportunity // saved state of the open routes by cycle key
+	pruned time.Time
+}
+
+// Open or create the database file, the currency index is built if missing
+func Open(path string) (*Store, error) {
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+
+		bucket, err := tx.CreateBucketIfNotExists(bucketName)
+		if err != nil {
+			return err
+		}
+
+		if tx.Bucket(currencyBucketName) != nil {
+			return nil
+		}
+
+		currencies, err := tx.CreateBucket(currencyBucketName)
+		if err != nil {
+			return err
+		}
+
+		return bucket.ForEach(func(k, v []byte) error {
+			var opportunity model.Opportunity
+			if err := json.Unmarshal(v, &opportunity); err != nil {
+				return err
+			}
+			return indexCurrencies(currencies, k, opportunity.Route)
+		})
+
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Store{
+		db:   db,
+		last: make(map[string]model.Opportunity),
+	}, nil
+
+}
+
+// Keep opportunities for d before the latest snapshot, 0 - forever
+func (s *Store) SetRetention(d time.Duration) {
+	s.retention = d
+}
+
+// Save new and changed opportunities of every snapshot of notifier
+// until its updates are closed or ctx is done
+func (s *Store) Run(ctx context.Context, notifier service.Notifier) {
+
+	updates, unsubscribe := notifier.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case snapshot, ok := <-updates:
+			if !ok {
+				return
+			}
+			if err := s.Save(s.changed(service.SnapshotOpportunities(snapshot))...); err != nil {
+				log.Printf("History error: %v\n", err)
+			}
+			if err := s.prune(snapshot.Time); err != nil {
+				log.Printf("History error: %v\n", err)
+			}
+		}
+	}
+
+}
+
+// Opportunities which appeared or changed since the previous snapshot,
+// a route is saved again after it disappears
+func (s *Store) changed(list []model.Opportunity) (result []model.Opportunity) {
+
+	last := make(map[string]model.Opportunity, len(list))
+	for _, opportunity := range list {
+
+		key := service.CycleKey(opportunity.Route)
+
+		saved, ok := s.last[key]
+		if !ok || saved.Profit != opportunity.Profit || saved.NetProfit != opportunity.NetProfit || saved.Volume != opportunity.Volume {
+			result = append(result, opportunity)
+			saved = opportunity
+		}
+		last[key] = saved
+
+	}
+	s.last = last
+
+	return
+
+}
+
+// Save opportunities in one transaction
+func (s *Store) Save(list ...model.Opportunity) error {
+
+	if len(list) == 0 {
+		return nil
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+
+		bucket := tx.Bucket(bucketName)
+		currencies := tx.Bucket(currencyBucketName)
+
+		for _, opportunity := range list {
+
+			seq, err := bucket.NextSequence()
+			if err != nil {
+				return err
+			}
+
+			value, err := json.Marshal(opportunity)
+			if err != nil {
+				return err
+			}
+
+			key := storeKey(opportunity.Time, seq)
+			if err = bucket.Put(key, value); err != nil {
+				return err
+			}
+
+			if err = indexCurrencies(currencies, key, opportunity.Route); err != nil {
+				return err
+			}
+
+		}
+
+		return nil
+
+	})
+
+}
+
+// Delete opportunities older than retention before now, once per prune interval
+func (s *Store) prune(now time.Time) error {
+
+	if s.retention <= 0 || now.Sub(s.pruned) < pruneInterval {
+		return nil
+	}
+	s.pruned = now
+
+	return s.Prune(now.Add(-s.retention))
+
+}
+
+// Delete opportunities before t
+func (s *Store) Prune(t time.Time) error {
+
+	before := storeKey(t, 0)
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+
+		bucket := tx.Bucket(bucketName)
+		currencies := tx.Bucket(currencyBucketName)
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.First(); k != nil && bytes.Compare(k, before) < 0; k, v = cursor.First() {
+
+			var opportunity model.Opportunity
+			if err := json.Unmarshal(v, &opportunity); err != nil {
+				return err
+			}
+
+			for _, currency := range routeCurrencies(opportunity.Route) {
+				if index := currencies.Bucket([]byte(currency)); index != nil {
+					if err := index.Delete(k); err != nil {
+						return err
+					}
+				}
+			}
+
+			if err := cursor.Delete(); err != nil {
+				return err
+			}
+
+		}
+
+		return nil
+
+	})
+
+}
+
+// Opportunities matching query, newest first.
+// Currency filter reads the currency index only.
+func (s *Store) GetHistory(ctx context.Context, query service.HistoryQuery) (list []model.Opportunity, err error) {
+
+	list = []model.Opportunity{}
+
+	err = s.db.View(func(tx *bolt.Tx) error {
+
+		bucket := tx.Bucket(bucketName)
+
+		var cursor *bolt.Cursor
+		if query.Currency != "" {
+			index := tx.Bucket(currencyBucketName).Bucket([]byte(query.Currency))
+			if index == nil {
+				return nil
+			}
+			cursor = index.Cursor()
+		} else {
+			cursor = bucket.Cursor()
+		}
+
+		// Last key not after the range end
+		k, _ := cursor.Last()
+		if !query.To.IsZero() {
+			if k, _ = cursor.Seek(storeKey(query.To.Add(time.Nanosecond), 0)); k == nil {
+				k, _ = cursor.Last()
+			} else {
+				k, _ = cursor.Prev()
+			}
+		}
+
+		var from []byte
+		if !query.From.IsZero() {
+			from = storeKey(query.From, 0)
+		}
+
+		for ; k != nil; k, _ = cursor.Prev() {
+
+			if from != nil && bytes.Compare(k, from) < 0 {
+				break
+			}
+
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			var opportunity model.Opportunity
+			if err := json.Unmarshal(bucket.Get(k), &opportunity); err != nil {
+				return err
+			}
+
+			if !query.Match(opportunity) {
+				continue
+			}
+
+			list = append(list, opportunity)
+			if query.Limit > 0 && len(list) == query.Limit {
+				break
+			}
+
+		}
+
+		return nil
+
+	})
+
+	return
+
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Add key to the buckets of the route currencies
+func indexCurrencies(currencies *bolt.Bucket, key []byte, route []model.Currency) error {
+
+	for _, currency := range routeCurrencies(route) {
+
+		index, err := currencies.CreateBucketIfNotExists([]byte(currency))
+		if err != nil {
+			return err
+		}
+
+		if err = index.Put(key, nil); err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+
+}
+
+// Distinct currencies of the route
+func routeCurrencies(route []model.Currency) []model.Currency {
+
+	list := make([]model.Currency, 0, len(route))
+	seen := make(map[model.Currency]bool, len(route))
+	for _, currency := range route {
+		if !seen[currency] {
+			seen[currency] = true
+			list = append(list, currency)
+		}
+	}
+
+	return list
+
+}
+
+func storeKey(t time.Time, seq uint64) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
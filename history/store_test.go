@@ -0,0 +1,181 @@
+package history
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+type testNotifier struct {
+	updates chan *model.Snapshot
+}
+
+func (n *testNotifier) Subscribe() (<-chan *model.Snapshot, func()) {
+	return n.updates, func() {}
+}
+
+func openTestStore(t *testing.T) (*Store, string) {
+
+	dir, err := ioutil.TempDir("", "history")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "history.db")
+	store, err := Open(path)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return store, dir
+
+}
+
+func TestStore(t *testing.T) {
+
+	store, dir := openTestStore(t)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	start := time.Date(2019, 2, 1, 10, 0, 0, 0, time.UTC)
+
+	btc := []model.Currency{"USD", "BTC", "USD"}
+	eth := []model.Currency{"USD", "ETH", "BTC", "USD"}
+
+	notifier := &testNotifier{updates: make(chan *model.Snapshot, 3)}
+	for i := 0; i < 3; i++ {
+		notifier.updates <- &model.Snapshot{
+			Time: start.Add(time.Duration(i) * time.Minute),
+			Arbitrage: []model.Arbitrage{
+				{Route: btc, Profit: 1.02, NetProfit: 1.01, Volume: 100 + float64(i)},
+				{Route: eth, Profit: 1.005, NetProfit: 1.001, Volume: 50},
+			},
+		}
+	}
+	close(notifier.updates)
+	store.Run(ctx, notifier)
+
+	// Unchanged ETH route is saved once
+	list, err := store.GetHistory(ctx, service.HistoryQuery{})
+	assert.Nil(t, err)
+	if assert.Len(t, list, 4) {
+		assert.Equal(t, start.Add(2*time.Minute).Unix(), list[0].Time.Unix())
+		assert.Equal(t, start.Unix(), list[3].Time.Unix())
+		assert.Equal(t, 100.0, list[3].Volume)
+	}
+
+	minProfit := 0.5
+	list, err = store.GetHistory(ctx, service.HistoryQuery{
+		From:      start.Add(time.Minute),
+		To:        start.Add(time.Minute),
+		MinProfit: &minProfit,
+	})
+	assert.Nil(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, btc, list[0].Route)
+		assert.Equal(t, 1.02, list[0].Profit)
+	}
+
+	list, err = store.GetHistory(ctx, service.HistoryQuery{Currency: "ETH", Limit: 2})
+	assert.Nil(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, eth, list[0].Route)
+	}
+
+	list, err = store.GetHistory(ctx, service.HistoryQuery{Currency: "BTC", To: start, Limit: 1})
+	assert.Nil(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, eth, list[0].Route)
+	}
+
+	list, err = store.GetHistory(ctx, service.HistoryQuery{Currency: "XRP"})
+	assert.Nil(t, err)
+	assert.Empty(t, list)
+
+	list, err = store.GetHistory(ctx, service.HistoryQuery{To: start.Add(-time.Second)})
+	assert.Nil(t, err)
+	assert.Empty(t, list)
+
+	// Saved opportunities stay after reopen
+	assert.Nil(t, store.Close())
+	store, err = Open(filepath.Join(dir, "history.db"))
+	assert.Nil(t, err)
+	defer store.Close()
+
+	list, err = store.GetHistory(ctx, service.HistoryQuery{From: start.Add(2 * time.Minute)})
+	assert.Nil(t, err)
+	assert.Len(t, list, 1)
+
+}
+
+func TestStore_changed(t *testing.T) {
+
+	store, dir := openTestStore(t)
+	defer os.RemoveAll(dir)
+	defer store.Close()
+
+	btc := model.Opportunity{Route: []model.Currency{"USD", "BTC", "USD"}, Profit: 1.02}
+	rotated := model.Opportunity{Route: []model.Currency{"BTC", "USD", "BTC"}, Profit: 1.02}
+
+	assert.Len(t, store.changed([]model.Opportunity{btc}), 1)
+	assert.Len(t, store.changed([]model.Opportunity{rotated}), 0)
+
+	// Saved again after it is gone
+	assert.Len(t, store.changed(nil), 0)
+	assert.Len(t, store.changed([]model.Opportunity{btc}), 1)
+
+}
+
+func TestStore_Prune(t *testing.T) {
+
+	store, dir := openTestStore(t)
+	defer os.RemoveAll(dir)
+
+	ctx := context.Background()
+	start := time.Date(2019, 2, 1, 10, 0, 0, 0, time.UTC)
+	route := []model.Currency{"USD", "BTC", "USD"}
+
+	store.SetRetention(time.Hour)
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, store.Save(model.Opportunity{Time: start.Add(time.Duration(i) * time.Hour), Route: route}))
+	}
+
+	// Pruned once per interval
+	assert.Nil(t, store.prune(start.Add(2*time.Hour)))
+	assert.Nil(t, store.Save(model.Opportunity{Time: start.Add(150 * time.Minute), Route: route}))
+	assert.Nil(t, store.prune(start.Add(150*time.Minute)))
+
+	list, err := store.GetHistory(ctx, service.HistoryQuery{})
+	assert.Nil(t, err)
+	assert.Len(t, list, 3)
+
+	list, err = store.GetHistory(ctx, service.HistoryQuery{Currency: "BTC"})
+	assert.Nil(t, err)
+	if assert.Len(t, list, 3) {
+		assert.Equal(t, start.Add(time.Hour).Unix(), list[2].Time.Unix())
+	}
+
+	// Currency index is built for an old database
+	assert.Nil(t, store.db.Update(func(tx *bolt.Tx) error {
+		return tx.DeleteBucket(currencyBucketName)
+	}))
+	assert.Nil(t, store.Close())
+
+	store, err = Open(filepath.Join(dir, "history.db"))
+	assert.Nil(t, err)
+	defer store.Close()
+
+	list, err = store.GetHistory(ctx, service.HistoryQuery{Currency: "USD"})
+	assert.Nil(t, err)
+	assert.Len(t, list, 3)
+
+}
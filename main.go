@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -10,19 +11,36 @@ import (
 	"strconv"
 	"syscall"
 	"time"
+	"github.com/tusupov/exmoarbitrage/alert"
 	"github.com/tusupov/exmoarbitrage/api"
+	"github.com/tusupov/exmoarbitrage/backtest"
 	"github.com/tusupov/exmoarbitrage/config"
+	"github.com/tusupov/exmoarbitrage/history"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/recorder"
 	"github.com/tusupov/exmoarbitrage/route"
 	"github.com/tusupov/exmoarbitrage/service"
+	"github.com/tusupov/exmoarbitrage/telegram"
 )
 
 func main() {
 
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "backtest" {
+		if err := backtest.Command(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	stop := make(chan os.Signal, 1)
 	signal.Notify(stop, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 
 	// Server config
-	cfg := config.Init()
+	cfg, err := config.Init()
+	if err != nil {
+		log.Fatal(err)
+	}
 	abs, err := filepath.Abs(cfg.TemplateDirectory)
 	if err != nil {
 		log.Fatal(err)
@@ -33,13 +51,192 @@ func main() {
 	client := &http.Client{
 		Timeout: 10 * time.Second,
 	}
-	exmoApi := api.NewExmo(api.ExmoBaseUrl, client)
+	exchange, err := newExchange(cfg.Exchange, cfg.ExchangeUrl, client, cfg.OrderDepth)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Exchange: %s", exchange.Name())
+
+	// Background market data refresh
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var marketApi api.Apier = exchange
+	if cfg.Replay != "" {
+
+		// Recorded history instead of the exchange
+		files, err := recorder.Files(cfg.Replay)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// Records are read as the replay reaches them
+		records := recorder.OpenFiles(files...)
+		defer records.Close()
+
+		replay, err := api.NewReplay(records, cfg.ReplaySpeed)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Replay %d files from: %s", len(files), cfg.Replay)
+
+		marketApi = replay
+
+	} else if cfg.Stream {
+
+		// Order books from websocket
+		if exchange.Name() != "exmo" {
+			log.Fatalf("Stream is not supported by exchange: %s", exchange.Name())
+		}
+
+		pairList, err := exchange.GetPairList(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		pairs := make([]model.Pair, 0, len(pairList))
+		for pair := range pairList {
+			pairs = append(pairs, pair)
+		}
+
+		stream := api.NewExmoStream(api.ExmoStreamUrl, cfg.OrderDepth)
+		go stream.Run(ctx, pairs...)
+
+		marketApi = api.NewStreamApi(exchange, stream)
+
+	}
 
 	// Service
-	serviceApi := service.NewArbitrage(exmoApi)
+	serviceApi := service.NewArbitrage(marketApi)
+	serviceApi.SetFee(cfg.Fee)
+
+	poller := service.NewPoller(serviceApi, cfg.PollInterval)
+	go poller.Run(ctx)
+
+	// Market history
+	recorded := make(chan struct{})
+	if cfg.RecordDirectory != "" {
+		log.Printf("Recording market data to: %s", cfg.RecordDirectory)
+		go func() {
+			recorder.NewRecorder(cfg.RecordDirectory).Run(ctx, poller)
+			close(recorded)
+		}()
+	} else {
+		close(recorded)
+	}
+
+	// Opportunity history
+	var historian service.Historian
+	saved := make(chan struct{})
+	if cfg.History != "" {
+		store, err := history.Open(cfg.History)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer store.Close()
+		store.SetRetention(cfg.HistoryRetention)
+		log.Printf("Saving opportunity history to: %s", cfg.History)
+		go func() {
+			store.Run(ctx, poller)
+			close(saved)
+		}()
+		historian = store
+	} else {
+		close(saved)
+	}
+
+	// Webhook alerts
+	if len(cfg.AlertWebhooks) > 0 {
+		alerter := alert.NewAlerter(client, cfg.AlertWebhooks, cfg.AlertRule)
+		alerter.SetInterval(cfg.AlertInterval)
+		alerter.SetGrace(cfg.AlertGrace)
+		alerter.SetRetry(cfg.AlertRetries, time.Second)
+		go alerter.Run(ctx, poller)
+		log.Printf("Alerts to %d webhooks", len(cfg.AlertWebhooks))
+	}
+
+	// Telegram bot, long polling needs a longer timeout
+	if cfg.TelegramToken != "" {
+		bot := telegram.NewBot(cfg.TelegramUrl, &http.Client{Timeout: time.Minute}, cfg.TelegramToken, cfg.TelegramChat, poller)
+		bot.SetMinProfit(cfg.TelegramMinProfit)
+		go func() {
+			if err := bot.Run(ctx, poller); err != nil {
+				log.Printf("Telegram error: %v\n", err)
+			}
+		}()
+		log.Printf("Telegram bot for chat: %s", cfg.TelegramChat)
+	}
+
+	// Route execution with the account or simulated orders
+	var trader api.Trader
+	if cfg.Paper {
+		paperTrader := api.NewPaperTrader(marketApi, cfg.PaperBalances)
+		paperTrader.SetFee(cfg.Fee)
+		paperTrader.SetLatency(cfg.PaperLatency)
+		trader = paperTrader
+		log.Println("Paper trading")
+	} else if cfg.TradeKey != "" {
+		if exchange.Name() != "exmo" {
+			log.Fatalf("Trading is not supported by exchange: %s", exchange.Name())
+		}
+		trader = api.NewExmoTrader(api.ExmoBaseUrl, client, cfg.TradeKey, cfg.TradeSecret)
+	}
+
+	var executor service.Executer
+	var exmoExecutor *service.Executor
+	if trader != nil {
+		exmoExecutor = service.NewExecutor(marketApi, trader)
+		exmoExecutor.SetFee(cfg.Fee)
+		executor = exmoExecutor
+	}
+
+	// Cross-exchange arbitrage on background snapshots of every exchange,
+	// the market poller is reused for its exchange unless it replays history
+	var cross service.CrossServicer
+	if len(cfg.CrossExchanges) > 1 {
+
+		crossService := service.NewCrossArbitrage()
+		for _, name := range cfg.CrossExchanges {
+
+			fee := cfg.Fee
+			if rate, ok := cfg.CrossFees[name]; ok {
+				fee.Taker = rate
+			}
+			fee.Withdraw = cfg.CrossWithdraw[name]
+
+			market := poller
+			if name != exchange.Name() || cfg.Replay != "" {
+				crossExchange, err := newExchange(name, "", client, cfg.OrderDepth)
+				if err != nil {
+					log.Fatal(err)
+				}
+				crossArbitrage := service.NewArbitrage(crossExchange)
+				crossArbitrage.SetFee(fee)
+				market = service.NewPoller(crossArbitrage, cfg.PollInterval)
+				go market.Run(ctx)
+			}
+
+			crossService.AddExchange(name, market)
+			crossService.SetFee(name, fee)
+
+		}
+		crossService.SetTransferTime(cfg.CrossTransferTime)
+
+		cross = crossService
+		log.Printf("Cross-exchange arbitrage: %v", cfg.CrossExchanges)
+
+	}
 
 	// Init route and view templates
-	router, err := route.Init(cfg, serviceApi)
+	router, err := route.Init(cfg, route.Options{
+		Service:   poller,
+		Notifier:  poller,
+		Refresher: poller,
+		Executor:  executor,
+		Historian: historian,
+		Cross:     cross,
+		Context:   ctx,
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -61,8 +258,33 @@ func main() {
 	// Waiting stop signal
 	<-stop
 
-	// Safe shutdown server
+	// Safe shutdown server, running executions finish their orders
+	// while market data is still refreshed
 	shutdown(srv, time.Second*59)
+	if exmoExecutor != nil {
+		exmoExecutor.Wait()
+	}
+
+	// Stop background refresh, wait for the history files to be written
+	cancel()
+	<-recorded
+	<-saved
+
+}
+
+// Exchange adapter with order book depth
+func newExchange(name, baseUrl string, client *http.Client, depth int) (exchange api.Exchange, err error) {
+
+	exchange, err = api.NewExchange(name, baseUrl, client)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %s, available: %v", err, name, api.ExchangeNames())
+	}
+
+	if depthSetter, ok := exchange.(api.DepthSetter); ok {
+		err = depthSetter.SetOrderDepth(depth)
+	}
+
+	return
 
 }
 
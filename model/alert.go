@@ -0,0 +1,25 @@
+package model
+
+import (
+	"time"
+)
+
+// Condition of an arbitrage alert, zero fields do not filter
+type AlertRule struct {
+	Name        string
+	MinProfit   float64       // min net profit, percent
+	MinVolume   float64       // min available volume in route start currency
+	Currencies  []Currency    // route goes through any of the currencies
+	Persistence time.Duration // min time the route has been seen for
+}
+
+// Route matched an alert rule
+type Alert struct {
+	Rule      string     `json:"rule"`
+	Time      time.Time  `json:"time"` // snapshot time
+	Route     []Currency `json:"route"`
+	Profit    float64    `json:"profit"`     // profit ratio without fees
+	NetProfit float64    `json:"net_profit"` // profit ratio after fees
+	Volume    float64    `json:"volume"`
+	FirstSeen *time.Time `json:"first_seen,omitempty"` // nil for not tracked route
+}
@@ -1,6 +1,73 @@
 package model
 
+import (
+	"time"
+)
+
 type Arbitrage struct {
-	Profit float64
-	Route  []Currency
+	Profit    float64    `json:"profit"`     // gross profit ratio
+	NetProfit float64    `json:"net_profit"` // profit ratio after trading fees, and the withdrawal spread over Volume if Volume > 0
+	Route     []Currency `json:"route"`
+
+	Volume          float64 `json:"volume"`            // max profitable volume in start currency, 0 - unprofitable
+	VolumeProfit    float64 `json:"volume_profit"`     // gross profit at Volume in start currency
+	NetVolumeProfit float64 `json:"net_volume_profit"` // profit at Volume after trading and withdrawal fees, 0 if Volume is 0
+
+	Violations []LimitViolation `json:"violations,omitempty"` // exchange limits the route breaks at Volume
+
+	Lifetime *Lifetime `json:"lifetime,omitempty"` // presence in consecutive snapshots, if tracked
+
+	Legs []Leg `json:"legs,omitempty"` // route steps with their exchanges, cross-exchange routes only
+}
+
+// Route presence in consecutive market snapshots
+type Lifetime struct {
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	PeakProfit float64   `json:"peak_profit"` // max net profit ratio
+	AvgProfit  float64   `json:"avg_profit"`  // mean net profit ratio
+	Seen       int       `json:"seen"`        // snapshots count
+}
+
+// Time the route has been seen for
+func (l Lifetime) Duration() time.Duration {
+	return l.LastSeen.Sub(l.FirstSeen)
+}
+
+// Currency on an exchange
+type Venue struct {
+	Exchange string   `json:"exchange"`
+	Currency Currency `json:"currency"`
+}
+
+func (v Venue) String() string {
+	return v.Exchange + ":" + string(v.Currency)
+}
+
+// Route step: an order on one exchange or a transfer between exchanges
+type Leg struct {
+	From         Venue         `json:"from"`
+	To           Venue         `json:"to"`
+	Pair         Pair          `json:"pair,omitempty"`          // traded pair, empty for a transfer
+	Rate         float64       `json:"rate"`                    // top of book rate after trading fee
+	Withdraw     float64       `json:"withdraw,omitempty"`      // transfer withdrawal fee in currency
+	TransferTime time.Duration `json:"transfer_time,omitempty"` // expected transfer time, 0 - unknown
+}
+
+// Leg moves currency between exchanges
+func (l Leg) Transfer() bool {
+	return l.From.Exchange != l.To.Exchange
+}
+
+// Total expected transfer time of the route legs
+func (a Arbitrage) TransferTime() (d time.Duration) {
+	for _, leg := range a.Legs {
+		d += leg.TransferTime
+	}
+	return
+}
+
+// Route can be executed on the exchange at Volume
+func (a Arbitrage) Executable() bool {
+	return len(a.Violations) == 0
 }
@@ -0,0 +1,88 @@
+package model
+
+import (
+	"time"
+)
+
+// Execution states:
+// pending -> planned -> running -> completed
+// running -> unwinding -> unwound
+// any not finished state -> failed
+type ExecutionState string
+
+const (
+	ExecutionPending   ExecutionState = "pending"   // created, legs not computed
+	ExecutionPlanned   ExecutionState = "planned"   // legs computed from order books
+	ExecutionRunning   ExecutionState = "running"   // orders are placed
+	ExecutionUnwinding ExecutionState = "unwinding" // a leg failed, converting back to start currency
+	ExecutionCompleted ExecutionState = "completed" // all legs filled
+	ExecutionUnwound   ExecutionState = "unwound"   // all holdings converted back to start currency
+	ExecutionFailed    ExecutionState = "failed"    // stopped, some holdings may be left
+)
+
+// Execution reached its final state
+func (s ExecutionState) Finished() bool {
+	return s == ExecutionCompleted || s == ExecutionUnwound || s == ExecutionFailed
+}
+
+type LegState string
+
+const (
+	LegPending   LegState = "pending"   // order is not placed yet
+	LegPlaced    LegState = "placed"    // order is open
+	LegFilled    LegState = "filled"    // order is closed, something is filled
+	LegCancelled LegState = "cancelled" // order is cancelled without fills
+	LegFailed    LegState = "failed"    // order is rejected
+)
+
+// One order of the execution route
+type ExecutionLeg struct {
+	Pair  Pair      `json:"pair"`
+	From  Currency  `json:"from"`
+	To    Currency  `json:"to"`
+	Type  OrderType `json:"type"`
+	State LegState  `json:"state"`
+
+	Quantity float64 `json:"quantity"` // order quantity
+	Price    float64 `json:"price"`    // order limit price, 0 for market orders
+	In       float64 `json:"in"`       // planned input in From currency
+	Out      float64 `json:"out"`      // expected output in To currency, after fee
+
+	OrderId  int64   `json:"order_id,omitempty"`
+	Spent    float64 `json:"spent"`    // filled input in From currency
+	Received float64 `json:"received"` // filled output in To currency, after fee
+}
+
+// Execution log record, Leg is the leg number starting from 1, 0 for the whole execution
+type ExecutionEvent struct {
+	Time    time.Time      `json:"time"`
+	State   ExecutionState `json:"state"`
+	Leg     int            `json:"leg,omitempty"`
+	Message string         `json:"message"`
+}
+
+// Arbitrage route execution
+type Execution struct {
+	Id       int64          `json:"id"`
+	Route    []Currency     `json:"route"`
+	Volume   float64        `json:"volume"` // input of the first leg in start currency
+	State    ExecutionState `json:"state"`
+	Parallel bool           `json:"parallel"` // legs are placed at once from available balances
+
+	Legs    []ExecutionLeg       `json:"legs"`
+	Unwind  []ExecutionLeg       `json:"unwind,omitempty"`
+	Events  []ExecutionEvent     `json:"events"`
+	Holding map[Currency]float64 `json:"holding"` // balance changes made by the execution
+
+	Created  time.Time `json:"created"`
+	Finished time.Time `json:"finished,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Result in start currency, profit if positive
+func (e Execution) Profit() float64 {
+	if len(e.Route) == 0 {
+		return 0
+	}
+	return e.Holding[e.Route[0]]
+}
@@ -0,0 +1,24 @@
+package model
+
+type Fee struct {
+	Taker float64 // market order commission rate
+	Maker float64 // limit order commission rate, paper trading resting orders only
+}
+
+type FeeSettings struct {
+	Fee // default commission
+
+	Pairs    map[Pair]Fee         // per-pair commission overrides
+	Withdraw map[Currency]float64 // fixed withdrawal fee in currency
+}
+
+func (f FeeSettings) GetFee(pair Pair) Fee {
+	if fee, ok := f.Pairs[pair]; ok {
+		return fee
+	}
+	return f.Fee
+}
+
+func (f FeeSettings) GetWithdraw(currency Currency) float64 {
+	return f.Withdraw[currency]
+}
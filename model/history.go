@@ -0,0 +1,14 @@
+package model
+
+import (
+	"time"
+)
+
+// Profitable route found in a snapshot, an entry of the history store
+type Opportunity struct {
+	Time      time.Time  `json:"time"`
+	Route     []Currency `json:"route"`
+	Profit    float64    `json:"profit"`     // profit ratio without fees
+	NetProfit float64    `json:"net_profit"` // profit ratio after fees
+	Volume    float64    `json:"volume"`     // available volume in route start currency
+}
@@ -0,0 +1,27 @@
+package model
+
+import (
+	"fmt"
+)
+
+// Exchange limit names, as in pair_settings
+const (
+	LimitMinQuantity = "min_quantity"
+	LimitMaxQuantity = "max_quantity"
+	LimitMinPrice    = "min_price"
+	LimitMaxPrice    = "max_price"
+	LimitMinAmount   = "min_amount"
+	LimitMaxAmount   = "max_amount"
+)
+
+// Route leg which breaks an exchange pair limit
+type LimitViolation struct {
+	Pair  Pair    `json:"pair"`
+	Limit string  `json:"limit"` // limit name
+	Value float64 `json:"value"` // leg trade value
+	Bound float64 `json:"bound"` // exchange limit value
+}
+
+func (v LimitViolation) String() string {
+	return fmt.Sprintf("%s %s: %g, limit %g", v.Pair, v.Limit, v.Value, v.Bound)
+}
@@ -17,12 +17,15 @@ func (po PairOrders) Exists(pair Pair) bool {
 }
 
 type Order struct {
-	Ask Offer
-	Bid Offer
+	Ask Offer `json:"ask"` // best ask
+	Bid Offer `json:"bid"` // best bid
+
+	Asks []Offer `json:"asks"` // ask ladder, price ascending
+	Bids []Offer `json:"bids"` // bid ladder, price descending
 }
 
 type Offer struct {
-	Price float64
-	//Quantity float64 `json:",string"`
-	//Amount   float64 `json:",string"`
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+	Amount   float64 `json:"amount"`
 }
@@ -22,4 +22,6 @@ type Setting struct {
 	MaxPrice    float64 `json:"max_price,string"`
 	MinAmount   float64 `json:"min_amount,string"`
 	MaxAmount   float64 `json:"max_amount,string"`
+
+	PricePrecision int `json:"price_precision"` // price decimal places, 0 - not set
 }
@@ -0,0 +1,13 @@
+package model
+
+import (
+	"time"
+)
+
+// Recorded market data, one line of a market history file
+type MarketRecord struct {
+	Time       time.Time    `json:"time"`
+	Currencies []Currency   `json:"currencies"`
+	Pairs      PairSettings `json:"pairs"`
+	Orders     PairOrders   `json:"orders"`
+}
@@ -0,0 +1,15 @@
+package model
+
+import (
+	"time"
+)
+
+// Market state at a moment with arbitrage found in it.
+// Shared between readers, must not be modified.
+type Snapshot struct {
+	Time       time.Time    `json:"time"`
+	Currencies []Currency   `json:"currencies"`
+	Pairs      PairSettings `json:"pairs"`
+	Orders     PairOrders   `json:"orders"`
+	Arbitrage  []Arbitrage  `json:"arbitrage"` // all profitable cycles, unfiltered
+}
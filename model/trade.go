@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+)
+
+// Exchange order types
+type OrderType string
+
+const (
+	OrderBuy             OrderType = "buy"               // limit buy
+	OrderSell            OrderType = "sell"              // limit sell
+	OrderMarketBuy       OrderType = "market_buy"        // market buy of quantity
+	OrderMarketSell      OrderType = "market_sell"       // market sell of quantity
+	OrderMarketBuyTotal  OrderType = "market_buy_total"  // market buy for amount of quote currency
+	OrderMarketSellTotal OrderType = "market_sell_total" // market sell for amount of quote currency
+)
+
+// Account balances
+type UserInfo struct {
+	UserId     int64                `json:"uid"`
+	ServerTime time.Time            `json:"server_time"`
+	Balances   map[Currency]float64 `json:"balances"` // available
+	Reserved   map[Currency]float64 `json:"reserved"` // held by open orders
+}
+
+// New order parameters, price is ignored by market orders
+type OrderRequest struct {
+	Pair     Pair      `json:"pair"`
+	Type     OrderType `json:"type"`
+	Quantity float64   `json:"quantity"`
+	Price    float64   `json:"price"`
+}
+
+type OpenOrder struct {
+	OrderId  int64     `json:"order_id"`
+	Created  time.Time `json:"created"`
+	Type     OrderType `json:"type"`
+	Pair     Pair      `json:"pair"`
+	Price    float64   `json:"price"`
+	Quantity float64   `json:"quantity"`
+	Amount   float64   `json:"amount"`
+}
+
+// Executed part of an order
+type Trade struct {
+	TradeId  int64     `json:"trade_id"`
+	Date     time.Time `json:"date"`
+	Type     OrderType `json:"type"`
+	Pair     Pair      `json:"pair"`
+	OrderId  int64     `json:"order_id"`
+	Price    float64   `json:"price"`
+	Quantity float64   `json:"quantity"`
+	Amount   float64   `json:"amount"`
+}
@@ -0,0 +1,217 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Max line of a market history file, one line has all order books
+const maxLineSize = 64 * 1024 * 1024
+
+// Reads market records from a JSON lines file, gzip compressed if named *.gz
+type Reader struct {
+	path    string
+	file    *os.File
+	gz      *gzip.Reader
+	scanner *bufio.Scanner
+}
+
+func Open(path string) (r *Reader, err error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+
+	r = &Reader{path: path, file: file}
+
+	var source io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		if r.gz, err = gzip.NewReader(file); err != nil {
+			file.Close()
+			return nil, err
+		}
+		source = r.gz
+	}
+
+	r.scanner = bufio.NewScanner(source)
+	r.scanner.Buffer(make([]byte, 0, 1024*1024), maxLineSize)
+
+	return
+
+}
+
+// Next record, io.EOF at the end of file.
+// A gzip file without trailer is being written or its recorder crashed:
+// it ends after the last complete record and is logged.
+func (r *Reader) Next() (record model.MarketRecord, err error) {
+
+	for r.scanner.Scan() {
+
+		line := r.scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		// Last line of a truncated file may be cut
+		if err = json.Unmarshal(line, &record); err != nil && r.scanner.Err() == io.ErrUnexpectedEOF {
+			record = model.MarketRecord{}
+			break
+		}
+		return
+
+	}
+
+	err = r.scanner.Err()
+	if err == io.ErrUnexpectedEOF {
+		log.Printf("Reader: %s is truncated, read up to the last complete record\n", r.path)
+	}
+	if err == nil || err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+
+	return
+
+}
+
+func (r *Reader) Close() error {
+
+	if r.gz != nil {
+		r.gz.Close()
+	}
+
+	return r.file.Close()
+
+}
+
+// Reads records of several files in order, one file is open at a time
+type FilesReader struct {
+	paths  []string
+	reader *Reader
+}
+
+func OpenFiles(paths ...string) *FilesReader {
+	return &FilesReader{
+		paths: paths,
+	}
+}
+
+// Next record, io.EOF at the end of the last file
+func (r *FilesReader) Next() (record model.MarketRecord, err error) {
+
+	for {
+
+		if r.reader == nil {
+			if len(r.paths) == 0 {
+				err = io.EOF
+				return
+			}
+			if r.reader, err = Open(r.paths[0]); err != nil {
+				return
+			}
+			r.paths = r.paths[1:]
+		}
+
+		if record, err = r.reader.Next(); err != io.EOF {
+			return
+		}
+
+		r.reader.Close()
+		r.reader = nil
+
+	}
+
+}
+
+func (r *FilesReader) Close() error {
+
+	if r.reader == nil {
+		return nil
+	}
+
+	err := r.reader.Close()
+	r.reader = nil
+
+	return err
+
+}
+
+// History files of dir, oldest day first, segments of a day in order.
+// A path which is not a directory is returned as is.
+func Files(path string) ([]string, error) {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(path, FilePrefix+"*"+FileExt))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		dayI, segmentI := fileDay(files[i])
+		dayJ, segmentJ := fileDay(files[j])
+		if dayI != dayJ {
+			return dayI < dayJ
+		}
+		return segmentI < segmentJ
+	})
+
+	return files, nil
+
+}
+
+// Files with records in [from, to] by their day name, zero time is no bound.
+// Files without a day name are kept.
+func Between(files []string, from, to time.Time) []string {
+
+	result := make([]string, 0, len(files))
+	for _, file := range files {
+
+		name, _ := fileDay(file)
+		day, err := time.Parse(dayFormat, name)
+		if err == nil {
+			if !from.IsZero() && !day.AddDate(0, 0, 1).After(from) {
+				continue
+			}
+			if !to.IsZero() && day.After(to) {
+				continue
+			}
+		}
+
+		result = append(result, file)
+
+	}
+
+	return result
+
+}
+
+// Day name and segment of a history file
+func fileDay(file string) (day string, segment int) {
+
+	day = strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), FilePrefix), FileExt)
+	if i := strings.IndexByte(day, '.'); i >= 0 {
+		segment, _ = strconv.Atoi(day[i+1:])
+		day = day[:i]
+	}
+
+	return
+
+}
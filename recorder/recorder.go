@@ -0,0 +1,229 @@
+package recorder
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+)
+
+const (
+	FilePrefix = "market-"   // market-2006-01-02.jsonl.gz, market-2006-01-02.1.jsonl.gz
+	FileExt    = ".jsonl.gz" // gzip compressed JSON lines
+	dayFormat  = "2006-01-02"
+)
+
+// Snapshots waiting to be written, newer ones are dropped while it is full
+const queueSize = 32
+
+// Appends market records to daily gzip compressed JSON lines files.
+// A file is reopened for append after restart, gzip readers
+// read the appended streams as one. A file left without gzip trailer
+// by a crash is not appended, records go to the next segment of the day.
+type Recorder struct {
+	dir string
+
+	mu   sync.Mutex
+	day  string
+	file *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+}
+
+func NewRecorder(dir string) *Recorder {
+	return &Recorder{
+		dir: dir,
+	}
+}
+
+// Record every snapshot of notifier until its updates are closed or ctx is done
+func (r *Recorder) Run(ctx context.Context, notifier service.QueueNotifier) {
+
+	updates, unsubscribe := notifier.SubscribeQueue(queueSize)
+	defer unsubscribe()
+	defer r.Close()
+
+	for {
+		select {
+
+		case <-ctx.Done():
+			return
+
+		case snapshot, ok := <-updates:
+			if !ok {
+				return
+			}
+
+			err := r.Write(model.MarketRecord{
+				Time:       snapshot.Time,
+				Currencies: snapshot.Currencies,
+				Pairs:      snapshot.Pairs,
+				Orders:     snapshot.Orders,
+			})
+			if err != nil {
+				log.Printf("Recorder error: %v\n", err)
+			}
+
+		}
+	}
+
+}
+
+// Append record to the file of its UTC day, record is flushed to disk
+func (r *Recorder) Write(record model.MarketRecord) (err error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	day := record.Time.UTC().Format(dayFormat)
+	if day != r.day {
+		if err = r.rotate(day); err != nil {
+			return
+		}
+	}
+
+	if err = r.enc.Encode(record); err != nil {
+		return
+	}
+
+	return r.gz.Flush()
+
+}
+
+func (r *Recorder) Close() error {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.close()
+
+}
+
+// Close the current file and open the last segment of day
+func (r *Recorder) rotate(day string) (err error) {
+
+	if err = r.close(); err != nil {
+		return
+	}
+
+	if err = os.MkdirAll(r.dir, 0755); err != nil {
+		return
+	}
+
+	path, err := lastSegment(r.dir, day)
+	if err != nil {
+		return
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return
+	}
+
+	r.day = day
+	r.file = file
+	r.gz = gzip.NewWriter(file)
+	r.enc = json.NewEncoder(r.gz)
+
+	return
+
+}
+
+func (r *Recorder) close() error {
+
+	if r.file == nil {
+		return nil
+	}
+
+	err := r.gz.Close()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	r.day, r.file, r.gz, r.enc = "", nil, nil, nil
+
+	return err
+
+}
+
+// Segment of day to append to: the last existing one
+// or the next one if the last was not closed
+func lastSegment(dir, day string) (string, error) {
+
+	segment := 0
+	for {
+		if _, err := os.Stat(SegmentPath(dir, day, segment+1)); os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		segment++
+	}
+
+	path := SegmentPath(dir, day, segment)
+
+	ok, err := closed(path)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		log.Printf("Recorder: %s was not closed, new segment is started\n", path)
+		path = SegmentPath(dir, day, segment+1)
+	}
+
+	return path, nil
+
+}
+
+// Missing, empty or complete gzip file
+func closed(path string) (bool, error) {
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err == io.EOF {
+		return true, nil
+	}
+	if err != nil {
+		return false, nil
+	}
+	defer gz.Close()
+
+	if _, err = io.Copy(ioutil.Discard, gz); err != nil {
+		return false, nil
+	}
+
+	return true, nil
+
+}
+
+// File of the day in dir
+func FilePath(dir, day string) string {
+	return SegmentPath(dir, day, 0)
+}
+
+// Segment of the day file in dir, 0 - the day file itself
+func SegmentPath(dir, day string, segment int) string {
+
+	if segment == 0 {
+		return filepath.Join(dir, FilePrefix+day+FileExt)
+	}
+
+	return filepath.Join(dir, FilePrefix+day+"."+strconv.Itoa(segment)+FileExt)
+
+}
@@ -0,0 +1,193 @@
+package recorder
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+type testNotifier struct {
+	updates chan *model.Snapshot
+}
+
+func (n *testNotifier) SubscribeQueue(size int) (<-chan *model.Snapshot, func()) {
+	return n.updates, func() {}
+}
+
+func testRecord(t time.Time, price float64) model.MarketRecord {
+	return model.MarketRecord{
+		Time:       t,
+		Currencies: []model.Currency{"BTC", "USD"},
+		Pairs: model.PairSettings{
+			"BTC_USD": model.Setting{MinQuantity: 0.001, MaxQuantity: 100, MinPrice: 1, MaxPrice: 30000, MinAmount: 1, MaxAmount: 500000, PricePrecision: 2},
+		},
+		Orders: model.PairOrders{
+			"BTC_USD": model.Order{
+				Ask:  model.Offer{Price: price, Quantity: 1, Amount: price},
+				Bid:  model.Offer{Price: price - 10, Quantity: 1, Amount: price - 10},
+				Asks: []model.Offer{{Price: price, Quantity: 1, Amount: price}},
+				Bids: []model.Offer{{Price: price - 10, Quantity: 1, Amount: price - 10}},
+			},
+		},
+	}
+}
+
+// All records of the files
+func readFiles(paths ...string) (records []model.MarketRecord, err error) {
+
+	reader := OpenFiles(paths...)
+	defer reader.Close()
+
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+}
+
+func TestRecorder(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "recorder")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	day := time.Date(2019, 1, 10, 23, 59, 0, 0, time.UTC)
+	records := []model.MarketRecord{
+		testRecord(day, 3700),
+		testRecord(day.Add(30*time.Second), 3710),
+		testRecord(day.Add(2*time.Minute), 3720),
+	}
+
+	recorder := NewRecorder(dir)
+	assert.Nil(t, recorder.Write(records[0]))
+	assert.Nil(t, recorder.Close())
+
+	// Append after restart and rotate on the next day
+	recorder = NewRecorder(dir)
+	assert.Nil(t, recorder.Write(records[1]))
+	assert.Nil(t, recorder.Write(records[2]))
+	assert.Nil(t, recorder.Close())
+
+	files, err := Files(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "market-2019-01-10.jsonl.gz"),
+		filepath.Join(dir, "market-2019-01-11.jsonl.gz"),
+	}, files)
+
+	result, err := readFiles(files...)
+	assert.Nil(t, err)
+	assert.Equal(t, records, result)
+
+	// Files of the range by day name
+	assert.Equal(t, files[1:], Between(files, day.Add(time.Minute), time.Time{}))
+	assert.Equal(t, files[:1], Between(files, time.Time{}, day))
+	assert.Equal(t, files, Between(files, day, day.Add(time.Minute)))
+
+	// Single file path
+	files, err = Files(files[1])
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+
+}
+
+func TestRecorder_Run(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "recorder")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	record := testRecord(time.Date(2019, 1, 10, 12, 0, 0, 0, time.UTC), 3700)
+
+	notifier := &testNotifier{updates: make(chan *model.Snapshot, 1)}
+	notifier.updates <- &model.Snapshot{
+		Time:       record.Time,
+		Currencies: record.Currencies,
+		Pairs:      record.Pairs,
+		Orders:     record.Orders,
+		Arbitrage:  []model.Arbitrage{{Profit: 1.01}},
+	}
+	close(notifier.updates)
+
+	NewRecorder(dir).Run(context.Background(), notifier)
+
+	result, err := readFiles(FilePath(dir, "2019-01-10"))
+	assert.Nil(t, err)
+	assert.Equal(t, []model.MarketRecord{record}, result)
+
+}
+
+func TestReader_truncated(t *testing.T) {
+
+	dir, err := ioutil.TempDir("", "recorder")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	day := time.Date(2019, 1, 10, 12, 0, 0, 0, time.UTC)
+	records := []model.MarketRecord{
+		testRecord(day, 3700),
+		testRecord(day.Add(time.Minute), 3710),
+		testRecord(day.Add(2*time.Minute), 3720),
+	}
+
+	// Flushed but not closed, as after a crash
+	recorder := NewRecorder(dir)
+	assert.Nil(t, recorder.Write(records[0]))
+
+	reader, err := Open(FilePath(dir, "2019-01-10"))
+	if assert.Nil(t, err) {
+		record, err := reader.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, records[0], record)
+		_, err = reader.Next()
+		assert.Equal(t, io.EOF, err)
+		reader.Close()
+	}
+
+	// Crash in the middle of a line
+	recorder.gz.Write([]byte(`{"time":"2019-01-10T12:01:00Z","curr`))
+	recorder.gz.Flush()
+
+	result, err := readFiles(FilePath(dir, "2019-01-10"))
+	assert.Nil(t, err)
+	assert.Equal(t, records[:1], result)
+
+	// Restart goes on in the next segment, which is appended after a clean close
+	recorder = NewRecorder(dir)
+	assert.Nil(t, recorder.Write(records[1]))
+	assert.Nil(t, recorder.Close())
+
+	recorder = NewRecorder(dir)
+	assert.Nil(t, recorder.Write(records[2]))
+	assert.Nil(t, recorder.Close())
+
+	files, err := Files(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		FilePath(dir, "2019-01-10"),
+		SegmentPath(dir, "2019-01-10", 1),
+	}, files)
+	assert.Equal(t, files, Between(files, day, day))
+
+	result, err = readFiles(files[1])
+	assert.Nil(t, err)
+	assert.Equal(t, records[1:], result)
+
+	// Truncated file ends after its complete records, the next file is read
+	result, err = readFiles(files...)
+	assert.Nil(t, err)
+	assert.Equal(t, records, result)
+
+}
@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"github.com/gorilla/mux"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+)
+
+var (
+	errInvalidPair  = errors.New("pair must be in BASE_QUOTE format")
+	errPairNotFound = errors.New("pair not found")
+)
+
+// JSON API v1
+type Api struct {
+	service service.Servicer
+}
+
+func NewApi(service service.Servicer) *Api {
+	return &Api{
+		service: service,
+	}
+}
+
+// Pair settings with numeric fields
+type pairSetting struct {
+	Pair        model.Pair `json:"pair"`
+	MinQuantity float64    `json:"min_quantity"`
+	MaxQuantity float64    `json:"max_quantity"`
+	MinPrice    float64    `json:"min_price"`
+	MaxPrice    float64    `json:"max_price"`
+	MinAmount   float64    `json:"min_amount"`
+	MaxAmount   float64    `json:"max_amount"`
+}
+
+type orderBook struct {
+	Pair model.Pair `json:"pair"`
+	model.Order
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+func (c *Api) Arbitrage(w http.ResponseWriter, r *http.Request) {
+
+	query, err := parseArbitrageQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	arbitrageList, err := c.service.GetArbitrage(r.Context(), query)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	if arbitrageList == nil {
+		arbitrageList = []model.Arbitrage{}
+	}
+
+	writeJSON(w, http.StatusOK, arbitrageList)
+
+}
+
+func (c *Api) Currencies(w http.ResponseWriter, r *http.Request) {
+
+	currencyList, err := c.service.GetCurrencyList(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	if currencyList == nil {
+		currencyList = []model.Currency{}
+	}
+
+	writeJSON(w, http.StatusOK, currencyList)
+
+}
+
+func (c *Api) Pairs(w http.ResponseWriter, r *http.Request) {
+
+	pairList, err := c.service.GetPairList(r.Context())
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	list := make([]pairSetting, 0, len(pairList))
+	for pair, setting := range pairList {
+		list = append(list, pairSetting{
+			Pair:        pair,
+			MinQuantity: setting.MinQuantity,
+			MaxQuantity: setting.MaxQuantity,
+			MinPrice:    setting.MinPrice,
+			MaxPrice:    setting.MaxPrice,
+			MinAmount:   setting.MinAmount,
+			MaxAmount:   setting.MaxAmount,
+		})
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Pair < list[j].Pair
+	})
+
+	writeJSON(w, http.StatusOK, list)
+
+}
+
+func (c *Api) OrderBook(w http.ResponseWriter, r *http.Request) {
+
+	pair := model.Pair(strings.ToUpper(mux.Vars(r)["pair"]))
+	if _, _, ok := pair.Split(); !ok {
+		writeError(w, http.StatusBadRequest, errInvalidPair)
+		return
+	}
+
+	pairOrders, err := c.service.GetOrders(r.Context(), pair)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	order, ok := pairOrders.GetOrder(pair)
+	if !ok {
+		writeError(w, http.StatusNotFound, errPairNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, orderBook{
+		Pair:  pair,
+		Order: order,
+	})
+
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, apiError{Error: err.Error()})
+}
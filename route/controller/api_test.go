@@ -0,0 +1,121 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	testifyMock "github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+	"github.com/tusupov/exmoarbitrage/service/mock"
+)
+
+func newTestRouter(api *Api) *mux.Router {
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/arbitrage", api.Arbitrage)
+	router.HandleFunc("/api/v1/currencies", api.Currencies)
+	router.HandleFunc("/api/v1/pairs", api.Pairs)
+	router.HandleFunc("/api/v1/orderbook/{pair}", api.OrderBook)
+
+	return router
+
+}
+
+func doRequest(router http.Handler, url string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	return w
+}
+
+func TestApi_Arbitrage(t *testing.T) {
+
+	serviceMock := mock.NewArbitrage()
+	router := newTestRouter(NewApi(serviceMock))
+
+	serviceMock.On("GetArbitrage", testifyMock.Anything, service.ArbitrageQuery{Base: "USD", Limit: 1}).Return([]model.Arbitrage{
+		{
+			Profit:    1.01,
+			NetProfit: 1.005,
+			Route:     []model.Currency{"USD", "BTC", "USD"},
+			Volume:    100,
+			Violations: []model.LimitViolation{
+				{Pair: "BTC_USD", Limit: model.LimitMinAmount, Value: 1, Bound: 2},
+			},
+		},
+	}, nil)
+	serviceMock.On("GetArbitrage", testifyMock.Anything, service.ArbitrageQuery{}).Return([]model.Arbitrage(nil), nil)
+
+	w := doRequest(router, "/api/v1/arbitrage?base=usd&limit=1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `[{"profit":1.01,"net_profit":1.005,"route":["USD","BTC","USD"],"volume":100,"volume_profit":0,"net_volume_profit":0,"violations":[{"pair":"BTC_USD","limit":"min_amount","value":1,"bound":2}]}]`, w.Body.String())
+
+	w = doRequest(router, "/api/v1/arbitrage")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[]`, w.Body.String())
+
+	w = doRequest(router, "/api/v1/arbitrage?limit=x")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+}
+
+func TestApi_Currencies(t *testing.T) {
+
+	serviceMock := mock.NewArbitrage()
+	router := newTestRouter(NewApi(serviceMock))
+	serviceMock.On("GetCurrencyList", testifyMock.Anything).Return([]model.Currency{"USD", "BTC"}, nil)
+
+	w := doRequest(router, "/api/v1/currencies")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `["USD","BTC"]`, w.Body.String())
+
+}
+
+func TestApi_Pairs(t *testing.T) {
+
+	serviceMock := mock.NewArbitrage()
+	router := newTestRouter(NewApi(serviceMock))
+	serviceMock.On("GetPairList", testifyMock.Anything).Return(model.PairSettings{
+		"BTC_USD": {MinQuantity: 0.001, MaxQuantity: 1000, MinPrice: 1, MaxPrice: 30000, MinAmount: 1, MaxAmount: 500000},
+	}, nil)
+
+	w := doRequest(router, "/api/v1/pairs")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"pair":"BTC_USD","min_quantity":0.001,"max_quantity":1000,"min_price":1,"max_price":30000,"min_amount":1,"max_amount":500000}]`, w.Body.String())
+
+}
+
+func TestApi_OrderBook(t *testing.T) {
+
+	serviceMock := mock.NewArbitrage()
+	router := newTestRouter(NewApi(serviceMock))
+	serviceMock.On("GetOrders", testifyMock.Anything, []model.Pair{"BTC_USD"}).Return(model.PairOrders{
+		"BTC_USD": model.Order{
+			Ask:  model.Offer{Price: 3700, Quantity: 1, Amount: 3700},
+			Bid:  model.Offer{Price: 3600, Quantity: 2, Amount: 7200},
+			Asks: []model.Offer{{Price: 3700, Quantity: 1, Amount: 3700}},
+			Bids: []model.Offer{{Price: 3600, Quantity: 2, Amount: 7200}},
+		},
+	}, nil)
+	serviceMock.On("GetOrders", testifyMock.Anything, []model.Pair{"ETH_USD"}).Return(model.PairOrders{}, nil)
+	serviceMock.On("GetOrders", testifyMock.Anything, []model.Pair{"XRP_USD"}).Return(model.PairOrders(nil), errors.New("upstream"))
+
+	w := doRequest(router, "/api/v1/orderbook/btc_usd")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"pair":"BTC_USD","ask":{"price":3700,"quantity":1,"amount":3700},"bid":{"price":3600,"quantity":2,"amount":7200},"asks":[{"price":3700,"quantity":1,"amount":3700}],"bids":[{"price":3600,"quantity":2,"amount":7200}]}`, w.Body.String())
+
+	w = doRequest(router, "/api/v1/orderbook/ETH_USD")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":"pair not found"}`, w.Body.String())
+
+	w = doRequest(router, "/api/v1/orderbook/XRP_USD")
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+
+	w = doRequest(router, "/api/v1/orderbook/BTCUSD")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+}
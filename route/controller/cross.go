@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+)
+
+var errCrossSort = errors.New("cross-exchange routes are not tracked, `sort` must be profit")
+
+// Cross-exchange arbitrage JSON API
+type Cross struct {
+	service service.CrossServicer
+}
+
+func NewCross(service service.CrossServicer) *Cross {
+	return &Cross{
+		service: service,
+	}
+}
+
+func (c *Cross) Arbitrage(w http.ResponseWriter, r *http.Request) {
+
+	query, err := parseArbitrageQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if query.Sort == service.SortLifetime {
+		writeError(w, http.StatusBadRequest, errCrossSort)
+		return
+	}
+
+	arbitrageList, err := c.service.GetArbitrage(r.Context(), query)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+
+	if arbitrageList == nil {
+		arbitrageList = []model.Arbitrage{}
+	}
+
+	writeJSON(w, http.StatusOK, arbitrageList)
+
+}
@@ -0,0 +1,48 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	testifyMock "github.com/stretchr/testify/mock"
+	"net/http"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+	"github.com/tusupov/exmoarbitrage/service/mock"
+)
+
+func TestCross_Arbitrage(t *testing.T) {
+
+	serviceMock := mock.NewArbitrage()
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/cross", NewCross(serviceMock).Arbitrage)
+
+	serviceMock.On("GetArbitrage", testifyMock.Anything, service.ArbitrageQuery{Base: "USD"}).Return([]model.Arbitrage{
+		{
+			Profit:    1.02,
+			NetProfit: 1.01,
+			Route:     []model.Currency{"USD", "BTC", "BTC", "USD", "USD"},
+			Legs: []model.Leg{
+				{From: model.Venue{Exchange: "exmo", Currency: "USD"}, To: model.Venue{Exchange: "exmo", Currency: "BTC"}, Pair: "BTC_USD", Rate: 0.0002},
+				{From: model.Venue{Exchange: "exmo", Currency: "BTC"}, To: model.Venue{Exchange: "binance", Currency: "BTC"}, Rate: 1, Withdraw: 0.001, TransferTime: time.Second},
+			},
+		},
+	}, nil)
+	serviceMock.On("GetArbitrage", testifyMock.Anything, service.ArbitrageQuery{}).Return([]model.Arbitrage(nil), errors.New("binance: timeout"))
+
+	w := doRequest(router, "/api/v1/cross?base=usd")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[{"profit":1.02,"net_profit":1.01,"route":["USD","BTC","BTC","USD","USD"],"volume":0,"volume_profit":0,"net_volume_profit":0,"legs":[
+		{"from":{"exchange":"exmo","currency":"USD"},"to":{"exchange":"exmo","currency":"BTC"},"pair":"BTC_USD","rate":0.0002},
+		{"from":{"exchange":"exmo","currency":"BTC"},"to":{"exchange":"binance","currency":"BTC"},"rate":1,"withdraw":0.001,"transfer_time":1000000000}]}]`, w.Body.String())
+
+	w = doRequest(router, "/api/v1/cross")
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.JSONEq(t, `{"error":"binance: timeout"}`, w.Body.String())
+
+	w = doRequest(router, "/api/v1/cross?sort=lifetime")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+}
@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"github.com/gorilla/mux"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+)
+
+var (
+	errExecutionNotFound = errors.New("execution not found")
+	errApiToken          = errors.New("invalid api token")
+	errContentType       = errors.New("Content-Type must be application/json")
+)
+
+// Route executions API.
+// Orders run in the server context: they are not cancelled
+// when the client goes away, shutdown waits for them.
+type Execution struct {
+	ctx      context.Context
+	executor service.Executer
+	token    string // required in `Authorization: Bearer` to execute routes
+}
+
+func NewExecution(ctx context.Context, executor service.Executer, token string) *Execution {
+	return &Execution{
+		ctx:      ctx,
+		executor: executor,
+		token:    token,
+	}
+}
+
+type executionRequest struct {
+	Route  []model.Currency `json:"route"`
+	Volume float64          `json:"volume"`
+}
+
+// Executions log, the token is required as it shows the account orders
+func (c *Execution) List(w http.ResponseWriter, r *http.Request) {
+
+	if !c.authorized(r) {
+		writeError(w, http.StatusUnauthorized, errApiToken)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, c.executor.GetExecutions())
+
+}
+
+func (c *Execution) Get(w http.ResponseWriter, r *http.Request) {
+
+	if !c.authorized(r) {
+		writeError(w, http.StatusUnauthorized, errApiToken)
+		return
+	}
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	exec, ok := c.executor.GetExecution(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, errExecutionNotFound)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, exec)
+
+}
+
+// Execute route and respond with its final state.
+// Token and JSON content type keep browsers from posting cross-site forms.
+func (c *Execution) Create(w http.ResponseWriter, r *http.Request) {
+
+	if !c.authorized(r) {
+		writeError(w, http.StatusUnauthorized, errApiToken)
+		return
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, errContentType)
+		return
+	}
+
+	var req executionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	route := make([]model.Currency, 0, len(req.Route))
+	for _, currency := range req.Route {
+		route = append(route, model.Currency(strings.ToUpper(string(currency))))
+	}
+
+	exec, err := c.executor.Execute(c.ctx, route, req.Volume)
+	if err != nil && exec.Id == 0 {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, exec)
+
+}
+
+// Request has the configured api token, empty token rejects every request
+func (c *Execution) authorized(r *http.Request) bool {
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if c.token == "" || token == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(c.token)) == 1
+
+}
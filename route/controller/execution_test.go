@@ -0,0 +1,125 @@
+package controller
+
+import (
+	"context"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	testifyMock "github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+	"github.com/tusupov/exmoarbitrage/service/mock"
+)
+
+func newTestExecutionRouter(execution *Execution) *mux.Router {
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/executions", execution.List).Methods("GET")
+	router.HandleFunc("/api/v1/executions", execution.Create).Methods("POST")
+	router.HandleFunc("/api/v1/executions/{id}", execution.Get).Methods("GET")
+
+	return router
+
+}
+
+func doGetExecution(router http.Handler, token, url string) *httptest.ResponseRecorder {
+
+	r := httptest.NewRequest(http.MethodGet, url, nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	return w
+
+}
+
+func doExecute(router http.Handler, token, contentType, body string) *httptest.ResponseRecorder {
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/executions", strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+
+	return w
+
+}
+
+func TestExecution(t *testing.T) {
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "server")
+
+	executorMock := mock.NewExecutor()
+	router := newTestExecutionRouter(NewExecution(ctx, executorMock, "secret"))
+
+	exec := model.Execution{
+		Id:     1,
+		Route:  []model.Currency{"USD", "BTC", "USD"},
+		Volume: 100,
+		State:  model.ExecutionCompleted,
+	}
+
+	executorMock.On("GetExecutions").Return([]model.Execution{exec})
+	executorMock.On("GetExecution", int64(1)).Return(exec, true)
+	executorMock.On("GetExecution", int64(2)).Return(model.Execution{}, false)
+	executorMock.On("Execute", ctx, []model.Currency{"USD", "BTC", "USD"}, 100.0).Return(exec, nil)
+	executorMock.On("Execute", testifyMock.Anything, []model.Currency{"USD"}, 100.0).Return(model.Execution{}, service.ErrExecutionRoute)
+
+	w := doGetExecution(router, "secret", "/api/v1/executions")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"state":"completed"`)
+
+	w = doGetExecution(router, "secret", "/api/v1/executions/1")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = doGetExecution(router, "secret", "/api/v1/executions/2")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Account orders are not shown without the token
+	w = doGetExecution(router, "", "/api/v1/executions")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = doGetExecution(router, "wrong", "/api/v1/executions/1")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Executed in the server context
+	w = doExecute(router, "secret", "application/json; charset=utf-8", `{"route":["usd","btc","usd"],"volume":100}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":1`)
+
+	w = doExecute(router, "secret", "application/json", `{"route":["USD"],"volume":100}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = doExecute(router, "secret", "application/json", `{`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Cross-site form posts have no token and no JSON content type
+	w = doExecute(router, "", "application/json", `{"route":["USD","BTC","USD"],"volume":100}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = doExecute(router, "wrong", "application/json", `{"route":["USD","BTC","USD"],"volume":100}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = doExecute(router, "secret", "text/plain", `{"route":["USD","BTC","USD"],"volume":100}`)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+
+	// Empty configured token rejects everything
+	router = newTestExecutionRouter(NewExecution(ctx, executorMock, ""))
+	w = doExecute(router, "", "application/json", `{"route":["USD","BTC","USD"],"volume":100}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	executorMock.AssertNumberOfCalls(t, "Execute", 2)
+
+}
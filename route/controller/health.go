@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+)
+
+// Liveness and readiness probes
+type Health struct {
+	refresher service.Refresher
+	maxAge    time.Duration
+	templates error // error of loading the page templates, nil if loaded
+}
+
+func NewHealth(refresher service.Refresher, maxAge time.Duration, templates error) *Health {
+	return &Health{
+		refresher: refresher,
+		maxAge:    maxAge,
+		templates: templates,
+	}
+}
+
+type healthCheck struct {
+	Ok      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+}
+
+type healthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]healthCheck `json:"checks,omitempty"`
+}
+
+// Process is alive
+func (c *Health) Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, healthResponse{Status: "ok"})
+}
+
+// Instance can serve: templates loaded, upstream market data fresh, pair settings cached
+func (c *Health) Ready(w http.ResponseWriter, r *http.Request) {
+
+	snapshot, err := c.refresher.Last()
+	checks := map[string]healthCheck{
+		"templates": c.checkTemplates(),
+		"market":    c.checkMarket(c.refresher.Updated(), err),
+		"pairs":     c.checkPairs(snapshot),
+	}
+
+	status, response := http.StatusOK, healthResponse{Status: "ok", Checks: checks}
+	for _, check := range checks {
+		if !check.Ok {
+			status, response.Status = http.StatusServiceUnavailable, "fail"
+		}
+	}
+
+	writeJSON(w, status, response)
+
+}
+
+func (c *Health) checkTemplates() healthCheck {
+
+	if c.templates != nil {
+		return healthCheck{Message: "templates are not loaded: " + c.templates.Error()}
+	}
+
+	return healthCheck{Ok: true}
+
+}
+
+// Upstream data of the last successful refresh is not older than max age
+func (c *Health) checkMarket(updated time.Time, err error) (check healthCheck) {
+
+	if updated.IsZero() {
+		check.Message = "no market data yet"
+	} else {
+		age := time.Since(updated).Round(time.Second)
+		check.Ok = age <= c.maxAge
+		check.Message = fmt.Sprintf("market data %s old, max age %s", age, c.maxAge)
+	}
+
+	if err != nil {
+		check.Message += ", last error: " + err.Error()
+	}
+
+	return
+
+}
+
+func (c *Health) checkPairs(snapshot *model.Snapshot) healthCheck {
+
+	if snapshot == nil || len(snapshot.Pairs) == 0 {
+		return healthCheck{Message: "pair settings are not cached"}
+	}
+
+	return healthCheck{Ok: true, Message: fmt.Sprintf("%d pairs", len(snapshot.Pairs))}
+
+}
@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"errors"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+type testRefresher struct {
+	snapshot *model.Snapshot
+	err      error
+	updated  time.Time
+}
+
+func (r *testRefresher) Last() (*model.Snapshot, error) {
+	return r.snapshot, r.err
+}
+
+func (r *testRefresher) Updated() time.Time {
+	return r.updated
+}
+
+func TestHealth(t *testing.T) {
+
+	refresher := &testRefresher{}
+	health := NewHealth(refresher, 30*time.Second, nil)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/healthz", health.Live)
+	router.HandleFunc("/readyz", health.Ready)
+
+	w := doRequest(router, "/healthz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok"}`, w.Body.String())
+
+	// Nothing loaded yet
+	w = doRequest(router, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"status":"fail","checks":{
+		"templates":{"ok":true},
+		"market":{"ok":false,"message":"no market data yet"},
+		"pairs":{"ok":false,"message":"pair settings are not cached"}
+	}}`, w.Body.String())
+
+	refresher.snapshot = &model.Snapshot{Pairs: model.PairSettings{"BTC_USD": {}}}
+	refresher.updated = time.Now()
+
+	w = doRequest(router, "/readyz")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"status":"ok","checks":{
+		"templates":{"ok":true},
+		"market":{"ok":true,"message":"market data 0s old, max age 30s"},
+		"pairs":{"ok":true,"message":"1 pairs"}
+	}}`, w.Body.String())
+
+	// Stale market data
+	refresher.updated = time.Now().Add(-time.Minute)
+	refresher.err = errors.New("upstream")
+
+	w = doRequest(router, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"message":"market data 1m0s old, max age 30s, last error: upstream"`)
+
+	// Fresh market data without templates
+	refresher.updated = time.Now()
+	refresher.err = nil
+	router.HandleFunc("/readyz-templates", NewHealth(refresher, 30*time.Second, errors.New("view/index.html: no such file")).Ready)
+
+	w = doRequest(router, "/readyz-templates")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"templates":{"ok":false,"message":"templates are not loaded: view/index.html: no such file"}`)
+
+}
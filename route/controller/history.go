@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"github.com/tusupov/exmoarbitrage/config"
+	"github.com/tusupov/exmoarbitrage/service"
+)
+
+const (
+	historyLimit    = 100  // default max result count of history requests
+	historyMaxLimit = 1000 // max result count a request may ask for
+)
+
+// Opportunity history page and API
+type History struct {
+	historian service.Historian
+
+	historyTpl *template.Template
+}
+
+func NewHistory(cfg *config.Config, historian service.Historian) (history *History, err error) {
+
+	funcMap := template.FuncMap{
+		"inc": func(i int) int {
+			return i + 1
+		},
+	}
+
+	t, err := template.New("history.html").Funcs(funcMap).ParseFiles(cfg.TemplateDirectory + "view/history.html")
+	if err != nil {
+		return
+	}
+
+	history = &History{
+		historian:  historian,
+		historyTpl: template.Must(t, nil),
+	}
+
+	return
+
+}
+
+// History API without the page template
+func NewHistoryApi(historian service.Historian) *History {
+	return &History{
+		historian: historian,
+	}
+}
+
+func (c *History) Page(w http.ResponseWriter, r *http.Request) {
+
+	query, err := parseHistoryQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if query.Limit == 0 {
+		query.Limit = historyLimit
+	}
+
+	opportunityList, err := c.historian.GetHistory(r.Context(), query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	list := make([][]interface{}, 0)
+	for _, opportunity := range opportunityList {
+		list = append(
+			list,
+			[]interface{}{
+				opportunity.Time.Format("2006-01-02 15:04:05"),
+				fmt.Sprint(opportunity.Route),
+				fmt.Sprintf("%.4f", (opportunity.Profit-1)*100),
+				fmt.Sprintf("%.4f", (opportunity.NetProfit-1)*100),
+				fmt.Sprintf("%.8f", opportunity.Volume),
+			},
+		)
+	}
+
+	err = c.historyTpl.Execute(w, map[string]interface{}{
+		"url":   r.URL.Path,
+		"query": r.URL.Query(),
+		"list":  list,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+}
+
+func (c *History) List(w http.ResponseWriter, r *http.Request) {
+
+	query, err := parseHistoryQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if query.Limit == 0 {
+		query.Limit = historyLimit
+	}
+
+	list, err := c.historian.GetHistory(r.Context(), query)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, list)
+
+}
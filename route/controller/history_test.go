@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	testifyMock "github.com/stretchr/testify/mock"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+	"github.com/tusupov/exmoarbitrage/service/mock"
+)
+
+func TestHistory_List(t *testing.T) {
+
+	historianMock := mock.NewHistorian()
+	history := &History{historian: historianMock}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/api/v1/history", history.List).Methods("GET")
+
+	opportunity := model.Opportunity{
+		Time:      time.Date(2019, 2, 1, 10, 0, 0, 0, time.UTC),
+		Route:     []model.Currency{"USD", "BTC", "USD"},
+		Profit:    1.02,
+		NetProfit: 1.01,
+		Volume:    100,
+	}
+
+	historianMock.On("GetHistory", testifyMock.Anything, service.HistoryQuery{Currency: "BTC", Limit: historyLimit}).Return([]model.Opportunity{opportunity}, nil)
+
+	w := doRequest(router, "/api/v1/history?currency=btc")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"route":["USD","BTC","USD"]`)
+	assert.Contains(t, w.Body.String(), `"time":"2019-02-01T10:00:00Z"`)
+
+	w = doRequest(router, "/api/v1/history?from=yesterday")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+}
+
+func TestParseHistoryQuery(t *testing.T) {
+
+	values, _ := url.ParseQuery("from=2019-02-01T10:00:00Z&to=2019-02-02&currency=%20eth&min_profit=0.5&limit=10")
+
+	query, err := parseHistoryQuery(values)
+	assert.Nil(t, err)
+
+	minProfit := 0.5
+	assert.Equal(t, service.HistoryQuery{
+		From:      time.Date(2019, 2, 1, 10, 0, 0, 0, time.UTC),
+		To:        time.Date(2019, 2, 3, 0, 0, 0, 0, time.UTC).Add(-time.Nanosecond),
+		Currency:  "ETH",
+		MinProfit: &minProfit,
+		Limit:     10,
+	}, query)
+
+	values, _ = url.ParseQuery("from=2019-02-01T10:30")
+	query, err = parseHistoryQuery(values)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Date(2019, 2, 1, 10, 30, 0, 0, time.UTC), query.From)
+
+	for _, raw := range []string{"to=02.02.2019", "min_profit=x", "limit=-1", "limit=1001"} {
+		values, _ := url.ParseQuery(raw)
+		_, err = parseHistoryQuery(values)
+		assert.NotNil(t, err, raw)
+	}
+
+}
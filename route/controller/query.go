@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+)
+
+// Parse arbitrage query from query string:
+// base, max_legs, min_profit, limit, sort, currencies, exclude, pairs, exclude_pairs
+func parseArbitrageQuery(values url.Values) (query service.ArbitrageQuery, err error) {
+
+	query.Base = model.Currency(strings.ToUpper(strings.TrimSpace(values.Get("base"))))
+
+	if query.MaxLegs, err = parseInt(values, "max_legs"); err != nil {
+		return
+	}
+	// Cycle has at least 2 legs, 0 - not set
+	if query.MaxLegs == 1 || query.MaxLegs > service.MaxQueryLegs {
+		err = fmt.Errorf("`max_legs` must be from 2 to %d", service.MaxQueryLegs)
+		return
+	}
+
+	if query.Limit, err = parseInt(values, "limit"); err != nil {
+		return
+	}
+
+	switch order := strings.ToLower(strings.TrimSpace(values.Get("sort"))); order {
+	case "", service.SortProfit:
+	case service.SortLifetime:
+		query.Sort = order
+	default:
+		err = fmt.Errorf("`sort` must be %s or %s", service.SortProfit, service.SortLifetime)
+		return
+	}
+
+	if query.MinProfit, err = parseFloat(values, "min_profit"); err != nil {
+		return
+	}
+
+	for _, v := range splitList(values.Get("currencies")) {
+		query.Currencies = append(query.Currencies, model.Currency(v))
+	}
+
+	for _, v := range splitList(values.Get("exclude")) {
+		query.ExcludeCurrencies = append(query.ExcludeCurrencies, model.Currency(v))
+	}
+
+	for _, v := range splitList(values.Get("pairs")) {
+		query.Pairs = append(query.Pairs, model.Pair(v))
+	}
+
+	for _, v := range splitList(values.Get("exclude_pairs")) {
+		query.ExcludePairs = append(query.ExcludePairs, model.Pair(v))
+	}
+
+	return
+
+}
+
+// Parse history query from query string: from, to, currency, min_profit, limit.
+// Date only range end includes the whole day.
+func parseHistoryQuery(values url.Values) (query service.HistoryQuery, err error) {
+
+	if query.From, _, err = parseTime(values, "from"); err != nil {
+		return
+	}
+
+	var dateOnly bool
+	if query.To, dateOnly, err = parseTime(values, "to"); err != nil {
+		return
+	}
+	if dateOnly {
+		query.To = query.To.Add(24*time.Hour - time.Nanosecond)
+	}
+
+	query.Currency = model.Currency(strings.ToUpper(strings.TrimSpace(values.Get("currency"))))
+
+	if query.MinProfit, err = parseFloat(values, "min_profit"); err != nil {
+		return
+	}
+
+	if query.Limit, err = parseInt(values, "limit"); err != nil {
+		return
+	}
+
+	if query.Limit > historyMaxLimit {
+		err = fmt.Errorf("`limit` must not exceed %d", historyMaxLimit)
+	}
+
+	return
+
+}
+
+func parseInt(values url.Values, key string) (n int, err error) {
+
+	v := strings.TrimSpace(values.Get(key))
+	if v == "" {
+		return
+	}
+
+	n, err = strconv.Atoi(v)
+	if err != nil || n < 0 {
+		err = fmt.Errorf("`%s` must be a non-negative integer", key)
+	}
+
+	return
+
+}
+
+// Optional number, nil if not set
+func parseFloat(values url.Values, key string) (*float64, error) {
+
+	v := strings.TrimSpace(values.Get(key))
+	if v == "" {
+		return nil, nil
+	}
+
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return nil, fmt.Errorf("`%s` must be a number: %v", key, err)
+	}
+
+	return &n, nil
+
+}
+
+// Time in RFC3339, 2006-01-02T15:04 (UTC) or 2006-01-02 (UTC) format
+func parseTime(values url.Values, key string) (t time.Time, dateOnly bool, err error) {
+
+	v := strings.TrimSpace(values.Get(key))
+	if v == "" {
+		return
+	}
+
+	if t, err = time.Parse(time.RFC3339, v); err == nil {
+		return
+	}
+
+	if t, err = time.Parse("2006-01-02T15:04", v); err == nil {
+		return
+	}
+
+	if t, err = time.Parse("2006-01-02", v); err == nil {
+		dateOnly = true
+		return
+	}
+
+	err = fmt.Errorf("`%s` must be a time in RFC3339 or 2006-01-02 format", key)
+
+	return
+
+}
+
+// Split comma separated upper case list
+func splitList(s string) (list []string) {
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.ToUpper(strings.TrimSpace(v)); v != "" {
+			list = append(list, v)
+		}
+	}
+	return
+}
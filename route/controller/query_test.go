@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"testing"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+)
+
+func TestParseArbitrageQuery(t *testing.T) {
+
+	values, _ := url.ParseQuery("base=usd&max_legs=3&min_profit=0.2&limit=5&sort=Lifetime&currencies=btc,%20eth&exclude=RUB&pairs=BTC_USD&exclude_pairs=btc_rub,")
+
+	query, err := parseArbitrageQuery(values)
+	assert.Nil(t, err)
+
+	minProfit := 0.2
+	assert.Equal(t, service.ArbitrageQuery{
+		Base:              "USD",
+		MaxLegs:           3,
+		MinProfit:         &minProfit,
+		Limit:             5,
+		Sort:              service.SortLifetime,
+		Currencies:        []model.Currency{"BTC", "ETH"},
+		ExcludeCurrencies: []model.Currency{"RUB"},
+		Pairs:             []model.Pair{"BTC_USD"},
+		ExcludePairs:      []model.Pair{"BTC_RUB"},
+	}, query)
+
+	query, err = parseArbitrageQuery(url.Values{})
+	assert.Nil(t, err)
+	assert.Equal(t, service.ArbitrageQuery{}, query)
+
+	query, err = parseArbitrageQuery(url.Values{"sort": {"profit"}})
+	assert.Nil(t, err)
+	assert.Equal(t, service.ArbitrageQuery{}, query)
+
+	for _, raw := range []string{"max_legs=x", "max_legs=1", "max_legs=6", "limit=-1", "min_profit=abc", "sort=volume"} {
+		values, _ := url.ParseQuery(raw)
+		_, err = parseArbitrageQuery(values)
+		assert.NotNil(t, err, raw)
+	}
+
+}
@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+)
+
+// Keep-alive comment interval for idle streams
+const streamPing = 15 * time.Second
+
+// Server-Sent Events streams
+type Stream struct {
+	service  service.Servicer
+	notifier service.Notifier
+}
+
+func NewStream(service service.Servicer, notifier service.Notifier) *Stream {
+	return &Stream{
+		service:  service,
+		notifier: notifier,
+	}
+}
+
+type arbitrageEvent struct {
+	Time time.Time         `json:"time"`
+	List []model.Arbitrage `json:"list"`
+}
+
+// Push arbitrage list filtered by query string on every snapshot change
+func (c *Stream) Arbitrage(w http.ResponseWriter, r *http.Request) {
+
+	query, err := parseArbitrageQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	updates, unsubscribe := c.notifier.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ctx := r.Context()
+
+	var last []byte
+	send := func(snapshotTime time.Time) error {
+
+		// Searched once per snapshot for all streams with the same query
+		list, err := c.service.GetArbitrage(ctx, query)
+		if err != nil {
+			_, err = fmt.Fprintf(w, "event: failure\ndata: %s\n\n", jsonString(err.Error()))
+			flusher.Flush()
+			return err
+		}
+
+		if list == nil {
+			list = []model.Arbitrage{}
+		}
+
+		data, err := json.Marshal(list)
+		if err != nil {
+			return err
+		}
+
+		// Same list in a new snapshot
+		if bytes.Equal(data, last) {
+			return nil
+		}
+		last = data
+
+		event, err := json.Marshal(arbitrageEvent{Time: snapshotTime, List: list})
+		if err != nil {
+			return err
+		}
+
+		if _, err = fmt.Fprintf(w, "event: arbitrage\ndata: %s\n\n", event); err != nil {
+			return err
+		}
+		flusher.Flush()
+
+		return nil
+
+	}
+
+	// Current state first
+	if snapshot, err := c.service.GetSnapshot(ctx); err == nil {
+		send(snapshot.Time)
+	}
+
+	ping := time.NewTicker(streamPing)
+	defer ping.Stop()
+
+	for {
+		select {
+
+		case <-ctx.Done():
+			return
+
+		case snapshot, ok := <-updates:
+			// Notifier stopped
+			if !ok {
+				return
+			}
+			send(snapshot.Time)
+
+		case <-ping.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		}
+	}
+
+}
+
+func jsonString(s string) []byte {
+	data, _ := json.Marshal(s)
+	return data
+}
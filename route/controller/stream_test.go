@@ -0,0 +1,96 @@
+package controller
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	testifyMock "github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+	"github.com/tusupov/exmoarbitrage/service/mock"
+)
+
+type testNotifier struct {
+	updates chan *model.Snapshot
+}
+
+func (n *testNotifier) Subscribe() (<-chan *model.Snapshot, func()) {
+	return n.updates, func() {}
+}
+
+// Read next SSE event, skipping comments
+func readEvent(r *bufio.Reader) (event, data string, err error) {
+
+	for {
+
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return event, data, err
+		}
+
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case line == "":
+			if event != "" {
+				return event, data, nil
+			}
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		}
+
+	}
+
+}
+
+func TestStream_Arbitrage(t *testing.T) {
+
+	snapshotTime := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	first := []model.Arbitrage{{Profit: 1.01, NetProfit: 1.01, Route: []model.Currency{"USD", "BTC", "USD"}}}
+	second := []model.Arbitrage{{Profit: 1.02, NetProfit: 1.02, Route: []model.Currency{"USD", "ETH", "USD"}}}
+
+	serviceMock := mock.NewArbitrage()
+	serviceMock.On("GetSnapshot", testifyMock.Anything).Return(&model.Snapshot{Time: snapshotTime}, nil)
+	serviceMock.On("GetArbitrage", testifyMock.Anything, service.ArbitrageQuery{Base: "USD"}).Return(first, nil).Twice()
+	serviceMock.On("GetArbitrage", testifyMock.Anything, service.ArbitrageQuery{Base: "USD"}).Return(second, nil)
+
+	notifier := &testNotifier{updates: make(chan *model.Snapshot, 1)}
+	stream := NewStream(serviceMock, notifier)
+
+	server := httptest.NewServer(http.HandlerFunc(stream.Arbitrage))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?base=USD")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer resp.Body.Close()
+
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	reader := bufio.NewReader(resp.Body)
+
+	event, data, err := readEvent(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, "arbitrage", event)
+	assert.JSONEq(t, `{"time":"2019-01-01T00:00:00Z","list":[{"profit":1.01,"net_profit":1.01,"route":["USD","BTC","USD"],"volume":0,"volume_profit":0,"net_volume_profit":0}]}`, data)
+
+	// Unchanged list is not sent again, changed one is
+	notifier.updates <- &model.Snapshot{Time: snapshotTime.Add(time.Second)}
+	notifier.updates <- &model.Snapshot{Time: snapshotTime.Add(2 * time.Second)}
+
+	event, data, err = readEvent(reader)
+	assert.Nil(t, err)
+	assert.Equal(t, "arbitrage", event)
+	assert.JSONEq(t, `{"time":"2019-01-01T00:00:02Z","list":[{"profit":1.02,"net_profit":1.02,"route":["USD","ETH","USD"],"volume":0,"volume_profit":0,"net_volume_profit":0}]}`, data)
+
+	// Stopped notifier ends the stream
+	close(notifier.updates)
+	_, _, err = readEvent(reader)
+	assert.NotNil(t, err)
+
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"time"
 	"github.com/tusupov/exmoarbitrage/config"
+	"github.com/tusupov/exmoarbitrage/model"
 	"github.com/tusupov/exmoarbitrage/service"
 )
 
@@ -57,7 +59,18 @@ func NewWeb(cfg *config.Config, service service.Servicer) (web *Web, err error)
 
 func (c *Web) Index(w http.ResponseWriter, r *http.Request) {
 
-	arbitrageList, err := c.service.GetArbitrage(r.Context())
+	query, err := parseArbitrageQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Top 10 by default
+	if query.Limit == 0 {
+		query.Limit = 10
+	}
+
+	arbitrageList, err := c.service.GetArbitrage(r.Context(), query)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -68,18 +81,22 @@ func (c *Web) Index(w http.ResponseWriter, r *http.Request) {
 		list = append(
 			list,
 			[]interface{}{
-				fmt.Sprintf("%.4f", (arbitrage.Profit-1)*100),
+				fmt.Sprintf("%.4f", (arbitrage.NetProfit-1)*100),
 				fmt.Sprint(arbitrage.Route),
+				fmt.Sprintf("%.8f", arbitrage.Volume),
+				fmt.Sprintf("%.8f", arbitrage.NetVolumeProfit),
+				fmt.Sprintf("%.4f", (arbitrage.Profit-1)*100),
+				arbitrage.Violations,
+				lifetimeText(arbitrage.Lifetime),
 			},
 		)
-		if len(list) == 10 {
-			break
-		}
 	}
 
 	err = c.indexTpl.Execute(w, map[string]interface{}{
-		"url":  r.URL.String(),
-		"list": list,
+		"url":     r.URL.Path,
+		"query":   r.URL.Query(),
+		"list":    list,
+		"history": c.cfg.History != "",
 	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -90,7 +107,13 @@ func (c *Web) Index(w http.ResponseWriter, r *http.Request) {
 
 func (c *Web) Arbitrage(w http.ResponseWriter, r *http.Request) {
 
-	arbitrageList, err := c.service.GetArbitrage(r.Context())
+	query, err := parseArbitrageQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	arbitrageList, err := c.service.GetArbitrage(r.Context(), query)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -101,15 +124,22 @@ func (c *Web) Arbitrage(w http.ResponseWriter, r *http.Request) {
 		list = append(
 			list,
 			[]interface{}{
-				fmt.Sprintf("%.4f", (arbitrage.Profit-1)*100),
+				fmt.Sprintf("%.4f", (arbitrage.NetProfit-1)*100),
 				fmt.Sprint(arbitrage.Route),
+				fmt.Sprintf("%.8f", arbitrage.Volume),
+				fmt.Sprintf("%.8f", arbitrage.NetVolumeProfit),
+				fmt.Sprintf("%.4f", (arbitrage.Profit-1)*100),
+				arbitrage.Violations,
+				lifetimeText(arbitrage.Lifetime),
 			},
 		)
 	}
 
 	err = c.arbitrageTpl.Execute(w, map[string]interface{}{
-		"url":  r.URL.String(),
-		"list": list,
+		"url":     r.URL.Path,
+		"query":   r.URL.Query(),
+		"list":    list,
+		"history": c.cfg.History != "",
 	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -127,8 +157,9 @@ func (c *Web) Currency(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = c.currencyTpl.Execute(w, map[string]interface{}{
-		"url":  r.URL.String(),
-		"list": currencyList,
+		"url":     r.URL.Path,
+		"list":    currencyList,
+		"history": c.cfg.History != "",
 	})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -136,3 +167,19 @@ func (c *Web) Currency(w http.ResponseWriter, r *http.Request) {
 	}
 
 }
+
+// Lifetime duration with peak and average net profit, empty if not tracked
+func lifetimeText(lifetime *model.Lifetime) string {
+
+	if lifetime == nil {
+		return ""
+	}
+
+	return fmt.Sprintf(
+		"%s (макс. %.4f %%, сред. %.4f %%)",
+		lifetime.Duration().Round(time.Second),
+		(lifetime.PeakProfit-1)*100,
+		(lifetime.AvgProfit-1)*100,
+	)
+
+}
@@ -1,24 +1,87 @@
 package route
 
 import (
+	"context"
+	"log"
+	"net/http"
 	"github.com/tusupov/exmoarbitrage/config"
 	"github.com/tusupov/exmoarbitrage/route/controller"
 	"github.com/tusupov/exmoarbitrage/service"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func Init(cfg *config.Config, service service.Servicer) (router *mux.Router, err error) {
+// Services of the routes, optional ones are not registered when nil
+type Options struct {
+	Service   service.Servicer
+	Notifier  service.Notifier
+	Refresher service.Refresher
+	Context   context.Context // server lifetime, cancels route execution on shutdown
 
-	web, err := controller.NewWeb(cfg, service)
-	if err != nil {
-		return
+	Executor  service.Executer      // optional, route execution
+	Historian service.Historian     // optional, opportunity history
+	Cross     service.CrossServicer // optional, cross-exchange arbitrage
+}
+
+// Pages with templates failed to parse or missing are not registered,
+// readiness reports the templates error and the API is still served
+func Init(cfg *config.Config, opts Options) (router *mux.Router, err error) {
+
+	web, templatesErr := controller.NewWeb(cfg, opts.Service)
+
+	var history *controller.History
+	if opts.Historian != nil && templatesErr == nil {
+		history, templatesErr = controller.NewHistory(cfg, opts.Historian)
+	}
+
+	if templatesErr != nil {
+		log.Printf("Templates error: %v\n", templatesErr)
 	}
 
 	router = mux.NewRouter()
-	router.HandleFunc("/", web.Index)
-	router.HandleFunc("/arbitrage", web.Arbitrage)
-	router.HandleFunc("/currency", web.Currency)
+	router.Use(instrument)
+	router.Handle("/metrics", promhttp.Handler()).Methods("GET")
+
+	health := controller.NewHealth(opts.Refresher, cfg.ReadyMaxAge, templatesErr)
+	router.HandleFunc("/healthz", health.Live).Methods("GET")
+	router.HandleFunc("/readyz", health.Ready).Methods("GET")
+	if templatesErr == nil {
+		router.HandleFunc("/", web.Index)
+		router.HandleFunc("/arbitrage", web.Arbitrage)
+		router.HandleFunc("/currency", web.Currency)
+	}
+	router.PathPrefix("/static/").Handler(http.StripPrefix("/static/", http.FileServer(http.Dir(cfg.TemplateDirectory+"view/static"))))
+
+	api := controller.NewApi(opts.Service)
+	apiV1 := router.PathPrefix("/api/v1").Subrouter()
+	apiV1.HandleFunc("/arbitrage", api.Arbitrage).Methods("GET")
+	apiV1.HandleFunc("/currencies", api.Currencies).Methods("GET")
+	apiV1.HandleFunc("/pairs", api.Pairs).Methods("GET")
+	apiV1.HandleFunc("/orderbook/{pair}", api.OrderBook).Methods("GET")
+
+	stream := controller.NewStream(opts.Service, opts.Notifier)
+	apiV1.HandleFunc("/stream/arbitrage", stream.Arbitrage).Methods("GET")
+
+	if opts.Executor != nil && cfg.TradeApi {
+		execution := controller.NewExecution(opts.Context, opts.Executor, cfg.TradeApiToken)
+		apiV1.HandleFunc("/executions", execution.List).Methods("GET")
+		apiV1.HandleFunc("/executions/{id}", execution.Get).Methods("GET")
+		apiV1.HandleFunc("/executions", execution.Create).Methods("POST")
+	}
+
+	if opts.Historian != nil {
+		if history != nil {
+			router.HandleFunc("/history", history.Page)
+		} else {
+			history = controller.NewHistoryApi(opts.Historian)
+		}
+		apiV1.HandleFunc("/history", history.List).Methods("GET")
+	}
+
+	if opts.Cross != nil {
+		apiV1.HandleFunc("/cross", controller.NewCross(opts.Cross).Arbitrage).Methods("GET")
+	}
 
 	return
 
@@ -0,0 +1,134 @@
+// Live arbitrage table: subscribes to the stream and highlights
+// new (green) and disappeared (red) routes
+(function () {
+
+    var tbody = document.getElementById('arbitrage-list');
+    if (!tbody || !window.EventSource) {
+        return;
+    }
+
+    var params = new URLSearchParams(window.location.search);
+    if (tbody.getAttribute('data-limit') && !params.has('limit')) {
+        params.set('limit', tbody.getAttribute('data-limit'));
+    }
+
+    var source = new EventSource('/api/v1/stream/arbitrage?' + params.toString());
+    source.addEventListener('arbitrage', function (e) {
+        render(JSON.parse(e.data));
+    });
+
+    function percent(value) {
+        return ((value - 1) * 100).toFixed(4);
+    }
+
+    // Same as fmt.Sprint of the route on the server
+    function routeKey(arbitrage) {
+        return '[' + arbitrage.route.join(' ') + ']';
+    }
+
+    // Same as lifetimeText on the server
+    function lifetime(value) {
+        if (!value) {
+            return '';
+        }
+        var seconds = Math.round((new Date(value.last_seen) - new Date(value.first_seen)) / 1000);
+        var text = seconds + 's';
+        if (seconds >= 60) {
+            text = Math.floor(seconds / 60) + 'm' + (seconds % 60) + 's';
+        }
+        if (seconds >= 3600) {
+            text = Math.floor(seconds / 3600) + 'h' + Math.floor(seconds % 3600 / 60) + 'm' + (seconds % 60) + 's';
+        }
+        return text + ' (макс. ' + percent(value.peak_profit) + ' %, сред. ' + percent(value.avg_profit) + ' %)';
+    }
+
+    function cell(tr, text, className) {
+        var td = document.createElement('td');
+        if (className) {
+            var div = document.createElement('div');
+            div.className = className;
+            div.textContent = text;
+            td.appendChild(div);
+        } else {
+            td.textContent = text;
+        }
+        tr.appendChild(td);
+        return td;
+    }
+
+    function row(arbitrage, i) {
+
+        var tr = document.createElement('tr');
+        tr.setAttribute('data-route', routeKey(arbitrage));
+
+        var th = document.createElement('th');
+        th.setAttribute('scope', 'row');
+        th.textContent = i + 1;
+        tr.appendChild(th);
+
+        var route = cell(tr, routeKey(arbitrage));
+        (arbitrage.violations || []).forEach(function (v) {
+            var div = document.createElement('div');
+            var small = document.createElement('small');
+            small.className = 'text-danger';
+            small.textContent = v.pair + ' ' + v.limit + ': ' + v.value + ', limit ' + v.bound;
+            div.appendChild(small);
+            route.appendChild(div);
+        });
+
+        var net = percent(arbitrage.net_profit);
+        cell(tr, percent(arbitrage.profit) + ' %');
+        cell(tr, net + ' %', net.charAt(0) === '-' ? 'text-danger' : 'text-success');
+        cell(tr, arbitrage.volume.toFixed(8));
+        cell(tr, arbitrage.net_volume_profit.toFixed(8));
+        cell(tr, lifetime(arbitrage.lifetime));
+
+        return tr;
+    }
+
+    function render(event) {
+
+        var previous = {};
+        tbody.querySelectorAll('tr[data-route]:not(.removed)').forEach(function (tr) {
+            previous[tr.getAttribute('data-route')] = tr;
+        });
+
+        var current = {};
+        var rows = document.createDocumentFragment();
+        event.list.forEach(function (arbitrage, i) {
+            var tr = row(arbitrage, i);
+            var key = tr.getAttribute('data-route');
+            current[key] = true;
+            if (!previous[key]) {
+                tr.className = 'table-success';
+            }
+            rows.appendChild(tr);
+        });
+
+        // Disappeared routes stay for a moment
+        Object.keys(previous).forEach(function (key) {
+            if (current[key]) {
+                return;
+            }
+            var tr = previous[key];
+            tr.className = 'table-danger removed';
+            rows.appendChild(tr);
+            setTimeout(function () {
+                if (tr.parentNode) {
+                    tr.parentNode.removeChild(tr);
+                }
+            }, 3000);
+        });
+
+        while (tbody.firstChild) {
+            tbody.removeChild(tbody.firstChild);
+        }
+        tbody.appendChild(rows);
+
+        var time = document.getElementById('arbitrage-time');
+        if (time) {
+            time.textContent = new Date(event.time).toLocaleTimeString();
+        }
+    }
+
+})();
@@ -4,12 +4,36 @@ import (
 	"context"
 	"math"
 	"sort"
+	"time"
 	"github.com/tusupov/exmoarbitrage/api"
 	"github.com/tusupov/exmoarbitrage/model"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Profit comparison precision
+const profitEPS = 1e-6
+
+var (
+	searchDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "arbitrage_search_duration_seconds",
+		Help:    "Arbitrage search duration",
+		Buckets: prometheus.DefBuckets,
+	})
+	profitableCycles = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "arbitrage_profitable_cycles",
+		Help: "Profitable cycles in the latest snapshot",
+	})
+	bestNetProfit = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "arbitrage_best_net_profit_percent",
+		Help: "Best net profit in the latest snapshot, percent",
+	})
 )
 
 type ArbitrageService struct {
 	api api.Apier
+	fee model.FeeSettings
 }
 
 func NewArbitrage(api api.Apier) *ArbitrageService {
@@ -18,137 +42,150 @@ func NewArbitrage(api api.Apier) *ArbitrageService {
 	}
 }
 
+// Set trading and withdrawal fees applied to arbitrage profit
+func (s *ArbitrageService) SetFee(fee model.FeeSettings) {
+	s.fee = fee
+}
+
 func (s *ArbitrageService) GetCurrencyList(ctx context.Context) (result []model.Currency, err error) {
 	return s.api.GetCurrencyList(ctx)
 }
 
+func (s *ArbitrageService) GetPairList(ctx context.Context) (model.PairSettings, error) {
+	return s.api.GetPairList(ctx)
+}
+
+func (s *ArbitrageService) GetOrders(ctx context.Context, pairs ...model.Pair) (model.PairOrders, error) {
+	return s.api.GetOrders(ctx, pairs...)
+}
+
 // Get Arbitrage list from orders
-func (s *ArbitrageService) GetArbitrage(ctx context.Context) (result []model.Arbitrage, err error) {
+func (s *ArbitrageService) GetArbitrage(ctx context.Context, query ArbitrageQuery) (result []model.Arbitrage, err error) {
 
-	currencyList, err := s.api.GetCurrencyList(ctx)
+	snapshot, err := s.load(ctx)
 	if err != nil {
 		return
 	}
 
-	pairList, err := s.api.GetPairList(ctx)
-	if err != nil {
-		return
-	}
+	result = s.Search(snapshot, query)
 
-	pairOrders, err := s.api.GetOrders(ctx, pairList.GetList()...)
+	return
+
+}
+
+// Get current market snapshot with all profitable cycles
+func (s *ArbitrageService) GetSnapshot(ctx context.Context) (snapshot *model.Snapshot, err error) {
+
+	snapshot, err = s.load(ctx)
 	if err != nil {
 		return
 	}
 
-	result = s.floydWarshall(currencyList, pairOrders)
+	snapshot.Arbitrage = s.Search(snapshot, ArbitrageQuery{})
+
+	count, best := 0, 0.0
+	for _, arbitrage := range snapshot.Arbitrage {
+		if arbitrage.NetProfit > 1 {
+			count++
+			best = math.Max(best, (arbitrage.NetProfit-1)*100)
+		}
+	}
+	profitableCycles.Set(float64(count))
+	bestNetProfit.Set(best)
 
 	return
 
 }
 
-// Floyd-Worshel Algorithm
-// Buy and Sell
-func (s ArbitrageService) floydWarshall(currencyList []model.Currency, pairOrders model.PairOrders) (result []model.Arbitrage) {
+// Search arbitrage in snapshot market data
+func (s *ArbitrageService) Search(snapshot *model.Snapshot, query ArbitrageQuery) (result []model.Arbitrage) {
 
-	const EPS = 1e-6
+	defer prometheus.NewTimer(searchDuration).ObserveDuration()
 
-	n := len(currencyList)
-	dist, p := make([][]float64, n), make([][]int, n)
+	currencyList, pairOrders := query.filter(snapshot.Currencies, snapshot.Orders)
 
-	// Init arrays
-	for i := 0; i < n; i++ {
+	// Routes are searched on net rates, so the best route is the one profitable after fees
+	netOrders := applyFee(pairOrders, s.fee)
+	if query.MaxLegs > 0 {
+		result = s.simpleCycles(currencyList, netOrders, query.MaxLegs, query.Base)
+	} else {
+		result = query.rebase(s.bellmanFord(currencyList, netOrders))
+	}
+	fillProfit(result, pairOrders, netOrders, s.fee, snapshot.Pairs)
+
+	result = query.apply(result)
 
-		dist[i], p[i] = make([]float64, n), make([]int, n)
+	return
 
-		for j := 0; j < n; j++ {
+}
 
-			pair := model.Pair(currencyList[i] + "_" + currencyList[j])
-			if order, ok := pairOrders.GetOrder(pair); ok {
-				dist[i][j] = order.Bid.Price
-			} else {
-				if order, ok := pairOrders.GetOrder(pair.Reverse()); ok {
-					dist[i][j] = 1 / order.Ask.Price
-				}
-			}
+// Get all simple cycles of 2..maxLegs legs, profitable or not.
+// If base is not empty, only cycles starting and ending at base.
+func (s *ArbitrageService) GetCycles(ctx context.Context, maxLegs int, base model.Currency) (result []model.Arbitrage, err error) {
+	return s.GetArbitrage(ctx, ArbitrageQuery{
+		Base:    base,
+		MaxLegs: maxLegs,
+	})
+}
 
-			p[i][j] = i
+// Time the market data was last known current:
+// the stream time of a streaming api, now for requested data
+func (s *ArbitrageService) Updated() time.Time {
 
-		}
+	if updater, ok := s.api.(api.Updater); ok {
+		return updater.Updated()
 	}
 
-	for k := 0; k < n; k++ {
-		for i := 0; i < n; i++ {
-			for j := 0; j < n; j++ {
+	return time.Now()
 
-				// Check pair exchange
-				mustPair := model.Pair(currencyList[i] + "_" + currencyList[j])
-				if !pairOrders.Exists(mustPair) && !pairOrders.Exists(mustPair.Reverse()) {
-					continue
-				}
+}
 
-				// Find the maximum profitable course
-				price := dist[i][k] * dist[k][j]
-				if dist[i][j]+EPS < price {
-					dist[i][j] = price
-					p[i][j] = p[k][j]
-				}
+// Load currencies, pair settings and orders,
+// a stepping source moves once so all of them are of one moment
+func (s *ArbitrageService) load(ctx context.Context) (snapshot *model.Snapshot, err error) {
 
-			}
-		}
+	if stepper, ok := s.api.(api.Stepper); ok {
+		stepper.Step()
 	}
 
-	// Full path recovery
-	returnRoute := func(i, j int) (route []model.Currency) {
-
-		route = append(route, currencyList[i])
-
-		k := i
-		for k != p[k][j] {
-			k = p[k][j]
-			route = append(route, currencyList[k])
-		}
-		route = append(route, currencyList[j])
-		route = append(route, currencyList[i])
-
-		return route
+	currencyList, err := s.api.GetCurrencyList(ctx)
+	if err != nil {
+		return
 	}
 
-	// Result
-	for i := 0; i < n; i++ {
+	pairList, err := s.api.GetPairList(ctx)
+	if err != nil {
+		return
+	}
 
-		for j := 0; j < n; j++ {
+	pairOrders, err := s.api.GetOrders(ctx, pairList.GetList()...)
+	if err != nil {
+		return
+	}
 
-			var offer model.Offer
+	now := time.Now()
+	if clock, ok := s.api.(api.Clock); ok {
+		now = clock.Now()
+	}
 
-			pair := model.Pair(currencyList[i] + "_" + currencyList[j])
-			if order, ok := pairOrders.GetOrder(pair); ok {
-				offer = order.Ask
-			} else if order, ok := pairOrders.GetOrder(pair.Reverse()); ok {
-				offer = order.Bid
-				offer.Price = 1 / offer.Price
-			} else {
-				// If there is no exchange between courses, skip
-				continue
-			}
+	snapshot = &model.Snapshot{
+		Time:       now,
+		Currencies: currencyList,
+		Pairs:      pairList,
+		Orders:     pairOrders,
+	}
 
-			// Add to result
-			result = append(result, model.Arbitrage{
-				Profit: dist[i][j] / offer.Price,
-				Route:  returnRoute(i, j),
-			})
+	return
 
-		}
-	}
+}
 
-	// Sort descending
-	// if the value is equal, the sorting will be according to the number of paths to increase
-	sort.Slice(result, func(i, j int) bool {
-		if math.Abs(result[i].Profit-result[j].Profit) <= EPS {
-			return len(result[i].Route) < len(result[j].Route)
+// Sort descending
+// if the value is equal, the sorting will be according to the number of paths to increase
+func sortArbitrage(list []model.Arbitrage) {
+	sort.Slice(list, func(i, j int) bool {
+		if math.Abs(list[i].Profit-list[j].Profit) <= profitEPS {
+			return len(list[i].Route) < len(list[j].Route)
 		}
-		return result[i].Profit > result[j].Profit
+		return list[i].Profit > list[j].Profit
 	})
-
-	return
-
 }
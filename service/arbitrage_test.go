@@ -2,8 +2,14 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
+	"math/rand"
 	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/api"
 	"github.com/tusupov/exmoarbitrage/api/mock"
 	"github.com/tusupov/exmoarbitrage/model"
 )
@@ -24,7 +30,7 @@ func TestArbitrageService_GetCurrencyList(t *testing.T) {
 
 }
 
-func TestArbitrageService_floydWarshall(t *testing.T) {
+func TestArbitrageService_bellmanFord(t *testing.T) {
 
 	testCase := []struct {
 		CurrencyList []model.Currency
@@ -39,31 +45,78 @@ func TestArbitrageService_floydWarshall(t *testing.T) {
 					Ask: model.Offer{Price: 3700},
 				},
 			},
+			Result: nil,
+		},
+		{
+			CurrencyList: []model.Currency{"BTC", "USD"},
+			PairOrders: model.PairOrders{
+				"BTC_USD": model.Order{
+					Bid: model.Offer{Price: 3800},
+					Ask: model.Offer{Price: 3700},
+				},
+			},
 			Result: []model.Arbitrage{
 				{
-					Profit: 1,
+					Profit: 3800 / 3700.0,
 					Route:  []model.Currency{"BTC", "USD", "BTC"},
-				}, {
-					Profit: 1,
-					Route:  []model.Currency{"USD", "BTC", "USD"},
 				},
 			},
 		},
 		{
-			CurrencyList: []model.Currency{"BTC", "USD"},
+			CurrencyList: []model.Currency{"USD", "BTC", "ETH"},
 			PairOrders: model.PairOrders{
 				"BTC_USD": model.Order{
-					Bid: model.Offer{Price: 3600},
-					Ask: model.Offer{Price: 3700},
+					Bid: model.Offer{Price: 4000},
+					Ask: model.Offer{Price: 4000},
+				},
+				"ETH_BTC": model.Order{
+					Bid: model.Offer{Price: 0.05},
+					Ask: model.Offer{Price: 0.05},
+				},
+				"ETH_USD": model.Order{
+					Bid: model.Offer{Price: 210},
+					Ask: model.Offer{Price: 210},
 				},
 			},
 			Result: []model.Arbitrage{
 				{
-					Profit: 3600 / 3700.0,
-					Route:  []model.Currency{"BTC", "USD", "BTC"},
-				}, {
-					Profit: 3600 / 3700.0,
-					Route:  []model.Currency{"USD", "BTC", "USD"},
+					Profit: 1.05,
+					Route:  []model.Currency{"BTC", "ETH", "USD", "BTC"},
+				},
+			},
+		},
+		{
+			CurrencyList: []model.Currency{"USD", "BTC", "ETH", "EUR"},
+			PairOrders: model.PairOrders{
+				"BTC_USD": model.Order{
+					Bid: model.Offer{Price: 4000},
+					Ask: model.Offer{Price: 4000},
+				},
+				"ETH_BTC": model.Order{
+					Bid: model.Offer{Price: 0.05},
+					Ask: model.Offer{Price: 0.05},
+				},
+				"ETH_USD": model.Order{
+					Bid: model.Offer{Price: 210},
+					Ask: model.Offer{Price: 210},
+				},
+				"BTC_EUR": model.Order{
+					Bid: model.Offer{Price: 3500},
+					Ask: model.Offer{Price: 3500},
+				},
+				"EUR_USD": model.Order{
+					Bid: model.Offer{Price: 1.2},
+					Ask: model.Offer{Price: 1.2},
+				},
+			},
+			Result: []model.Arbitrage{
+				{
+					Profit: 1.05,
+					Route:  []model.Currency{"BTC", "ETH", "USD", "BTC"},
+				},
+				{
+					Profit: 1.05,
+					Route:  []model.Currency{"BTC", "EUR", "USD", "BTC"},
 				},
 			},
 		},
@@ -72,21 +125,31 @@ func TestArbitrageService_floydWarshall(t *testing.T) {
 	for _, test := range testCase {
 
 		arbitrageService := &ArbitrageService{}
-		result := arbitrageService.floydWarshall(test.CurrencyList, test.PairOrders)
+		result := arbitrageService.bellmanFord(test.CurrencyList, test.PairOrders)
 
 		if assert.Equal(t, len(test.Result), len(result)) {
-
-			for i := 0; i < len(result); i++ {
-				assert.Equal(t, test.Result[i].Profit, result[i].Profit)
-				assert.ElementsMatch(t, test.Result[i].Route, result[i].Route)
+			for _, expected := range test.Result {
+				assert.True(t, containsRoute(result, expected), "route %v not found in %v", expected.Route, result)
+			}
+			for i := 1; i < len(result); i++ {
+				assert.True(t, result[i-1].Profit >= result[i].Profit)
 			}
-
 		}
 
 	}
 
 }
 
+// Result contains arbitrage with the same route and profit
+func containsRoute(list []model.Arbitrage, expected model.Arbitrage) bool {
+	for _, arbitrage := range list {
+		if assert.ObjectsAreEqual(arbitrage.Route, expected.Route) && math.Abs(arbitrage.Profit-expected.Profit) < 1e-9 {
+			return true
+		}
+	}
+	return false
+}
+
 func TestArbitrageService_GetArbitrage(t *testing.T) {
 
 	testCase := []struct {
@@ -102,31 +165,30 @@ func TestArbitrageService_GetArbitrage(t *testing.T) {
 					Ask: model.Offer{Price: 3700},
 				},
 			},
-			Result: []model.Arbitrage{
-				{
-					Profit: 1,
-					Route:  []model.Currency{"BTC", "USD", "BTC"},
-				}, {
-					Profit: 1,
-					Route:  []model.Currency{"USD", "BTC", "USD"},
+			Result: nil,
+		},
+		{
+			CurrencyList: []model.Currency{"BTC", "USD"},
+			PairOrders: model.PairOrders{
+				"BTC_USD": model.Order{
+					Bid: model.Offer{Price: 3600},
+					Ask: model.Offer{Price: 3700},
 				},
 			},
+			Result: nil,
 		},
 		{
 			CurrencyList: []model.Currency{"BTC", "USD"},
 			PairOrders: model.PairOrders{
 				"BTC_USD": model.Order{
-					Bid: model.Offer{Price: 3600},
+					Bid: model.Offer{Price: 3800},
 					Ask: model.Offer{Price: 3700},
 				},
 			},
 			Result: []model.Arbitrage{
 				{
-					Profit: 3600 / 3700.0,
+					Profit: 3800 / 3700.0,
 					Route:  []model.Currency{"BTC", "USD", "BTC"},
-				}, {
-					Profit: 3600 / 3700.0,
-					Route:  []model.Currency{"USD", "BTC", "USD"},
 				},
 			},
 		},
@@ -143,7 +205,7 @@ func TestArbitrageService_GetArbitrage(t *testing.T) {
 
 		arbitrageService := NewArbitrage(exmoApiMock)
 
-		result, err := arbitrageService.GetArbitrage(ctx)
+		result, err := arbitrageService.GetArbitrage(ctx, ArbitrageQuery{})
 
 		if assert.Nil(t, err) && assert.Equal(t, len(test.Result), len(result)) {
 			for i := 0; i < len(result); i++ {
@@ -155,3 +217,98 @@ func TestArbitrageService_GetArbitrage(t *testing.T) {
 	}
 
 }
+
+func BenchmarkArbitrageService_bellmanFord(b *testing.B) {
+
+	// Exmo sized market: 50 currencies, 100 pairs
+	rnd := rand.New(rand.NewSource(1))
+
+	currencyList := make([]model.Currency, 50)
+	for i := range currencyList {
+		currencyList[i] = model.Currency(fmt.Sprintf("C%02d", i))
+	}
+
+	pairOrders := model.PairOrders{}
+	for len(pairOrders) < 100 {
+		i, j := rnd.Intn(len(currencyList)), rnd.Intn(len(currencyList))
+		if i == j {
+			continue
+		}
+		price := math.Exp(rnd.NormFloat64())
+		pairOrders[model.Pair(currencyList[i]+"_"+currencyList[j])] = model.Order{
+			Bid: model.Offer{Price: price * 0.999},
+			Ask: model.Offer{Price: price * 1.001},
+		}
+	}
+
+	arbitrageService := &ArbitrageService{}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		arbitrageService.bellmanFord(currencyList, pairOrders)
+	}
+
+}
+
+// Records of a slice
+type testRecordReader struct {
+	records []model.MarketRecord
+}
+
+func (r *testRecordReader) Next() (record model.MarketRecord, err error) {
+
+	if len(r.records) == 0 {
+		err = io.EOF
+		return
+	}
+
+	record, r.records = r.records[0], r.records[1:]
+
+	return
+
+}
+
+func TestArbitrageService_GetSnapshot_replay(t *testing.T) {
+
+	recordTime := time.Date(2019, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	replay, err := api.NewReplay(&testRecordReader{records: []model.MarketRecord{
+		{
+			Time:       recordTime,
+			Currencies: []model.Currency{"BTC", "USD"},
+			Pairs:      model.PairSettings{"BTC_USD": model.Setting{}},
+			Orders: model.PairOrders{
+				"BTC_USD": model.Order{Ask: model.Offer{Price: 3700}, Bid: model.Offer{Price: 3800}},
+			},
+		},
+		{
+			Time:       recordTime.Add(time.Second),
+			Currencies: []model.Currency{"BTC", "USD"},
+			Pairs:      model.PairSettings{"BTC_USD": model.Setting{}},
+			Orders: model.PairOrders{
+				"BTC_USD": model.Order{Ask: model.Offer{Price: 3800}, Bid: model.Offer{Price: 3700}},
+			},
+		},
+	}}, 0)
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+	arbitrage := NewArbitrage(replay)
+
+	// Snapshot time is the record time
+	snapshot, err := arbitrage.GetSnapshot(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, recordTime, snapshot.Time)
+	assert.Len(t, snapshot.Arbitrage, 1)
+
+	// Other readers do not move the replay, the next snapshot does
+	pairOrders, err := arbitrage.GetOrders(ctx, "BTC_USD")
+	assert.Nil(t, err)
+	assert.Equal(t, 3700.0, pairOrders["BTC_USD"].Ask.Price)
+
+	snapshot, err = arbitrage.GetSnapshot(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, recordTime.Add(time.Second), snapshot.Time)
+	assert.Len(t, snapshot.Arbitrage, 0)
+
+}
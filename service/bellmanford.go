@@ -0,0 +1,189 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+const (
+	logEPS         = 1e-12 // relaxation precision of -log(rate) distances
+	maxCycleSearch = 1000  // max Bellman-Ford runs per search
+)
+
+// Bellman-Ford Algorithm on -log(rate) edges
+// A cycle with negative total weight is a cycle with rate product > 1
+func (s ArbitrageService) bellmanFord(currencyList []model.Currency, pairOrders model.PairOrders) (result []model.Arbitrage) {
+
+	g := newGraph(currencyList, pairOrders)
+
+	for _, cycle := range g.profitableCycles() {
+		route, profit := g.route(cycle)
+		result = append(result, model.Arbitrage{
+			Profit: profit,
+			Route:  route,
+		})
+	}
+
+	sortArbitrage(result)
+
+	return
+
+}
+
+// Distinct negative cycles, normalized, found by repeated Bellman-Ford runs
+func (g *graph) profitableCycles() (result [][]int) {
+
+	found := make(map[string]bool)
+
+	// Branch on banned edges: every other simple cycle misses
+	// at least one edge of a found cycle, so banning each of
+	// its edges in turn keeps all the remaining cycles reachable
+	searched := make(map[string]bool)
+	stack := [][]int{{}}
+
+	for runs := 0; len(stack) > 0 && runs < maxCycleSearch; runs++ {
+
+		bannedList := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		banned := make([]bool, len(g.edges))
+		for _, ei := range bannedList {
+			banned[ei] = true
+		}
+
+		cycles := g.negativeCycles(banned)
+		if len(cycles) == 0 {
+			continue
+		}
+
+		for _, cycle := range cycles {
+
+			cycle = g.normalize(cycle)
+
+			key := fmt.Sprint(cycle)
+			if found[key] {
+				continue
+			}
+			found[key] = true
+
+			result = append(result, cycle)
+
+		}
+
+		for _, ei := range cycles[0] {
+
+			next := make([]int, len(bannedList), len(bannedList)+1)
+			copy(next, bannedList)
+			next = append(next, ei)
+			sort.Ints(next)
+
+			key := fmt.Sprint(next)
+			if searched[key] {
+				continue
+			}
+			searched[key] = true
+
+			stack = append(stack, next)
+
+		}
+
+	}
+
+	return
+
+}
+
+// Negative cycles of the predecessor graph after Bellman-Ford relaxation
+// from a virtual source connected to every node
+func (g *graph) negativeCycles(banned []bool) (cycles [][]int) {
+
+	n := len(g.nodes)
+
+	dist := make([]float64, n)
+	pred := make([]int, n)
+	for i := range pred {
+		pred[i] = -1
+	}
+
+	relaxed := false
+	for i := 0; i < n; i++ {
+
+		relaxed = false
+		for ei, e := range g.edges {
+			if banned[ei] {
+				continue
+			}
+			if dist[e.from]+e.weight < dist[e.to]-logEPS {
+				dist[e.to] = dist[e.from] + e.weight
+				pred[e.to] = ei
+				relaxed = true
+			}
+		}
+
+		// Converged, no negative cycles
+		if !relaxed {
+			return
+		}
+
+	}
+
+	// Find cycles in predecessor graph
+	const (
+		unvisited = iota
+		onPath
+		done
+	)
+
+	state := make([]int, n)
+	for v := 0; v < n; v++ {
+
+		path := make([]int, 0)
+
+		u := v
+		for u >= 0 && state[u] == unvisited {
+			state[u] = onPath
+			path = append(path, u)
+			if pred[u] < 0 {
+				u = -1
+				break
+			}
+			u = g.edges[pred[u]].from
+		}
+
+		if u >= 0 && state[u] == onPath {
+
+			cycle := make([]int, 0)
+			weight := 0.0
+
+			x := u
+			for {
+				ei := pred[x]
+				cycle = append(cycle, ei)
+				weight += g.edges[ei].weight
+				x = g.edges[ei].from
+				if x == u {
+					break
+				}
+			}
+
+			// Predecessors are collected backwards
+			for i, j := 0, len(cycle)-1; i < j; i, j = i+1, j-1 {
+				cycle[i], cycle[j] = cycle[j], cycle[i]
+			}
+
+			if weight < -logEPS {
+				cycles = append(cycles, cycle)
+			}
+
+		}
+
+		for _, p := range path {
+			state[p] = done
+		}
+
+	}
+
+	return
+
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Max distinct queries cached for the same snapshots
+const maxCachedQueries = 100
+
+// Search results by normalized query, valid until some of the snapshots changes.
+// Results are shared between callers and must not be modified.
+type queryCache struct {
+	mu        sync.Mutex
+	snapshots []*model.Snapshot
+	results   map[string][]model.Arbitrage
+}
+
+// Cached result of the query or the search result, searches run one at a time
+func (c *queryCache) get(snapshots []*model.Snapshot, query ArbitrageQuery, search func() []model.Arbitrage) []model.Arbitrage {
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !sameSnapshots(c.snapshots, snapshots) {
+		c.snapshots = append([]*model.Snapshot(nil), snapshots...)
+		c.results = make(map[string][]model.Arbitrage)
+	}
+
+	key := query.key()
+	if result, ok := c.results[key]; ok {
+		return result
+	}
+
+	result := search()
+	if len(c.results) < maxCachedQueries {
+		c.results[key] = result
+	}
+
+	return result
+
+}
+
+func sameSnapshots(a, b []*model.Snapshot) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+
+}
+
+// Query identity: list order and duplicates do not matter
+func (q ArbitrageQuery) key() string {
+
+	minProfit := "-"
+	if q.MinProfit != nil {
+		minProfit = fmt.Sprint(*q.MinProfit)
+	}
+
+	currencies := func(list []model.Currency) string {
+		items := make([]string, 0, len(list))
+		for _, currency := range list {
+			items = append(items, string(currency))
+		}
+		return sortedSet(items)
+	}
+
+	pairs := func(list []model.Pair) string {
+		items := make([]string, 0, len(list))
+		for _, pair := range list {
+			items = append(items, string(pair))
+		}
+		return sortedSet(items)
+	}
+
+	return strings.Join([]string{
+		string(q.Base),
+		fmt.Sprint(q.MaxLegs),
+		minProfit,
+		fmt.Sprint(q.Limit),
+		q.Sort,
+		currencies(q.Currencies),
+		currencies(q.ExcludeCurrencies),
+		pairs(q.Pairs),
+		pairs(q.ExcludePairs),
+	}, "|")
+
+}
+
+func sortedSet(items []string) string {
+
+	sort.Strings(items)
+
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if len(result) == 0 || item != result[len(result)-1] {
+			result = append(result, item)
+		}
+	}
+
+	return strings.Join(result, ",")
+
+}
@@ -0,0 +1,312 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Market of one exchange in the cross-exchange search
+type venue struct {
+	name   string
+	market Snapshotter
+	fee    model.FeeSettings
+}
+
+// Arbitrage across several exchanges: orders on every exchange
+// and transfers of the same currency between them.
+// Searches the latest snapshots of the exchanges, no upstream requests.
+type CrossArbitrageService struct {
+	venues    []venue
+	transfers map[model.Currency]time.Duration
+	cache     queryCache
+}
+
+func NewCrossArbitrage() *CrossArbitrageService {
+	return &CrossArbitrageService{
+		transfers: make(map[model.Currency]time.Duration),
+	}
+}
+
+// Add exchange with its latest snapshot source, e.g. its Poller
+func (s *CrossArbitrageService) AddExchange(name string, market Snapshotter) {
+	s.venues = append(s.venues, venue{
+		name:   name,
+		market: market,
+	})
+}
+
+// Set trading and withdrawal fees of the exchange,
+// currencies without withdrawal fee are not transferred from it
+func (s *CrossArbitrageService) SetFee(exchange string, fee model.FeeSettings) {
+	for i := range s.venues {
+		if s.venues[i].name == exchange {
+			s.venues[i].fee = fee
+		}
+	}
+}
+
+// Set expected transfer time of currencies between exchanges
+func (s *CrossArbitrageService) SetTransferTime(times map[model.Currency]time.Duration) {
+	for currency, d := range times {
+		s.transfers[currency] = d
+	}
+}
+
+// Get cross-exchange arbitrage list from the latest snapshots of every exchange,
+// results are shared by the same queries until some snapshot changes
+func (s *CrossArbitrageService) GetArbitrage(ctx context.Context, query ArbitrageQuery) (result []model.Arbitrage, err error) {
+
+	snapshots := make([]*model.Snapshot, len(s.venues))
+	for i, v := range s.venues {
+		if snapshots[i], err = v.market.GetSnapshot(ctx); err != nil {
+			return nil, fmt.Errorf("%s: %v", v.name, err)
+		}
+	}
+
+	result = s.cache.get(snapshots, query, func() []model.Arbitrage {
+		return s.Search(snapshots, query)
+	})
+
+	return
+
+}
+
+// Search arbitrage in snapshots of the exchanges, given in exchanges order.
+// Routes trading on a single exchange are left to its own search.
+// Without max legs only routes with positive net volume profit are listed:
+// fixed withdrawal fees may eat the profit of the whole volume.
+func (s *CrossArbitrageService) Search(snapshots []*model.Snapshot, query ArbitrageQuery) (result []model.Arbitrage) {
+
+	names := make([]string, len(s.venues))
+	fees := make([]model.FeeSettings, len(s.venues))
+	currencyLists := make([][]model.Currency, len(s.venues))
+	pairOrders := make([]model.PairOrders, len(s.venues))
+	netOrders := make([]model.PairOrders, len(s.venues))
+
+	for i, v := range s.venues {
+		names[i] = v.name
+		fees[i] = v.fee
+		currencyLists[i], pairOrders[i] = query.filter(snapshots[i].Currencies, snapshots[i].Orders)
+		netOrders[i] = applyFee(pairOrders[i], v.fee)
+	}
+
+	g := newCrossGraph(names, fees, currencyLists, netOrders)
+
+	var cycles [][]int
+	if query.MaxLegs > 0 {
+		cycles = g.simpleCycles(query.MaxLegs, "")
+	} else {
+		cycles = g.profitableCycles()
+	}
+
+	for _, cycle := range cycles {
+
+		if !g.crosses(cycle) {
+			continue
+		}
+
+		if query.Base != "" {
+			var ok bool
+			if cycle, ok = g.rebase(cycle, query.Base); !ok {
+				continue
+			}
+		}
+
+		arbitrage, ok := s.arbitrage(g, cycle, snapshots, pairOrders, netOrders)
+		if !ok || query.MaxLegs == 0 && arbitrage.NetVolumeProfit <= 0 {
+			continue
+		}
+		result = append(result, arbitrage)
+
+	}
+
+	sortArbitrage(result)
+
+	result = query.apply(result)
+
+	return
+
+}
+
+// Arbitrage of the cycle with its legs, volume and limits.
+// Withdrawal fees of the transfers reduce the volume profit only.
+func (s *CrossArbitrageService) arbitrage(g *crossGraph, cycle []int, snapshots []*model.Snapshot, pairOrders, netOrders []model.PairOrders) (arbitrage model.Arbitrage, ok bool) {
+
+	grossLegs := make([]leg, 0, len(cycle))
+	netLegs := make([]leg, 0, len(cycle))
+	owners := make([]int, 0, len(cycle))
+
+	arbitrage.Route = make([]model.Currency, 0, len(cycle)+1)
+	arbitrage.Legs = make([]model.Leg, 0, len(cycle))
+
+	for _, ei := range cycle {
+
+		e := g.edges[ei]
+		owner := g.owner[e.from]
+		l := model.Leg{
+			From: g.venues[e.from],
+			To:   g.venues[e.to],
+		}
+
+		if g.owner[e.to] != owner {
+
+			l.Rate = 1
+			l.Withdraw = s.venues[owner].fee.GetWithdraw(l.From.Currency)
+			l.TransferTime = s.transfers[l.From.Currency]
+
+			grossLegs = append(grossLegs, leg{transfer: true})
+			netLegs = append(netLegs, leg{transfer: true, withdraw: l.Withdraw})
+
+		} else {
+
+			grossLeg, okGross := newLeg(l.From.Currency, l.To.Currency, pairOrders[owner])
+			netLeg, okNet := newLeg(l.From.Currency, l.To.Currency, netOrders[owner])
+			if !okGross || !okNet {
+				return
+			}
+
+			l.Pair = netLeg.pair
+			l.Rate = netLeg.rate()
+
+			grossLegs = append(grossLegs, grossLeg)
+			netLegs = append(netLegs, netLeg)
+
+		}
+
+		owners = append(owners, owner)
+		arbitrage.Route = append(arbitrage.Route, l.From.Currency)
+		arbitrage.Legs = append(arbitrage.Legs, l)
+
+	}
+	arbitrage.Route = append(arbitrage.Route, arbitrage.Route[0])
+
+	volume, violations := applyLegLimits(grossLegs, netLegs, routeProfitableVolume(netLegs), func(i int) (model.Setting, bool) {
+		if grossLegs[i].transfer {
+			return model.Setting{}, false
+		}
+		return snapshots[owners[i]].Pairs.GetSetting(grossLegs[i].pair)
+	})
+
+	arbitrage.Profit = routeRate(grossLegs)
+	arbitrage.NetProfit = routeRate(netLegs)
+	arbitrage.Volume = volume
+	arbitrage.VolumeProfit = routeFill(grossLegs, volume) - volume
+	arbitrage.NetVolumeProfit = routeFill(netLegs, volume) - volume
+	arbitrage.Violations = violations
+
+	return arbitrage, true
+
+}
+
+// Combined graph of several exchanges: a node is a currency on an exchange
+// named "exchange:currency", transfer edges join the same currency
+// on different exchanges
+type crossGraph struct {
+	*graph
+	venues []model.Venue
+	owner  []int // exchange index of every node
+}
+
+// Transfers have rate 1, their fixed withdrawal fee depends on the volume.
+// Currency is transferred only from exchanges with its withdrawal fee.
+func newCrossGraph(exchanges []string, fees []model.FeeSettings, currencyLists [][]model.Currency, pairOrders []model.PairOrders) *crossGraph {
+
+	g := &crossGraph{
+		graph: &graph{},
+	}
+
+	for i, exchange := range exchanges {
+
+		sub := newGraph(currencyLists[i], pairOrders[i])
+
+		offset := len(g.nodes)
+		for _, currency := range sub.nodes {
+			v := model.Venue{Exchange: exchange, Currency: currency}
+			g.nodes = append(g.nodes, model.Currency(v.String()))
+			g.venues = append(g.venues, v)
+			g.owner = append(g.owner, i)
+		}
+
+		for _, e := range sub.edges {
+			e.from += offset
+			e.to += offset
+			g.edges = append(g.edges, e)
+		}
+
+	}
+
+	for i, from := range g.venues {
+
+		if _, ok := fees[g.owner[i]].Withdraw[from.Currency]; !ok {
+			continue
+		}
+
+		for j, to := range g.venues {
+			if g.owner[i] != g.owner[j] && from.Currency == to.Currency {
+				g.addEdge(i, j, 1)
+			}
+		}
+
+	}
+
+	return g
+
+}
+
+// Cycle trades on at least two exchanges
+func (g *crossGraph) crosses(cycle []int) bool {
+
+	trading := -1
+	for _, ei := range cycle {
+
+		e := g.edges[ei]
+		if g.owner[e.from] != g.owner[e.to] {
+			continue
+		}
+
+		if trading >= 0 && trading != g.owner[e.from] {
+			return true
+		}
+		trading = g.owner[e.from]
+
+	}
+
+	return false
+
+}
+
+// Rotate cycle to start from the base currency on any exchange,
+// with an order rather than a transfer if possible
+func (g *crossGraph) rebase(cycle []int, base model.Currency) ([]int, bool) {
+
+	start := -1
+	for i, ei := range cycle {
+
+		e := g.edges[ei]
+		if g.venues[e.from].Currency != base {
+			continue
+		}
+
+		if g.owner[e.from] == g.owner[e.to] {
+			start = i
+			break
+		}
+		if start < 0 {
+			start = i
+		}
+
+	}
+
+	if start < 0 {
+		return nil, false
+	}
+
+	result := make([]int, 0, len(cycle))
+	result = append(result, cycle[start:]...)
+	result = append(result, cycle[:start]...)
+
+	return result, true
+
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Fixed snapshot source
+type testMarket struct {
+	snapshot *model.Snapshot
+	err      error
+}
+
+func (m testMarket) GetSnapshot(ctx context.Context) (*model.Snapshot, error) {
+	return m.snapshot, m.err
+}
+
+// BTC is cheaper on exmo than on binance
+func testCrossSnapshots() []*model.Snapshot {
+	return []*model.Snapshot{
+		{
+			Currencies: []model.Currency{"USD", "BTC"},
+			Pairs:      model.PairSettings{"BTC_USD": model.Setting{MinQuantity: 0.001}},
+			Orders: model.PairOrders{
+				"BTC_USD": model.Order{
+					Bid:  model.Offer{Price: 3500},
+					Ask:  model.Offer{Price: 3510},
+					Bids: []model.Offer{{Price: 3500, Quantity: 1}},
+					Asks: []model.Offer{{Price: 3510, Quantity: 1}},
+				},
+			},
+		},
+		{
+			Currencies: []model.Currency{"USD", "BTC", "ETH"},
+			Pairs:      model.PairSettings{},
+			Orders: model.PairOrders{
+				"BTC_USD": model.Order{
+					Bid:  model.Offer{Price: 3600},
+					Ask:  model.Offer{Price: 3610},
+					Bids: []model.Offer{{Price: 3600, Quantity: 0.5}},
+					Asks: []model.Offer{{Price: 3610, Quantity: 0.5}},
+				},
+				"ETH_BTC": model.Order{
+					Bid: model.Offer{Price: 0.03},
+					Ask: model.Offer{Price: 0.031},
+				},
+			},
+		},
+	}
+}
+
+func TestCrossArbitrageService_Search(t *testing.T) {
+
+	s := NewCrossArbitrage()
+	s.AddExchange("exmo", nil)
+	s.AddExchange("binance", nil)
+	s.SetFee("exmo", model.FeeSettings{Withdraw: map[model.Currency]float64{"BTC": 0.001, "USD": 0}})
+	s.SetFee("binance", model.FeeSettings{Withdraw: map[model.Currency]float64{"BTC": 0.0005, "USD": 1}})
+	s.SetTransferTime(map[model.Currency]time.Duration{"BTC": 30 * time.Minute})
+
+	result := s.Search(testCrossSnapshots(), ArbitrageQuery{Base: "USD"})
+	if !assert.Len(t, result, 1) {
+		return
+	}
+
+	arbitrage := result[0]
+	assert.Equal(t, []model.Currency{"USD", "BTC", "BTC", "USD", "USD"}, arbitrage.Route)
+	assert.Equal(t, []model.Leg{
+		{From: model.Venue{Exchange: "exmo", Currency: "USD"}, To: model.Venue{Exchange: "exmo", Currency: "BTC"}, Pair: "BTC_USD", Rate: 1 / 3510.0},
+		{From: model.Venue{Exchange: "exmo", Currency: "BTC"}, To: model.Venue{Exchange: "binance", Currency: "BTC"}, Rate: 1, Withdraw: 0.001, TransferTime: 30 * time.Minute},
+		{From: model.Venue{Exchange: "binance", Currency: "BTC"}, To: model.Venue{Exchange: "binance", Currency: "USD"}, Pair: "BTC_USD", Rate: 3600},
+		{From: model.Venue{Exchange: "binance", Currency: "USD"}, To: model.Venue{Exchange: "exmo", Currency: "USD"}, Rate: 1, Withdraw: 1},
+	}, arbitrage.Legs)
+	assert.Equal(t, 30*time.Minute, arbitrage.TransferTime())
+
+	// Binance bids limit the volume, BTC withdrawal fee is bought on top
+	assert.InDelta(t, 3600/3510.0, arbitrage.Profit, 1e-9)
+	assert.InDelta(t, 3600/3510.0, arbitrage.NetProfit, 1e-9)
+	assert.InDelta(t, 0.501*3510, arbitrage.Volume, 1e-6)
+	assert.InDelta(t, 1800-0.501*3510, arbitrage.VolumeProfit, 1e-6)
+	assert.InDelta(t, 1799-0.501*3510, arbitrage.NetVolumeProfit, 1e-6)
+	assert.True(t, arbitrage.Executable())
+
+	// Both directions of the cycle, single exchange cycles are skipped
+	result = s.Search(testCrossSnapshots(), ArbitrageQuery{Base: "USD", MaxLegs: 4})
+	if assert.Len(t, result, 2) {
+		assert.Equal(t, arbitrage.Legs, result[0].Legs)
+		assert.Equal(t, model.Venue{Exchange: "binance", Currency: "USD"}, result[1].Legs[0].From)
+		assert.True(t, result[1].NetProfit < 1)
+	}
+
+	// Without BTC the exchanges are joined by USD only
+	result = s.Search(testCrossSnapshots(), ArbitrageQuery{ExcludeCurrencies: []model.Currency{"BTC"}, MaxLegs: 4})
+	assert.Len(t, result, 0)
+
+	// BTC is not withdrawn from exmo without its fee
+	s.SetFee("exmo", model.FeeSettings{Withdraw: map[model.Currency]float64{"USD": 0}})
+	result = s.Search(testCrossSnapshots(), ArbitrageQuery{})
+	assert.Len(t, result, 0)
+
+	// Withdrawal fee costs more than the whole volume earns
+	s.SetFee("exmo", model.FeeSettings{Withdraw: map[model.Currency]float64{"BTC": 0.03}})
+	result = s.Search(testCrossSnapshots(), ArbitrageQuery{})
+	assert.Len(t, result, 0)
+
+	// Listed with max legs, reverse route has no USD transfer from exmo
+	result = s.Search(testCrossSnapshots(), ArbitrageQuery{Base: "USD", MaxLegs: 4})
+	if assert.Len(t, result, 1) {
+		assert.True(t, result[0].NetProfit > 1)
+		assert.True(t, result[0].NetVolumeProfit < 0)
+	}
+
+	// Trading fees make the route unprofitable
+	s.SetFee("exmo", model.FeeSettings{Fee: model.Fee{Taker: 0.02}, Withdraw: map[model.Currency]float64{"BTC": 0, "USD": 0}})
+	s.SetFee("binance", model.FeeSettings{Fee: model.Fee{Taker: 0.02}, Withdraw: map[model.Currency]float64{"BTC": 0, "USD": 0}})
+	result = s.Search(testCrossSnapshots(), ArbitrageQuery{})
+	assert.Len(t, result, 0)
+
+}
+
+func TestCrossArbitrageService_GetArbitrage(t *testing.T) {
+
+	ctx := context.Background()
+	snapshots := testCrossSnapshots()
+
+	s := NewCrossArbitrage()
+	s.AddExchange("exmo", testMarket{snapshot: snapshots[0]})
+	s.AddExchange("binance", testMarket{snapshot: snapshots[1]})
+	s.SetFee("exmo", model.FeeSettings{Withdraw: map[model.Currency]float64{"BTC": 0}})
+	s.SetFee("binance", model.FeeSettings{Withdraw: map[model.Currency]float64{"USD": 0}})
+
+	result, err := s.GetArbitrage(ctx, ArbitrageQuery{Currencies: []model.Currency{"USD", "BTC"}})
+	assert.Nil(t, err)
+	assert.Len(t, result, 1)
+
+	// Same snapshots and query in another order are served from cache
+	cached, err := s.GetArbitrage(ctx, ArbitrageQuery{Currencies: []model.Currency{"BTC", "USD", "BTC"}})
+	assert.Nil(t, err)
+	if assert.Len(t, cached, 1) {
+		assert.True(t, &result[0] == &cached[0])
+	}
+
+	// New snapshot is searched again
+	s.venues[1].market = testMarket{snapshot: testCrossSnapshots()[1]}
+	cached, err = s.GetArbitrage(ctx, ArbitrageQuery{Currencies: []model.Currency{"USD", "BTC"}})
+	assert.Nil(t, err)
+	if assert.Len(t, cached, 1) {
+		assert.False(t, &result[0] == &cached[0])
+	}
+
+	s.venues[1].market = testMarket{err: ErrNoSnapshot}
+	_, err = s.GetArbitrage(ctx, ArbitrageQuery{})
+	assert.EqualError(t, err, "binance: "+ErrNoSnapshot.Error())
+
+}
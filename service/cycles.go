@@ -0,0 +1,105 @@
+package service
+
+import (
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Max cycles listed by one simple cycles search
+const maxSimpleCycles = 10000
+
+// Outgoing edge indexes of every node
+func (g *graph) adjacency() [][]int {
+
+	adj := make([][]int, len(g.nodes))
+	for ei, e := range g.edges {
+		adj[e.from] = append(adj[e.from], ei)
+	}
+
+	return adj
+
+}
+
+// All simple cycles of 2..maxLegs edges, at most maxSimpleCycles.
+// Without base every cycle is listed once, starting from its
+// lexicographically smallest currency; with base only cycles
+// through base are listed, starting from base.
+func (g *graph) simpleCycles(maxLegs int, base model.Currency) (cycles [][]int) {
+
+	adj := g.adjacency()
+	visited := make([]bool, len(g.nodes))
+	path := make([]int, 0, maxLegs)
+
+	var dfs func(start, u int)
+	dfs = func(start, u int) {
+
+		for _, ei := range adj[u] {
+
+			if len(cycles) >= maxSimpleCycles {
+				return
+			}
+
+			v := g.edges[ei].to
+
+			if v == start {
+				if len(path) >= 1 {
+					cycle := make([]int, len(path)+1)
+					copy(cycle, path)
+					cycle[len(path)] = ei
+					cycles = append(cycles, cycle)
+				}
+				continue
+			}
+
+			if visited[v] || len(path)+1 >= maxLegs {
+				continue
+			}
+
+			// Without base the start is the smallest currency of the cycle
+			if base == "" && g.nodes[v] < g.nodes[start] {
+				continue
+			}
+
+			visited[v] = true
+			path = append(path, ei)
+			dfs(start, v)
+			path = path[:len(path)-1]
+			visited[v] = false
+
+		}
+
+	}
+
+	for start, currency := range g.nodes {
+
+		if base != "" && currency != base {
+			continue
+		}
+
+		visited[start] = true
+		dfs(start, start)
+		visited[start] = false
+
+	}
+
+	return
+
+}
+
+// Simple cycles of 2..maxLegs legs with their profit, sorted descending
+func (s ArbitrageService) simpleCycles(currencyList []model.Currency, pairOrders model.PairOrders, maxLegs int, base model.Currency) (result []model.Arbitrage) {
+
+	g := newGraph(currencyList, pairOrders)
+
+	for _, cycle := range g.simpleCycles(maxLegs, base) {
+		route, profit := g.route(cycle)
+		result = append(result, model.Arbitrage{
+			Profit: profit,
+			Route:  route,
+		})
+	}
+
+	sortArbitrage(result)
+
+	return
+
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"github.com/tusupov/exmoarbitrage/api/mock"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+func TestArbitrageService_simpleCycles(t *testing.T) {
+
+	currencyList := []model.Currency{"USD", "BTC", "ETH"}
+	pairOrders := model.PairOrders{
+		"BTC_USD": model.Order{
+			Bid: model.Offer{Price: 4000},
+			Ask: model.Offer{Price: 4000},
+		},
+		"ETH_BTC": model.Order{
+			Bid: model.Offer{Price: 0.05},
+			Ask: model.Offer{Price: 0.05},
+		},
+		"ETH_USD": model.Order{
+			Bid: model.Offer{Price: 210},
+			Ask: model.Offer{Price: 210},
+		},
+	}
+
+	testCase := []struct {
+		MaxLegs int
+		Base    model.Currency
+		Result  []model.Arbitrage
+	}{
+		{
+			MaxLegs: 2,
+			Result: []model.Arbitrage{
+				{Profit: 1, Route: []model.Currency{"BTC", "USD", "BTC"}},
+				{Profit: 1, Route: []model.Currency{"BTC", "ETH", "BTC"}},
+				{Profit: 1, Route: []model.Currency{"ETH", "USD", "ETH"}},
+			},
+		},
+		{
+			MaxLegs: 3,
+			Result: []model.Arbitrage{
+				{Profit: 1.05, Route: []model.Currency{"BTC", "ETH", "USD", "BTC"}},
+				{Profit: 1, Route: []model.Currency{"BTC", "USD", "BTC"}},
+				{Profit: 1, Route: []model.Currency{"BTC", "ETH", "BTC"}},
+				{Profit: 1, Route: []model.Currency{"ETH", "USD", "ETH"}},
+				{Profit: 1 / 1.05, Route: []model.Currency{"BTC", "USD", "ETH", "BTC"}},
+			},
+		},
+		{
+			MaxLegs: 3,
+			Base:    "USD",
+			Result: []model.Arbitrage{
+				{Profit: 1.05, Route: []model.Currency{"USD", "BTC", "ETH", "USD"}},
+				{Profit: 1, Route: []model.Currency{"USD", "BTC", "USD"}},
+				{Profit: 1, Route: []model.Currency{"USD", "ETH", "USD"}},
+				{Profit: 1 / 1.05, Route: []model.Currency{"USD", "ETH", "BTC", "USD"}},
+			},
+		},
+		{
+			MaxLegs: 1,
+			Result:  nil,
+		},
+	}
+
+	for _, test := range testCase {
+
+		arbitrageService := &ArbitrageService{}
+		result := arbitrageService.simpleCycles(currencyList, pairOrders, test.MaxLegs, test.Base)
+
+		if assert.Equal(t, len(test.Result), len(result)) {
+			for _, expected := range test.Result {
+				assert.True(t, containsRoute(result, expected), "route %v not found in %v", expected.Route, result)
+			}
+			for i := 1; i < len(result); i++ {
+				assert.True(t, result[i-1].Profit >= result[i].Profit)
+			}
+		}
+
+	}
+
+}
+
+func TestArbitrageService_GetCycles(t *testing.T) {
+
+	ctx := context.Background()
+
+	exmoApiMock := mock.NewExmo()
+	exmoApiMock.On("GetCurrencyList", ctx).Return([]model.Currency{"BTC", "USD"}, nil)
+	exmoApiMock.On("GetPairList", ctx).Return(model.PairSettings{}, nil)
+	exmoApiMock.On("GetOrders", ctx, []model.Pair{}).Return(model.PairOrders{
+		"BTC_USD": model.Order{
+			Bid: model.Offer{Price: 3600},
+			Ask: model.Offer{Price: 3700},
+		},
+	}, nil)
+
+	arbitrageService := NewArbitrage(exmoApiMock)
+	arbitrageService.SetFee(model.FeeSettings{Withdraw: map[model.Currency]float64{"USD": 1}})
+
+	result, err := arbitrageService.GetCycles(ctx, 3, "USD")
+	if assert.Nil(t, err) && assert.Len(t, result, 1) {
+		assert.Equal(t, []model.Currency{"USD", "BTC", "USD"}, result[0].Route)
+		assert.InDelta(t, 3600/3700.0, result[0].Profit, 1e-9)
+		// Unprofitable at any volume, nothing is withdrawn
+		assert.InDelta(t, 3600/3700.0, result[0].NetProfit, 1e-9)
+		assert.Equal(t, 0.0, result[0].Volume)
+		assert.Equal(t, 0.0, result[0].NetVolumeProfit)
+	}
+
+}
+
+func TestGraph_simpleCyclesLimit(t *testing.T) {
+
+	// Every pair of 12 currencies is traded, 22484 cycles of 2..5 legs
+	currencyList := make([]model.Currency, 12)
+	for i := range currencyList {
+		currencyList[i] = model.Currency(rune('A' + i))
+	}
+
+	pairOrders := model.PairOrders{}
+	for i, base := range currencyList {
+		for _, quote := range currencyList[i+1:] {
+			pairOrders[model.Pair(base+"_"+quote)] = model.Order{
+				Bid: model.Offer{Price: 1},
+				Ask: model.Offer{Price: 1},
+			}
+		}
+	}
+
+	g := newGraph(currencyList, pairOrders)
+	assert.Len(t, g.simpleCycles(5, ""), maxSimpleCycles)
+	assert.Len(t, g.simpleCycles(2, ""), 66)
+
+}
@@ -0,0 +1,787 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+	"github.com/tusupov/exmoarbitrage/api"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+const (
+	quantityPrecision = 8    // order quantity decimal places
+	holdingEPS        = 1e-8 // dust left by rounding is not unwound
+	maxExecutions     = 100  // executions kept in the log
+)
+
+var (
+	ErrExecutionRoute  = errors.New("Маршрут должен начинаться и заканчиваться одной валютой")
+	ErrExecutionVolume = errors.New("Объем должен быть больше нуля")
+	ErrExecutionState  = errors.New("Недопустимое состояние исполнения")
+	ErrRouteOrders     = errors.New("Нет стакана для пары маршрута")
+	ErrLegLimits       = errors.New("Сделка нарушает лимиты пары")
+	ErrLegNotFilled    = errors.New("Ордер не исполнен")
+)
+
+// Allowed execution state changes
+var executionTransitions = map[model.ExecutionState][]model.ExecutionState{
+	model.ExecutionPending:   {model.ExecutionPlanned, model.ExecutionFailed},
+	model.ExecutionPlanned:   {model.ExecutionRunning, model.ExecutionFailed},
+	model.ExecutionRunning:   {model.ExecutionCompleted, model.ExecutionUnwinding, model.ExecutionFailed},
+	model.ExecutionUnwinding: {model.ExecutionUnwound, model.ExecutionFailed},
+}
+
+// Places orders of arbitrage routes and keeps the execution log
+type Executor struct {
+	api    api.Apier
+	trader api.Trader
+	fee    model.FeeSettings
+
+	pollInterval time.Duration // open orders check interval
+	fillTimeout  time.Duration // order is cancelled if not filled in time
+
+	mu         sync.Mutex
+	executions []*model.Execution
+	lastId     int64
+
+	running sync.WaitGroup // executions in progress
+	execMu  sync.Mutex     // one execution at a time, balances are checked by each
+}
+
+// Route leg with the data its orders are computed from
+type executionStep struct {
+	leg        leg
+	setting    model.Setting
+	hasSetting bool
+	fee        float64
+}
+
+func NewExecutor(api api.Apier, trader api.Trader) *Executor {
+	return &Executor{
+		api:          api,
+		trader:       trader,
+		pollInterval: time.Second,
+		fillTimeout:  10 * time.Second,
+	}
+}
+
+// Set trading fees, received amounts are reduced by taker fee
+func (e *Executor) SetFee(fee model.FeeSettings) {
+	e.fee = fee
+}
+
+func (e *Executor) SetTimeouts(pollInterval, fillTimeout time.Duration) {
+	e.pollInterval = pollInterval
+	e.fillTimeout = fillTimeout
+}
+
+// Execute closed route with volume of the start currency.
+// Legs are placed at once when balances allow, otherwise one by one
+// with the output of a leg as the input of the next one.
+// When a leg fails the holdings are converted back to the start currency.
+// Executions run one at a time and wait in the log as pending.
+func (e *Executor) Execute(ctx context.Context, route []model.Currency, volume float64) (model.Execution, error) {
+
+	if len(route) < 3 || route[0] != route[len(route)-1] {
+		return model.Execution{}, ErrExecutionRoute
+	}
+
+	if volume <= 0 {
+		return model.Execution{}, ErrExecutionVolume
+	}
+
+	e.running.Add(1)
+	defer e.running.Done()
+
+	exec := e.create(route, volume)
+
+	e.execMu.Lock()
+	defer e.execMu.Unlock()
+
+	err := e.run(ctx, exec)
+
+	return e.copy(exec), err
+
+}
+
+// Wait until executions in progress are finished
+func (e *Executor) Wait() {
+	e.running.Wait()
+}
+
+// Executions log, newest first
+func (e *Executor) GetExecutions() []model.Execution {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	result := make([]model.Execution, 0, len(e.executions))
+	for i := len(e.executions) - 1; i >= 0; i-- {
+		result = append(result, copyExecution(e.executions[i]))
+	}
+
+	return result
+
+}
+
+func (e *Executor) GetExecution(id int64) (model.Execution, bool) {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, exec := range e.executions {
+		if exec.Id == id {
+			return copyExecution(exec), true
+		}
+	}
+
+	return model.Execution{}, false
+
+}
+
+func (e *Executor) create(route []model.Currency, volume float64) *model.Execution {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.lastId++
+	now := time.Now()
+
+	exec := &model.Execution{
+		Id:      e.lastId,
+		Route:   append([]model.Currency(nil), route...),
+		Volume:  volume,
+		State:   model.ExecutionPending,
+		Holding: map[model.Currency]float64{},
+		Created: now,
+		Events: []model.ExecutionEvent{{
+			Time:    now,
+			State:   model.ExecutionPending,
+			Message: fmt.Sprintf("execute %v with %g %s", route, volume, route[0]),
+		}},
+	}
+
+	e.executions = append(e.executions, exec)
+	if len(e.executions) > maxExecutions {
+		e.executions = e.executions[len(e.executions)-maxExecutions:]
+	}
+
+	return exec
+
+}
+
+func (e *Executor) run(ctx context.Context, exec *model.Execution) error {
+
+	steps, err := e.plan(ctx, exec)
+	if err != nil {
+		e.fail(exec, err)
+		return err
+	}
+
+	parallel, err := e.fundable(ctx, exec)
+	if err != nil {
+		e.fail(exec, err)
+		return err
+	}
+
+	mode := "sequential"
+	if parallel {
+		mode = "parallel"
+	}
+
+	e.mu.Lock()
+	exec.Parallel = parallel
+	e.mu.Unlock()
+
+	if err = e.setState(exec, model.ExecutionRunning, "place orders, "+mode); err != nil {
+		return err
+	}
+
+	if parallel {
+		err = e.runParallel(ctx, exec, steps)
+	} else {
+		err = e.runSequential(ctx, exec, steps)
+	}
+
+	if err == nil {
+		e.mu.Lock()
+		result := exec.Holding[exec.Route[0]]
+		e.mu.Unlock()
+		return e.setState(exec, model.ExecutionCompleted, fmt.Sprintf("result %g %s", result, exec.Route[0]))
+	}
+
+	// Holdings are converted back even if ctx is done, funds must not stay in the route
+	unwindCtx, cancel := context.WithTimeout(context.Background(), time.Duration(len(exec.Route))*2*e.fillTimeout)
+	defer cancel()
+
+	if unwindErr := e.unwind(unwindCtx, exec, err); unwindErr != nil {
+		return unwindErr
+	}
+
+	return err
+
+}
+
+// Compute legs from the current order books, volume is reduced
+// to the books depth and the pair max limits
+func (e *Executor) plan(ctx context.Context, exec *model.Execution) (steps []executionStep, err error) {
+
+	pairSettings, err := e.api.GetPairList(ctx)
+	if err != nil {
+		return
+	}
+
+	pairOrders, err := e.api.GetOrders(ctx, routePairs(exec.Route, pairSettings)...)
+	if err != nil {
+		return
+	}
+
+	grossLegs, ok := routeLegs(exec.Route, pairOrders)
+	if !ok {
+		return nil, ErrRouteOrders
+	}
+
+	netLegs, _ := routeLegs(exec.Route, applyFee(pairOrders, e.fee))
+
+	volume, violations := applyLimits(grossLegs, netLegs, math.Min(exec.Volume, routeCapacity(netLegs)), pairSettings)
+	if len(violations) > 0 {
+		e.event(exec, 0, "limit violation: %s", violations[0])
+		return nil, ErrLegLimits
+	}
+
+	if volume < exec.Volume {
+		e.event(exec, 0, "volume reduced to %g %s", volume, exec.Route[0])
+	}
+
+	steps = make([]executionStep, len(grossLegs))
+	legs := make([]model.ExecutionLeg, len(grossLegs))
+
+	in := volume
+	for i, l := range grossLegs {
+
+		steps[i].leg = l
+		steps[i].setting, steps[i].hasSetting = pairSettings.GetSetting(l.pair)
+		steps[i].fee = e.fee.GetFee(l.pair).Taker
+
+		legs[i] = steps[i].order(exec.Route[i], exec.Route[i+1], in)
+		if err = steps[i].check(legs[i]); err != nil {
+			e.event(exec, i+1, "%s %g at %g: %v", legs[i].Type, legs[i].Quantity, legs[i].Price, err)
+			return nil, err
+		}
+
+		in = legs[i].Out
+
+	}
+
+	e.mu.Lock()
+	exec.Volume = volume
+	exec.Legs = legs
+	e.mu.Unlock()
+
+	err = e.setState(exec, model.ExecutionPlanned, fmt.Sprintf("expected %g %s", in, exec.Route[0]))
+
+	return
+
+}
+
+// Check start balance, true if every leg can be funded from balances at once
+func (e *Executor) fundable(ctx context.Context, exec *model.Execution) (parallel bool, err error) {
+
+	info, err := e.trader.GetUserInfo(ctx)
+	if err != nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if info.Balances[exec.Route[0]] < exec.Volume {
+		return false, api.ErrInsufficientFunds
+	}
+
+	need := map[model.Currency]float64{}
+	for _, l := range exec.Legs {
+		need[l.From] += l.In
+	}
+
+	for currency, amount := range need {
+		if info.Balances[currency] < amount {
+			return false, nil
+		}
+	}
+
+	return true, nil
+
+}
+
+// Every leg input is the output of the previous leg
+func (e *Executor) runSequential(ctx context.Context, exec *model.Execution, steps []executionStep) error {
+
+	in := exec.Volume
+	for i, step := range steps {
+
+		e.mu.Lock()
+		l := step.order(exec.Legs[i].From, exec.Legs[i].To, in)
+		exec.Legs[i] = l
+		e.mu.Unlock()
+
+		if err := step.check(l); err != nil {
+			e.setLeg(exec, false, i, model.LegFailed, "%s %g at %g: %v", l.Type, l.Quantity, l.Price, err)
+			return err
+		}
+
+		received, err := e.place(ctx, exec, false, i, step.fee)
+		if err != nil {
+			return err
+		}
+
+		in = received
+
+	}
+
+	return nil
+
+}
+
+// Legs are funded from balances and placed at once
+func (e *Executor) runParallel(ctx context.Context, exec *model.Execution, steps []executionStep) error {
+
+	errs := make([]error, len(steps))
+
+	var wg sync.WaitGroup
+	for i := range steps {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = e.place(ctx, exec, false, i, steps[i].fee)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
+
+// Convert holdings made by the execution back to the start currency with market orders
+func (e *Executor) unwind(ctx context.Context, exec *model.Execution, reason error) error {
+
+	if err := e.setState(exec, model.ExecutionUnwinding, reason.Error()); err != nil {
+		return err
+	}
+
+	base := exec.Route[0]
+
+	e.mu.Lock()
+	currencies := make([]model.Currency, 0, len(exec.Holding))
+	for currency, amount := range exec.Holding {
+		if currency != base && amount > holdingEPS {
+			currencies = append(currencies, currency)
+		}
+	}
+	e.mu.Unlock()
+
+	sort.Slice(currencies, func(i, j int) bool {
+		return currencies[i] < currencies[j]
+	})
+
+	if len(currencies) == 0 {
+		return e.setState(exec, model.ExecutionUnwound, "nothing to unwind")
+	}
+
+	pairSettings, err := e.api.GetPairList(ctx)
+	if err != nil {
+		e.fail(exec, err)
+		return err
+	}
+
+	pairs := make([]model.Pair, 0, len(currencies))
+	for _, currency := range currencies {
+		pairs = append(pairs, routePairs([]model.Currency{currency, base}, pairSettings)...)
+	}
+
+	pairOrders, err := e.api.GetOrders(ctx, pairs...)
+	if err != nil {
+		e.fail(exec, err)
+		return err
+	}
+
+	var failed error
+	for _, currency := range currencies {
+
+		l, ok := newLeg(currency, base, pairOrders)
+		if !ok {
+			e.event(exec, 0, "no pair to convert %s to %s", currency, base)
+			failed = ErrRouteOrders
+			continue
+		}
+
+		e.mu.Lock()
+		amount := floorTo(exec.Holding[currency], quantityPrecision)
+		unwindLeg := model.ExecutionLeg{
+			Pair:     l.pair,
+			From:     currency,
+			To:       base,
+			Type:     model.OrderMarketSell,
+			State:    model.LegPending,
+			Quantity: amount,
+			In:       amount,
+		}
+		if !l.sell {
+			unwindLeg.Type = model.OrderMarketBuyTotal
+		}
+		exec.Unwind = append(exec.Unwind, unwindLeg)
+		i := len(exec.Unwind) - 1
+		e.mu.Unlock()
+
+		if _, err := e.place(ctx, exec, true, i, e.fee.GetFee(l.pair).Taker); err != nil {
+			failed = err
+		}
+
+	}
+
+	if failed != nil {
+		e.fail(exec, failed)
+		return failed
+	}
+
+	return e.setState(exec, model.ExecutionUnwound, "holdings converted to "+string(base))
+
+}
+
+// Place leg order, wait until it is closed and account its fills
+func (e *Executor) place(ctx context.Context, exec *model.Execution, unwind bool, i int, fee float64) (received float64, err error) {
+
+	e.mu.Lock()
+	l := *executionLeg(exec, unwind, i)
+	e.mu.Unlock()
+
+	orderId, err := e.trader.CreateOrder(ctx, model.OrderRequest{
+		Pair:     l.Pair,
+		Type:     l.Type,
+		Quantity: l.Quantity,
+		Price:    l.Price,
+	})
+	if err != nil {
+		e.setLeg(exec, unwind, i, model.LegFailed, "%s %g %s: %v", l.Type, l.Quantity, l.Pair, err)
+		return
+	}
+
+	e.mu.Lock()
+	executionLeg(exec, unwind, i).OrderId = orderId
+	e.mu.Unlock()
+	e.setLeg(exec, unwind, i, model.LegPlaced, "order %d %s %g %s at %g", orderId, l.Type, l.Quantity, l.Pair, l.Price)
+
+	if err = e.wait(ctx, orderId); err != nil {
+		e.setLeg(exec, unwind, i, model.LegFailed, "order %d: %v", orderId, err)
+		return
+	}
+
+	// Fills are taken from trades, the order may be partially filled.
+	// They are accounted even if ctx is done, as the cancel is sent.
+	tradesCtx, cancel := context.WithTimeout(context.Background(), e.fillTimeout)
+	defer cancel()
+
+	trades, err := e.trader.GetTrades(tradesCtx, l.Pair)
+	if err != nil {
+		e.setLeg(exec, unwind, i, model.LegFailed, "order %d trades: %v", orderId, err)
+		return
+	}
+
+	var quantity, amount float64
+	for _, trade := range trades {
+		if trade.OrderId == orderId {
+			quantity += trade.Quantity
+			amount += trade.Amount
+		}
+	}
+
+	if quantity == 0 {
+		e.setLeg(exec, unwind, i, model.LegCancelled, "order %d is not filled", orderId)
+		return 0, ErrLegNotFilled
+	}
+
+	spent, received := amount, quantity
+	if sellOrder(l.Type) {
+		spent, received = quantity, amount
+	}
+	received *= 1 - fee
+
+	e.mu.Lock()
+	leg := executionLeg(exec, unwind, i)
+	leg.Spent, leg.Received = spent, received
+	exec.Holding[l.From] -= spent
+	exec.Holding[l.To] += received
+	e.mu.Unlock()
+
+	e.setLeg(exec, unwind, i, model.LegFilled, "order %d filled: %g %s -> %g %s", orderId, spent, l.From, received, l.To)
+
+	return
+
+}
+
+// Wait until the order is closed, cancel it after fill timeout
+func (e *Executor) wait(ctx context.Context, orderId int64) error {
+
+	deadline := time.Now().Add(e.fillTimeout)
+
+	for time.Now().Before(deadline) && ctx.Err() == nil {
+
+		orders, err := e.trader.GetOpenOrders(ctx)
+		if err == nil && !containsOrder(orders, orderId) {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(e.pollInterval):
+		}
+
+	}
+
+	// Cancel is sent even if ctx is done, the order must not stay open
+	cancelCtx, cancel := context.WithTimeout(context.Background(), e.fillTimeout)
+	defer cancel()
+
+	if err := e.trader.CancelOrder(cancelCtx, orderId); err != nil && err != api.ErrOrderNotFound {
+		return err
+	}
+
+	return nil
+
+}
+
+func (e *Executor) setState(exec *model.Execution, state model.ExecutionState, message string) error {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	allowed := false
+	for _, next := range executionTransitions[exec.State] {
+		if next == state {
+			allowed = true
+			break
+		}
+	}
+
+	if !allowed {
+		return ErrExecutionState
+	}
+
+	exec.State = state
+	exec.Events = append(exec.Events, model.ExecutionEvent{
+		Time:    time.Now(),
+		State:   state,
+		Message: message,
+	})
+
+	if state.Finished() {
+		exec.Finished = time.Now()
+	}
+
+	return nil
+
+}
+
+func (e *Executor) fail(exec *model.Execution, err error) {
+
+	e.mu.Lock()
+	exec.Error = err.Error()
+	e.mu.Unlock()
+
+	e.setState(exec, model.ExecutionFailed, err.Error())
+
+}
+
+func (e *Executor) setLeg(exec *model.Execution, unwind bool, i int, state model.LegState, format string, args ...interface{}) {
+
+	e.mu.Lock()
+	executionLeg(exec, unwind, i).State = state
+	e.mu.Unlock()
+
+	number := i + 1
+	if unwind {
+		number += len(exec.Legs)
+	}
+
+	e.event(exec, number, format, args...)
+
+}
+
+func (e *Executor) event(exec *model.Execution, leg int, format string, args ...interface{}) {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	exec.Events = append(exec.Events, model.ExecutionEvent{
+		Time:    time.Now(),
+		State:   exec.State,
+		Leg:     leg,
+		Message: fmt.Sprintf(format, args...),
+	})
+
+}
+
+func (e *Executor) copy(exec *model.Execution) model.Execution {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return copyExecution(exec)
+}
+
+// Limit order of the leg for input, priced at the deepest level the input reaches
+func (s executionStep) order(from, to model.Currency, in float64) model.ExecutionLeg {
+
+	l := model.ExecutionLeg{
+		Pair:  s.leg.pair,
+		From:  from,
+		To:    to,
+		State: model.LegPending,
+		In:    in,
+	}
+
+	price := s.leg.limitPrice(in)
+
+	if s.leg.sell {
+		l.Type = model.OrderSell
+		l.Price = s.roundPrice(price, false)
+		l.Quantity = floorTo(in, quantityPrecision)
+		l.Out = s.leg.fill(l.Quantity)
+	} else {
+		l.Type = model.OrderBuy
+		l.Price = s.roundPrice(price, true)
+		if l.Price > 0 {
+			l.Quantity = floorTo(in/l.Price, quantityPrecision)
+		}
+		l.Out = math.Min(l.Quantity, s.leg.fill(in))
+	}
+
+	l.Out *= 1 - s.fee
+
+	return l
+
+}
+
+// Check order against the pair min limits
+func (s executionStep) check(l model.ExecutionLeg) error {
+
+	if l.Quantity <= 0 {
+		return ErrLegLimits
+	}
+
+	if !s.hasSetting {
+		return nil
+	}
+
+	if l.Quantity < s.setting.MinQuantity || l.Quantity*l.Price < s.setting.MinAmount {
+		return ErrLegLimits
+	}
+
+	return nil
+
+}
+
+// Round price to the pair precision, up for buy and down for sell
+// so the order still crosses the book
+func (s executionStep) roundPrice(price float64, up bool) float64 {
+
+	if !s.hasSetting || s.setting.PricePrecision <= 0 {
+		return price
+	}
+
+	if up {
+		return ceilTo(price, s.setting.PricePrecision)
+	}
+	return floorTo(price, s.setting.PricePrecision)
+
+}
+
+// Price of the deepest level used by input
+func (l leg) limitPrice(in float64) (price float64) {
+
+	for _, offer := range l.offers {
+
+		price = offer.Price
+
+		levelIn, _ := l.level(offer)
+		if in <= levelIn {
+			break
+		}
+		in -= levelIn
+
+	}
+
+	return
+
+}
+
+// Traded pairs of the route, as listed in pair settings
+func routePairs(route []model.Currency, pairSettings model.PairSettings) []model.Pair {
+
+	pairs := make([]model.Pair, 0, len(route))
+	for i := 0; i+1 < len(route); i++ {
+		pair := model.Pair(route[i] + "_" + route[i+1])
+		if _, ok := pairSettings.GetSetting(pair); !ok {
+			pair = pair.Reverse()
+		}
+		pairs = append(pairs, pair)
+	}
+
+	return pairs
+
+}
+
+func executionLeg(exec *model.Execution, unwind bool, i int) *model.ExecutionLeg {
+	if unwind {
+		return &exec.Unwind[i]
+	}
+	return &exec.Legs[i]
+}
+
+func copyExecution(exec *model.Execution) model.Execution {
+
+	result := *exec
+	result.Route = append([]model.Currency(nil), exec.Route...)
+	result.Legs = append([]model.ExecutionLeg(nil), exec.Legs...)
+	result.Unwind = append([]model.ExecutionLeg(nil), exec.Unwind...)
+	result.Events = append([]model.ExecutionEvent(nil), exec.Events...)
+
+	result.Holding = make(map[model.Currency]float64, len(exec.Holding))
+	for currency, amount := range exec.Holding {
+		result.Holding[currency] = amount
+	}
+
+	return result
+
+}
+
+func containsOrder(orders []model.OpenOrder, orderId int64) bool {
+	for _, order := range orders {
+		if order.OrderId == orderId {
+			return true
+		}
+	}
+	return false
+}
+
+func sellOrder(orderType model.OrderType) bool {
+	return orderType == model.OrderSell || orderType == model.OrderMarketSell || orderType == model.OrderMarketSellTotal
+}
+
+func floorTo(v float64, precision int) float64 {
+	p := math.Pow(10, float64(precision))
+	return math.Floor(v*p+1e-9) / p
+}
+
+func ceilTo(v float64, precision int) float64 {
+	p := math.Pow(10, float64(precision))
+	return math.Ceil(v*p-1e-9) / p
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	testifyMock "github.com/stretchr/testify/mock"
+	"sync"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/api"
+	"github.com/tusupov/exmoarbitrage/api/mock"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// USD -> BTC -> EUR -> USD market without fees
+func newTestExecutor(balances map[model.Currency]float64) (*Executor, traderMocker) {
+
+	exmoApiMock := mock.NewExmo()
+	exmoApiMock.On("GetPairList", testifyMock.Anything).Return(model.PairSettings{
+		"BTC_USD": model.Setting{MinQuantity: 0.001, MinAmount: 1},
+		"BTC_EUR": model.Setting{MinQuantity: 0.001, MinAmount: 1},
+		"EUR_USD": model.Setting{MinQuantity: 1, MinAmount: 1},
+	}, nil)
+	exmoApiMock.On("GetOrders", testifyMock.Anything, testifyMock.Anything).Return(model.PairOrders{
+		"BTC_USD": model.Order{
+			Asks: []model.Offer{{Price: 4000, Quantity: 1}},
+			Bids: []model.Offer{{Price: 3900, Quantity: 1}},
+		},
+		"BTC_EUR": model.Order{
+			Asks: []model.Offer{{Price: 3700, Quantity: 1}},
+			Bids: []model.Offer{{Price: 3600, Quantity: 1}},
+		},
+		"EUR_USD": model.Order{
+			Asks: []model.Offer{{Price: 1.25, Quantity: 10000}},
+			Bids: []model.Offer{{Price: 1.2, Quantity: 10000}},
+		},
+	}, nil)
+
+	traderMock := mock.NewTrader()
+	traderMock.On("GetUserInfo", testifyMock.Anything).Return(model.UserInfo{Balances: balances}, nil)
+	traderMock.On("GetOpenOrders", testifyMock.Anything).Return([]model.OpenOrder{}, nil)
+
+	executor := NewExecutor(exmoApiMock, traderMock)
+	executor.SetTimeouts(time.Millisecond, 10*time.Millisecond)
+
+	return executor, traderMock
+
+}
+
+// Trader mock methods used by the tests
+type traderMocker interface {
+	api.Trader
+	On(methodName string, arguments ...interface{}) *testifyMock.Call
+	AssertNotCalled(t testifyMock.TestingT, methodName string, arguments ...interface{}) bool
+	AssertNumberOfCalls(t testifyMock.TestingT, methodName string, expectedCalls int) bool
+}
+
+var testExecutionRoute = []model.Currency{"USD", "BTC", "EUR", "USD"}
+
+var (
+	testBuyBTC  = model.OrderRequest{Pair: "BTC_USD", Type: model.OrderBuy, Quantity: 0.1, Price: 4000}
+	testSellBTC = model.OrderRequest{Pair: "BTC_EUR", Type: model.OrderSell, Quantity: 0.1, Price: 3600}
+	testSellEUR = model.OrderRequest{Pair: "EUR_USD", Type: model.OrderSell, Quantity: 360, Price: 1.2}
+)
+
+func executionStates(exec model.Execution) (states []model.ExecutionState) {
+	for _, event := range exec.Events {
+		if len(states) == 0 || states[len(states)-1] != event.State {
+			states = append(states, event.State)
+		}
+	}
+	return
+}
+
+func TestExecutor_Execute(t *testing.T) {
+
+	for _, parallel := range []bool{false, true} {
+
+		balances := map[model.Currency]float64{"USD": 1000}
+		if parallel {
+			balances = map[model.Currency]float64{"USD": 1000, "BTC": 1, "EUR": 1000}
+		}
+
+		executor, traderMock := newTestExecutor(balances)
+		traderMock.On("CreateOrder", testifyMock.Anything, testBuyBTC).Return(int64(1), nil)
+		traderMock.On("CreateOrder", testifyMock.Anything, testSellBTC).Return(int64(2), nil)
+		traderMock.On("CreateOrder", testifyMock.Anything, testSellEUR).Return(int64(3), nil)
+		traderMock.On("GetTrades", testifyMock.Anything, []model.Pair{"BTC_USD"}).Return([]model.Trade{{OrderId: 1, Quantity: 0.1, Amount: 400}}, nil)
+		traderMock.On("GetTrades", testifyMock.Anything, []model.Pair{"BTC_EUR"}).Return([]model.Trade{{OrderId: 2, Quantity: 0.1, Amount: 360}}, nil)
+		traderMock.On("GetTrades", testifyMock.Anything, []model.Pair{"EUR_USD"}).Return([]model.Trade{{OrderId: 3, Quantity: 360, Amount: 432}}, nil)
+
+		exec, err := executor.Execute(context.Background(), testExecutionRoute, 400)
+		assert.Nil(t, err)
+		assert.Equal(t, model.ExecutionCompleted, exec.State)
+		assert.Equal(t, parallel, exec.Parallel)
+		assert.Equal(t, []model.ExecutionState{
+			model.ExecutionPending, model.ExecutionPlanned, model.ExecutionRunning, model.ExecutionCompleted,
+		}, executionStates(exec))
+
+		assert.Len(t, exec.Legs, 3)
+		for _, l := range exec.Legs {
+			assert.Equal(t, model.LegFilled, l.State)
+		}
+		assert.InDelta(t, 432, exec.Legs[2].Received, 1e-9)
+		assert.InDelta(t, 32, exec.Profit(), 1e-9)
+		assert.InDelta(t, 0, exec.Holding["BTC"], 1e-9)
+		assert.Empty(t, exec.Unwind)
+		assert.False(t, exec.Finished.IsZero())
+
+		list := executor.GetExecutions()
+		assert.Len(t, list, 1)
+		assert.Equal(t, exec.Id, list[0].Id)
+
+	}
+
+}
+
+func TestExecutor_Execute_unwind(t *testing.T) {
+
+	executor, traderMock := newTestExecutor(map[model.Currency]float64{"USD": 1000})
+	traderMock.On("CreateOrder", testifyMock.Anything, testBuyBTC).Return(int64(1), nil)
+	traderMock.On("CreateOrder", testifyMock.Anything, testSellBTC).Return(int64(0), api.ErrInsufficientFunds)
+	traderMock.On("CreateOrder", testifyMock.Anything, model.OrderRequest{Pair: "BTC_USD", Type: model.OrderMarketSell, Quantity: 0.1}).Return(int64(4), nil)
+	traderMock.On("GetTrades", testifyMock.Anything, []model.Pair{"BTC_USD"}).Return([]model.Trade{
+		{OrderId: 1, Quantity: 0.1, Amount: 400},
+		{OrderId: 4, Quantity: 0.1, Amount: 390},
+	}, nil)
+
+	exec, err := executor.Execute(context.Background(), testExecutionRoute, 400)
+	assert.Equal(t, api.ErrInsufficientFunds, err)
+	assert.Equal(t, model.ExecutionUnwound, exec.State)
+	assert.Equal(t, []model.ExecutionState{
+		model.ExecutionPending, model.ExecutionPlanned, model.ExecutionRunning, model.ExecutionUnwinding, model.ExecutionUnwound,
+	}, executionStates(exec))
+
+	assert.Equal(t, model.LegFilled, exec.Legs[0].State)
+	assert.Equal(t, model.LegFailed, exec.Legs[1].State)
+	assert.Equal(t, model.LegPending, exec.Legs[2].State)
+
+	assert.Len(t, exec.Unwind, 1)
+	assert.Equal(t, model.LegFilled, exec.Unwind[0].State)
+	assert.InDelta(t, -10, exec.Profit(), 1e-9)
+	assert.InDelta(t, 0, exec.Holding["BTC"], 1e-9)
+
+	traderMock.AssertNotCalled(t, "CreateOrder", testifyMock.Anything, testSellEUR)
+
+}
+
+// Shutdown cancels ctx while an order is open, its fills are still
+// accounted and converted back
+func TestExecutor_Execute_cancelled(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	live := testifyMock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() == nil })
+	done := testifyMock.MatchedBy(func(ctx context.Context) bool { return ctx.Err() != nil })
+
+	executor, traderMock := newTestExecutor(map[model.Currency]float64{"USD": 1000})
+	traderMock.On("CreateOrder", testifyMock.Anything, testBuyBTC).Return(int64(1), nil).Run(func(testifyMock.Arguments) {
+		cancel()
+	})
+	traderMock.On("CancelOrder", live, int64(1)).Return(nil)
+	traderMock.On("CreateOrder", done, testSellBTC).Return(int64(0), context.Canceled)
+	traderMock.On("CreateOrder", live, model.OrderRequest{Pair: "BTC_USD", Type: model.OrderMarketSell, Quantity: 0.1}).Return(int64(4), nil)
+	traderMock.On("GetTrades", live, []model.Pair{"BTC_USD"}).Return([]model.Trade{
+		{OrderId: 1, Quantity: 0.1, Amount: 400},
+		{OrderId: 4, Quantity: 0.1, Amount: 390},
+	}, nil)
+
+	exec, err := executor.Execute(ctx, testExecutionRoute, 400)
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, model.ExecutionUnwound, exec.State)
+	assert.Equal(t, model.LegFilled, exec.Legs[0].State)
+	assert.Equal(t, model.LegFailed, exec.Legs[1].State)
+	if assert.Len(t, exec.Unwind, 1) {
+		assert.Equal(t, model.LegFilled, exec.Unwind[0].State)
+	}
+	assert.InDelta(t, 0, exec.Holding["BTC"], 1e-9)
+
+	// Nothing is left running
+	executor.Wait()
+
+}
+
+// Second execution checks balances after the first one is finished
+func TestExecutor_Execute_serial(t *testing.T) {
+
+	placed := make(chan struct{}, 2)
+	release := make(chan struct{})
+
+	executor, traderMock := newTestExecutor(map[model.Currency]float64{"USD": 1000})
+	traderMock.On("CreateOrder", testifyMock.Anything, testBuyBTC).Return(int64(1), nil).Run(func(testifyMock.Arguments) {
+		placed <- struct{}{}
+		<-release
+	})
+	traderMock.On("GetTrades", testifyMock.Anything, []model.Pair{"BTC_USD"}).Return([]model.Trade{}, nil)
+
+	var wg sync.WaitGroup
+	execute := func() {
+		defer wg.Done()
+		executor.Execute(context.Background(), testExecutionRoute, 400)
+	}
+
+	wg.Add(2)
+	go execute()
+	<-placed
+	go execute()
+
+	time.Sleep(20 * time.Millisecond)
+	traderMock.AssertNumberOfCalls(t, "GetUserInfo", 1)
+	assert.Len(t, executor.GetExecutions(), 2)
+
+	close(release)
+	wg.Wait()
+	traderMock.AssertNumberOfCalls(t, "GetUserInfo", 2)
+
+}
+
+func TestExecutor_Execute_notFilled(t *testing.T) {
+
+	executor, traderMock := newTestExecutor(map[model.Currency]float64{"USD": 1000})
+	traderMock.On("CreateOrder", testifyMock.Anything, testBuyBTC).Return(int64(1), nil)
+	traderMock.On("GetTrades", testifyMock.Anything, []model.Pair{"BTC_USD"}).Return([]model.Trade{}, nil)
+
+	exec, err := executor.Execute(context.Background(), testExecutionRoute, 400)
+	assert.Equal(t, ErrLegNotFilled, err)
+	assert.Equal(t, model.ExecutionUnwound, exec.State)
+	assert.Equal(t, model.LegCancelled, exec.Legs[0].State)
+	assert.Empty(t, exec.Unwind)
+
+}
+
+func TestExecutor_Execute_insufficientFunds(t *testing.T) {
+
+	executor, traderMock := newTestExecutor(map[model.Currency]float64{"USD": 100})
+
+	exec, err := executor.Execute(context.Background(), testExecutionRoute, 400)
+	assert.Equal(t, api.ErrInsufficientFunds, err)
+	assert.Equal(t, model.ExecutionFailed, exec.State)
+	assert.Equal(t, api.ErrInsufficientFunds.Error(), exec.Error)
+
+	traderMock.AssertNotCalled(t, "CreateOrder", testifyMock.Anything, testifyMock.Anything)
+
+	_, err = executor.Execute(context.Background(), []model.Currency{"USD", "BTC"}, 400)
+	assert.Equal(t, ErrExecutionRoute, err)
+
+	_, err = executor.Execute(context.Background(), testExecutionRoute, 0)
+	assert.Equal(t, ErrExecutionVolume, err)
+
+}
+
+func TestExecutor_setState(t *testing.T) {
+
+	executor := NewExecutor(nil, nil)
+	exec := executor.create(testExecutionRoute, 1)
+
+	assert.Equal(t, ErrExecutionState, executor.setState(exec, model.ExecutionRunning, ""))
+	assert.Nil(t, executor.setState(exec, model.ExecutionPlanned, ""))
+	assert.Nil(t, executor.setState(exec, model.ExecutionFailed, ""))
+	assert.Equal(t, ErrExecutionState, executor.setState(exec, model.ExecutionRunning, ""))
+
+}
+
+func TestExecutionStep_order(t *testing.T) {
+
+	route := []model.Currency{"USD", "BTC"}
+	legs, ok := routeLegs(route, model.PairOrders{
+		"BTC_USD": model.Order{
+			Asks: []model.Offer{{Price: 4000.123, Quantity: 0.05}, {Price: 4001.56, Quantity: 1}},
+		},
+	})
+	assert.True(t, ok)
+
+	step := executionStep{
+		leg:        legs[0],
+		setting:    model.Setting{MinQuantity: 0.001, PricePrecision: 1},
+		hasSetting: true,
+		fee:        0.002,
+	}
+
+	// Buy is priced at the deepest level rounded up
+	order := step.order("USD", "BTC", 400)
+	assert.Equal(t, model.OrderBuy, order.Type)
+	assert.Equal(t, 4001.6, order.Price)
+	assert.Equal(t, 0.09996001, order.Quantity)
+	assert.True(t, order.Quantity*order.Price <= 400)
+	assert.Nil(t, step.check(order))
+
+	// Below min quantity
+	assert.Equal(t, ErrLegLimits, step.check(step.order("USD", "BTC", 1)))
+
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Apply taker commission to every order book level.
+// Commission is charged from the received currency, so
+// selling at bid yields price*(1-fee) and buying at ask
+// yields quantity*(1-fee) for the same spent amount.
+func applyFee(pairOrders model.PairOrders, fee model.FeeSettings) (result model.PairOrders) {
+
+	result = make(model.PairOrders, len(pairOrders))
+
+	for pair, order := range pairOrders {
+
+		k := 1 - fee.GetFee(pair).Taker
+
+		result[pair] = model.Order{
+			Ask:  askFee(order.Ask, k),
+			Bid:  bidFee(order.Bid, k),
+			Asks: offersFee(order.Asks, k, askFee),
+			Bids: offersFee(order.Bids, k, bidFee),
+		}
+
+	}
+
+	return
+
+}
+
+func askFee(offer model.Offer, k float64) model.Offer {
+	offer.Price /= k
+	offer.Quantity *= k
+	return offer
+}
+
+func bidFee(offer model.Offer, k float64) model.Offer {
+	offer.Price *= k
+	offer.Amount *= k
+	return offer
+}
+
+func offersFee(offers []model.Offer, k float64, apply func(model.Offer, float64) model.Offer) (result []model.Offer) {
+
+	if offers == nil {
+		return
+	}
+
+	result = make([]model.Offer, len(offers))
+	for i, offer := range offers {
+		result[i] = apply(offer, k)
+	}
+
+	return
+
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"github.com/tusupov/exmoarbitrage/api/mock"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+func TestApplyFee(t *testing.T) {
+
+	pairOrders := model.PairOrders{
+		"BTC_USD": model.Order{
+			Ask:  model.Offer{Price: 4000, Quantity: 1, Amount: 4000},
+			Bid:  model.Offer{Price: 3600, Quantity: 1, Amount: 3600},
+			Asks: []model.Offer{{Price: 4000, Quantity: 1, Amount: 4000}},
+			Bids: []model.Offer{{Price: 3600, Quantity: 1, Amount: 3600}},
+		},
+		"ETH_BTC": model.Order{
+			Ask: model.Offer{Price: 0.04, Quantity: 10, Amount: 0.4},
+			Bid: model.Offer{Price: 0.03, Quantity: 10, Amount: 0.3},
+		},
+	}
+
+	fee := model.FeeSettings{
+		Fee: model.Fee{Taker: 0.1},
+		Pairs: map[model.Pair]model.Fee{
+			"ETH_BTC": {Taker: 0.5},
+		},
+	}
+
+	result := applyFee(pairOrders, fee)
+
+	order, _ := result.GetOrder("BTC_USD")
+	assert.InDelta(t, 4000/0.9, order.Ask.Price, 1e-9)
+	assert.InDelta(t, 0.9, order.Ask.Quantity, 1e-9)
+	assert.InDelta(t, 4000, order.Ask.Amount, 1e-9)
+	assert.InDelta(t, 3240, order.Bid.Price, 1e-9)
+	assert.InDelta(t, 1, order.Bid.Quantity, 1e-9)
+	assert.InDelta(t, 3240, order.Bid.Amount, 1e-9)
+	assert.Equal(t, []model.Offer{order.Ask}, order.Asks)
+	assert.Equal(t, []model.Offer{order.Bid}, order.Bids)
+
+	order, _ = result.GetOrder("ETH_BTC")
+	assert.InDelta(t, 0.08, order.Ask.Price, 1e-9)
+	assert.InDelta(t, 0.015, order.Bid.Price, 1e-9)
+	assert.Nil(t, order.Asks)
+
+	// Source is not modified
+	assert.Equal(t, 4000.0, pairOrders["BTC_USD"].Asks[0].Price)
+
+}
+
+func TestArbitrageService_GetArbitrage_Fee(t *testing.T) {
+
+	ctx := context.Background()
+
+	pairOrders := model.PairOrders{
+		"BTC_USD": model.Order{
+			Ask:  model.Offer{Price: 3700, Quantity: 1},
+			Bid:  model.Offer{Price: 5000, Quantity: 1},
+			Asks: []model.Offer{{Price: 3700, Quantity: 1}},
+			Bids: []model.Offer{{Price: 5000, Quantity: 1}},
+		},
+	}
+
+	exmoApiMock := mock.NewExmo()
+	exmoApiMock.On("GetCurrencyList", ctx).Return([]model.Currency{"BTC", "USD"}, nil)
+	exmoApiMock.On("GetPairList", ctx).Return(model.PairSettings{}, nil)
+	exmoApiMock.On("GetOrders", ctx, []model.Pair{}).Return(pairOrders, nil)
+
+	arbitrageService := NewArbitrage(exmoApiMock)
+	arbitrageService.SetFee(model.FeeSettings{
+		Fee:      model.Fee{Taker: 0.1},
+		Withdraw: map[model.Currency]float64{"BTC": 0.01, "USD": 100},
+	})
+
+	result, err := arbitrageService.GetArbitrage(ctx, ArbitrageQuery{})
+	if assert.Nil(t, err) && assert.Len(t, result, 1) {
+
+		arbitrage := result[0]
+		assert.Equal(t, []model.Currency{"BTC", "USD", "BTC"}, arbitrage.Route)
+		assert.InDelta(t, 5000/3700.0, arbitrage.Profit, 1e-9)
+		// Withdrawal of 0.01 BTC is spread over the volume
+		assert.InDelta(t, 0.81*5000/3700.0-0.01/(3700/4500.0), arbitrage.NetProfit, 1e-9)
+
+		// Net buy leg absorbs 3700 USD, which is 3700/4500 BTC sold at net bid
+		assert.InDelta(t, 3700/4500.0, arbitrage.Volume, 1e-9)
+		assert.InDelta(t, 1-3700/4500.0, arbitrage.VolumeProfit, 1e-9)
+		// Result is withdrawn once in BTC, USD stays on the exchange
+		assert.InDelta(t, 0.9-3700/4500.0-0.01, arbitrage.NetVolumeProfit, 1e-9)
+
+	}
+
+}
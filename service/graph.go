@@ -0,0 +1,106 @@
+package service
+
+import (
+	"math"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Exchange direction between two currencies
+type edge struct {
+	from, to int
+	rate     float64 // top of book rate
+	weight   float64 // -log(rate)
+}
+
+// Currency exchange graph built from order books
+type graph struct {
+	nodes []model.Currency
+	edges []edge
+}
+
+// Build graph: pair A_B gives edges A->B at bid and B->A at 1/ask.
+// Pairs with currencies missing in currencyList are skipped.
+func newGraph(currencyList []model.Currency, pairOrders model.PairOrders) *graph {
+
+	g := &graph{
+		nodes: currencyList,
+	}
+
+	index := make(map[model.Currency]int, len(currencyList))
+	for i, currency := range currencyList {
+		index[currency] = i
+	}
+
+	for pair, order := range pairOrders {
+
+		base, quote, ok := pair.Split()
+		if !ok {
+			continue
+		}
+
+		i, okBase := index[base]
+		j, okQuote := index[quote]
+		if !okBase || !okQuote {
+			continue
+		}
+
+		g.addEdge(i, j, order.Bid.Price)
+		if order.Ask.Price > 0 {
+			g.addEdge(j, i, 1/order.Ask.Price)
+		}
+
+	}
+
+	return g
+
+}
+
+func (g *graph) addEdge(from, to int, rate float64) {
+
+	if rate <= 0 {
+		return
+	}
+
+	g.edges = append(g.edges, edge{
+		from:   from,
+		to:     to,
+		rate:   rate,
+		weight: -math.Log(rate),
+	})
+
+}
+
+// Route of the cycle given by edge indexes, closed with its start currency
+func (g *graph) route(cycle []int) (route []model.Currency, profit float64) {
+
+	profit = 1
+	route = make([]model.Currency, 0, len(cycle)+1)
+
+	for _, ei := range cycle {
+		route = append(route, g.nodes[g.edges[ei].from])
+		profit *= g.edges[ei].rate
+	}
+	route = append(route, route[0])
+
+	return
+
+}
+
+// Rotate cycle to start from its lexicographically smallest currency,
+// so rotations of the same cycle give the same edge list
+func (g *graph) normalize(cycle []int) []int {
+
+	start := 0
+	for i, ei := range cycle {
+		if g.nodes[g.edges[ei].from] < g.nodes[g.edges[cycle[start]].from] {
+			start = i
+		}
+	}
+
+	result := make([]int, 0, len(cycle))
+	result = append(result, cycle[start:]...)
+	result = append(result, cycle[:start]...)
+
+	return result
+
+}
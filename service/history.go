@@ -0,0 +1,56 @@
+package service
+
+import (
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Opportunity history query, zero fields do not filter
+type HistoryQuery struct {
+	From      time.Time      // range start, inclusive
+	To        time.Time      // range end, inclusive
+	Currency  model.Currency // route goes through the currency
+	MinProfit *float64       // min net profit, percent
+	Limit     int            // max result count
+}
+
+// Opportunity is in the time range and passes the filters
+func (q HistoryQuery) Match(opportunity model.Opportunity) bool {
+
+	if !q.From.IsZero() && opportunity.Time.Before(q.From) {
+		return false
+	}
+
+	if !q.To.IsZero() && opportunity.Time.After(q.To) {
+		return false
+	}
+
+	if q.Currency != "" && !containsCurrency(opportunity.Route, q.Currency) {
+		return false
+	}
+
+	if q.MinProfit != nil && (opportunity.NetProfit-1)*100 < *q.MinProfit {
+		return false
+	}
+
+	return true
+
+}
+
+// Opportunities of the snapshot arbitrage
+func SnapshotOpportunities(snapshot *model.Snapshot) []model.Opportunity {
+
+	list := make([]model.Opportunity, 0, len(snapshot.Arbitrage))
+	for _, arbitrage := range snapshot.Arbitrage {
+		list = append(list, model.Opportunity{
+			Time:      snapshot.Time,
+			Route:     arbitrage.Route,
+			Profit:    arbitrage.Profit,
+			NetProfit: arbitrage.NetProfit,
+			Volume:    arbitrage.Volume,
+		})
+	}
+
+	return list
+
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"math"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Order quantity and amount of the leg for input
+func (l leg) trade(in float64) (quantity, amount float64) {
+	out := l.fill(in)
+	if l.sell {
+		return in, out
+	}
+	return out, in
+}
+
+// Maximum input of the leg allowed by the pair max limits
+func (l leg) maxInput(setting model.Setting) (in float64) {
+
+	in = math.Inf(1)
+
+	maxIn, maxOut := setting.MaxQuantity, setting.MaxAmount
+	if !l.sell {
+		maxIn, maxOut = maxOut, maxIn
+	}
+
+	if maxIn > 0 {
+		in = math.Min(in, maxIn)
+	}
+	if maxOut > 0 {
+		in = math.Min(in, l.inverse(maxOut))
+	}
+
+	return
+}
+
+// Reduce route volume to the pair max limits
+// and check the legs against the min limits and price range.
+// Legs of pairs without settings are not checked.
+func applyLimits(grossLegs, netLegs []leg, volume float64, pairSettings model.PairSettings) (float64, []model.LimitViolation) {
+	return applyLegLimits(grossLegs, netLegs, volume, func(i int) (model.Setting, bool) {
+		return pairSettings.GetSetting(grossLegs[i].pair)
+	})
+}
+
+// Limits of legs on different exchanges, legSetting gives the pair settings of leg i
+func applyLegLimits(grossLegs, netLegs []leg, volume float64, legSetting func(i int) (model.Setting, bool)) (float64, []model.LimitViolation) {
+
+	for i := range grossLegs {
+
+		setting, ok := legSetting(i)
+		if !ok {
+			continue
+		}
+
+		in := routeFill(netLegs[:i], volume)
+		if maxIn := grossLegs[i].maxInput(setting); in > maxIn {
+			volume = routeInverse(netLegs[:i], maxIn)
+		}
+
+	}
+
+	var violations []model.LimitViolation
+
+	for i, l := range grossLegs {
+
+		setting, ok := legSetting(i)
+		if !ok {
+			continue
+		}
+
+		quantity, amount := l.trade(routeFill(netLegs[:i], volume))
+
+		price := 0.0
+		if len(l.offers) > 0 {
+			price = l.offers[0].Price
+		}
+
+		check := func(limit string, value, bound float64, broken bool) {
+			if broken {
+				violations = append(violations, model.LimitViolation{
+					Pair:  l.pair,
+					Limit: limit,
+					Value: value,
+					Bound: bound,
+				})
+			}
+		}
+
+		check(model.LimitMinQuantity, quantity, setting.MinQuantity, quantity < setting.MinQuantity)
+		check(model.LimitMinAmount, amount, setting.MinAmount, amount < setting.MinAmount)
+		check(model.LimitMinPrice, price, setting.MinPrice, price < setting.MinPrice)
+		check(model.LimitMaxPrice, price, setting.MaxPrice, setting.MaxPrice > 0 && price > setting.MaxPrice)
+
+	}
+
+	return volume, violations
+
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+func TestApplyLimits(t *testing.T) {
+
+	route := []model.Currency{"USD", "BTC", "USD"}
+	pairOrders := model.PairOrders{
+		"BTC_USD": model.Order{
+			Asks: []model.Offer{{Price: 3700, Quantity: 2}},
+			Bids: []model.Offer{{Price: 3600, Quantity: 2}},
+		},
+	}
+	legs, ok := routeLegs(route, pairOrders)
+	assert.True(t, ok)
+
+	testCase := []struct {
+		Setting    model.Setting
+		Volume     float64
+		Violations []model.LimitViolation
+	}{
+		{
+			Setting: model.Setting{MinQuantity: 0.001, MaxQuantity: 100, MinPrice: 1, MaxPrice: 30000, MinAmount: 1, MaxAmount: 500000},
+			Volume:  7400,
+		},
+		{
+			Setting: model.Setting{MaxQuantity: 0.5},
+			Volume:  1850,
+		},
+		{
+			Setting: model.Setting{MaxAmount: 1800},
+			Volume:  1800,
+		},
+		{
+			Setting: model.Setting{MaxQuantity: 0.5, MinAmount: 1820, MaxPrice: 3650},
+			Volume:  1850,
+			Violations: []model.LimitViolation{
+				{Pair: "BTC_USD", Limit: model.LimitMaxPrice, Value: 3700, Bound: 3650},
+				{Pair: "BTC_USD", Limit: model.LimitMinAmount, Value: 1800, Bound: 1820},
+			},
+		},
+	}
+
+	for _, test := range testCase {
+
+		volume, violations := applyLimits(legs, legs, routeCapacity(legs), model.PairSettings{"BTC_USD": test.Setting})
+		assert.InDelta(t, test.Volume, volume, 1e-9)
+		assert.ElementsMatch(t, test.Violations, violations)
+
+	}
+
+	// Pairs without settings are not checked
+	volume, violations := applyLimits(legs, legs, routeCapacity(legs), model.PairSettings{})
+	assert.InDelta(t, 7400, volume, 1e-9)
+	assert.Empty(t, violations)
+
+}
@@ -0,0 +1,46 @@
+package mock
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+)
+
+type arbitrage struct {
+	mock.Mock
+}
+
+func NewArbitrage() *arbitrage {
+	return &arbitrage{}
+}
+
+func (m *arbitrage) GetCurrencyList(ctx context.Context) ([]model.Currency, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]model.Currency), args.Error(1)
+}
+
+func (m *arbitrage) GetPairList(ctx context.Context) (model.PairSettings, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(model.PairSettings), args.Error(1)
+}
+
+func (m *arbitrage) GetOrders(ctx context.Context, pairs ...model.Pair) (model.PairOrders, error) {
+	args := m.Called(ctx, pairs)
+	return args.Get(0).(model.PairOrders), args.Error(1)
+}
+
+func (m *arbitrage) GetArbitrage(ctx context.Context, query service.ArbitrageQuery) ([]model.Arbitrage, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).([]model.Arbitrage), args.Error(1)
+}
+
+func (m *arbitrage) GetCycles(ctx context.Context, maxLegs int, base model.Currency) ([]model.Arbitrage, error) {
+	args := m.Called(ctx, maxLegs, base)
+	return args.Get(0).([]model.Arbitrage), args.Error(1)
+}
+
+func (m *arbitrage) GetSnapshot(ctx context.Context) (*model.Snapshot, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(*model.Snapshot), args.Error(1)
+}
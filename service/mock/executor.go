@@ -0,0 +1,30 @@
+package mock
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+type executor struct {
+	mock.Mock
+}
+
+func NewExecutor() *executor {
+	return &executor{}
+}
+
+func (m *executor) Execute(ctx context.Context, route []model.Currency, volume float64) (model.Execution, error) {
+	args := m.Called(ctx, route, volume)
+	return args.Get(0).(model.Execution), args.Error(1)
+}
+
+func (m *executor) GetExecutions() []model.Execution {
+	args := m.Called()
+	return args.Get(0).([]model.Execution)
+}
+
+func (m *executor) GetExecution(id int64) (model.Execution, bool) {
+	args := m.Called(id)
+	return args.Get(0).(model.Execution), args.Bool(1)
+}
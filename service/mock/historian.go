@@ -0,0 +1,21 @@
+package mock
+
+import (
+	"context"
+	"github.com/stretchr/testify/mock"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+)
+
+type historian struct {
+	mock.Mock
+}
+
+func NewHistorian() *historian {
+	return &historian{}
+}
+
+func (m *historian) GetHistory(ctx context.Context, query service.HistoryQuery) ([]model.Opportunity, error) {
+	args := m.Called(ctx, query)
+	return args.Get(0).([]model.Opportunity), args.Error(1)
+}
@@ -0,0 +1,303 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+var ErrNoSnapshot = errors.New("Данные рынка еще не загружены")
+
+// Min time to load a snapshot, slow upstream may need more than a few intervals
+const minRefreshTimeout = 10 * time.Second
+
+// Refreshes market snapshot in background and serves
+// all readers from the latest one without upstream requests
+type Poller struct {
+	arbitrage *ArbitrageService
+	interval  time.Duration
+
+	mu       sync.RWMutex
+	snapshot *model.Snapshot
+	err      error     // last refresh error
+	updated  time.Time // upstream data time of the last successful refresh
+	tracker  *tracker
+	cache    queryCache
+
+	subMu       sync.Mutex
+	subscribers map[chan *model.Snapshot]bool // true - queue, false - latest only
+	stopped     bool
+}
+
+func NewPoller(arbitrage *ArbitrageService, interval time.Duration) *Poller {
+	return &Poller{
+		arbitrage:   arbitrage,
+		interval:    interval,
+		tracker:     newTracker(),
+		subscribers: make(map[chan *model.Snapshot]bool),
+	}
+}
+
+// Refresh snapshot every interval until ctx is done.
+// Subscriber channels are closed on return.
+func (p *Poller) Run(ctx context.Context) {
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	defer p.stop()
+
+	for {
+
+		if err := p.Refresh(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Poller error: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+	}
+
+}
+
+// Load new snapshot, the previous one is kept on error
+func (p *Poller) Refresh(ctx context.Context) error {
+
+	timeout := p.interval * 5
+	if timeout < minRefreshTimeout {
+		timeout = minRefreshTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	snapshot, err := p.arbitrage.GetSnapshot(ctx)
+
+	p.mu.Lock()
+	p.err = err
+	if err == nil {
+		p.tracker.update(snapshot)
+		p.snapshot = snapshot
+		p.updated = p.arbitrage.Updated()
+	}
+	p.mu.Unlock()
+
+	if err == nil {
+		p.notify(snapshot)
+	}
+
+	return err
+
+}
+
+// Receive every new snapshot until unsubscribe is called.
+// Slow subscribers get only the latest snapshot.
+func (p *Poller) Subscribe() (<-chan *model.Snapshot, func()) {
+	return p.subscribe(make(chan *model.Snapshot, 1), false)
+}
+
+// Receive every new snapshot in order until unsubscribe is called.
+// Up to size snapshots wait for a slow subscriber, new ones are dropped
+// and logged while the queue is full.
+func (p *Poller) SubscribeQueue(size int) (<-chan *model.Snapshot, func()) {
+	return p.subscribe(make(chan *model.Snapshot, size), true)
+}
+
+func (p *Poller) subscribe(ch chan *model.Snapshot, queue bool) (<-chan *model.Snapshot, func()) {
+
+	p.subMu.Lock()
+	if p.stopped {
+		close(ch)
+	} else {
+		p.subscribers[ch] = queue
+	}
+	p.subMu.Unlock()
+
+	unsubscribe := func() {
+		p.subMu.Lock()
+		delete(p.subscribers, ch)
+		p.subMu.Unlock()
+	}
+
+	return ch, unsubscribe
+
+}
+
+// Close subscriber channels, no more snapshots will be sent
+func (p *Poller) stop() {
+
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+
+	for ch := range p.subscribers {
+		close(ch)
+		delete(p.subscribers, ch)
+	}
+	p.stopped = true
+
+}
+
+func (p *Poller) notify(snapshot *model.Snapshot) {
+
+	p.subMu.Lock()
+	defer p.subMu.Unlock()
+
+	if p.stopped {
+		return
+	}
+
+	for ch, queue := range p.subscribers {
+
+		if queue {
+			select {
+			case ch <- snapshot:
+			default:
+				log.Printf("Poller: subscriber queue of %d is full, snapshot %s dropped\n", cap(ch), snapshot.Time.Format(time.RFC3339))
+			}
+			continue
+		}
+
+		// Replace not received snapshot
+		select {
+		case <-ch:
+		default:
+		}
+
+		ch <- snapshot
+
+	}
+
+}
+
+// Latest snapshot and last refresh error
+func (p *Poller) Last() (*model.Snapshot, error) {
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.snapshot, p.err
+
+}
+
+// Time the upstream data of the last successful refresh was current:
+// the stream time or the fetch time, zero if there was none
+func (p *Poller) Updated() time.Time {
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.updated
+
+}
+
+func (p *Poller) GetSnapshot(ctx context.Context) (*model.Snapshot, error) {
+
+	snapshot, err := p.Last()
+	if snapshot == nil {
+		if err == nil {
+			err = ErrNoSnapshot
+		}
+		return nil, err
+	}
+
+	return snapshot, nil
+
+}
+
+func (p *Poller) GetCurrencyList(ctx context.Context) ([]model.Currency, error) {
+
+	snapshot, err := p.GetSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot.Currencies, nil
+
+}
+
+func (p *Poller) GetPairList(ctx context.Context) (model.PairSettings, error) {
+
+	snapshot, err := p.GetSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return snapshot.Pairs, nil
+
+}
+
+func (p *Poller) GetOrders(ctx context.Context, pairs ...model.Pair) (model.PairOrders, error) {
+
+	snapshot, err := p.GetSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(model.PairOrders, len(pairs))
+	for _, pair := range pairs {
+		if order, ok := snapshot.Orders.GetOrder(pair); ok {
+			result[pair] = order
+		}
+	}
+
+	return result, nil
+
+}
+
+// Arbitrage of the latest snapshot, results are shared by the same queries
+// until the snapshot changes, e.g. by every stream with the query
+func (p *Poller) GetArbitrage(ctx context.Context, query ArbitrageQuery) ([]model.Arbitrage, error) {
+
+	snapshot, err := p.GetSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := p.cache.get([]*model.Snapshot{snapshot}, query, func() []model.Arbitrage {
+		return p.search(snapshot, query)
+	})
+
+	return result, nil
+
+}
+
+func (p *Poller) search(snapshot *model.Snapshot, query ArbitrageQuery) (result []model.Arbitrage) {
+
+	// Longest living routes may be anywhere in the profit order
+	limit := query.Limit
+	if query.Sort == SortLifetime {
+		query.Limit = 0
+	}
+
+	// Unfiltered search is already done in the snapshot
+	if query.unfiltered() {
+		result = query.apply(snapshot.Arbitrage)
+	} else {
+		result = p.arbitrage.Search(snapshot, query)
+		p.mu.RLock()
+		p.tracker.annotate(result)
+		p.mu.RUnlock()
+	}
+
+	if query.Sort == SortLifetime {
+		sortLifetime(result)
+		if limit > 0 && len(result) > limit {
+			result = result[:limit]
+		}
+	}
+
+	return
+
+}
+
+func (p *Poller) GetCycles(ctx context.Context, maxLegs int, base model.Currency) ([]model.Arbitrage, error) {
+	return p.GetArbitrage(ctx, ArbitrageQuery{
+		Base:    base,
+		MaxLegs: maxLegs,
+	})
+}
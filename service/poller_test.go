@@ -0,0 +1,251 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	testifyMock "github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/api"
+	"github.com/tusupov/exmoarbitrage/api/mock"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+func TestPoller(t *testing.T) {
+
+	ctx := context.Background()
+
+	pairOrders := model.PairOrders{
+		"BTC_USD": model.Order{
+			Bid: model.Offer{Price: 3800},
+			Ask: model.Offer{Price: 3700},
+		},
+	}
+
+	exmoApiMock := mock.NewExmo()
+	exmoApiMock.On("GetCurrencyList", testifyMock.Anything).Return([]model.Currency{"BTC", "USD"}, nil)
+	exmoApiMock.On("GetPairList", testifyMock.Anything).Return(model.PairSettings{}, nil)
+
+	poller := NewPoller(NewArbitrage(exmoApiMock), time.Second)
+
+	// Nothing loaded yet
+	_, err := poller.GetArbitrage(ctx, ArbitrageQuery{})
+	assert.Equal(t, ErrNoSnapshot, err)
+
+	assert.True(t, poller.Updated().IsZero())
+
+	exmoApiMock.On("GetOrders", testifyMock.Anything, []model.Pair{}).Return(pairOrders, nil).Once()
+	exmoApiMock.On("GetOrders", testifyMock.Anything, []model.Pair{}).Return(model.PairOrders(nil), errors.New("upstream"))
+	assert.Nil(t, poller.Refresh(ctx))
+
+	updated := poller.Updated()
+	assert.False(t, updated.IsZero())
+
+	snapshot, err := poller.GetSnapshot(ctx)
+	assert.Nil(t, err)
+	assert.Len(t, snapshot.Arbitrage, 1)
+
+	// Readers are served from the snapshot
+	for i := 0; i < 3; i++ {
+		result, err := poller.GetArbitrage(ctx, ArbitrageQuery{})
+		assert.Nil(t, err)
+		assert.Len(t, result, 1)
+
+		result, err = poller.GetArbitrage(ctx, ArbitrageQuery{Base: "USD"})
+		assert.Nil(t, err)
+		if assert.Len(t, result, 1) {
+			assert.Equal(t, []model.Currency{"USD", "BTC", "USD"}, result[0].Route)
+		}
+	}
+	exmoApiMock.AssertNumberOfCalls(t, "GetOrders", 1)
+
+	pairs, err := poller.GetOrders(ctx, "BTC_USD", "ETH_USD")
+	assert.Nil(t, err)
+	assert.Equal(t, pairOrders, pairs)
+
+	// Failed refresh keeps the previous snapshot
+	assert.NotNil(t, poller.Refresh(ctx))
+
+	last, err := poller.Last()
+	assert.NotNil(t, err)
+	assert.Equal(t, snapshot, last)
+	assert.Equal(t, updated, poller.Updated())
+
+	result, err := poller.GetArbitrage(ctx, ArbitrageQuery{})
+	assert.Nil(t, err)
+	assert.Len(t, result, 1)
+
+}
+
+// Streaming api with a fixed data time
+type testStreamApi struct {
+	api.Apier
+	updated time.Time
+}
+
+func (a testStreamApi) Updated() time.Time {
+	return a.updated
+}
+
+func TestPoller_Updated(t *testing.T) {
+
+	exmoApiMock := mock.NewExmo()
+	exmoApiMock.On("GetCurrencyList", testifyMock.Anything).Return([]model.Currency{"BTC", "USD"}, nil)
+	exmoApiMock.On("GetPairList", testifyMock.Anything).Return(model.PairSettings{}, nil)
+	exmoApiMock.On("GetOrders", testifyMock.Anything, []model.Pair{}).Return(model.PairOrders{}, nil)
+
+	// Stale stream is reported with a fresh snapshot
+	updated := time.Now().Add(-time.Hour)
+	poller := NewPoller(NewArbitrage(testStreamApi{Apier: exmoApiMock, updated: updated}), time.Second)
+	assert.Nil(t, poller.Refresh(context.Background()))
+	assert.Equal(t, updated, poller.Updated())
+
+}
+
+func TestPoller_Subscribe(t *testing.T) {
+
+	exmoApiMock := mock.NewExmo()
+	exmoApiMock.On("GetCurrencyList", testifyMock.Anything).Return([]model.Currency{"BTC", "USD"}, nil)
+	exmoApiMock.On("GetPairList", testifyMock.Anything).Return(model.PairSettings{}, nil)
+	exmoApiMock.On("GetOrders", testifyMock.Anything, []model.Pair{}).Return(model.PairOrders{}, nil)
+
+	poller := NewPoller(NewArbitrage(exmoApiMock), time.Hour)
+
+	updates, unsubscribe := poller.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		poller.Run(ctx)
+		close(done)
+	}()
+
+	// First refresh is done immediately
+	snapshot := <-updates
+	last, _ := poller.Last()
+	assert.Equal(t, last, snapshot)
+
+	cancel()
+	<-done
+
+	_, ok := <-updates
+	assert.False(t, ok)
+
+	// Subscribers after stop get a closed channel
+	updates, _ = poller.Subscribe()
+	_, ok = <-updates
+	assert.False(t, ok)
+
+}
+
+func TestPoller_SubscribeQueue(t *testing.T) {
+
+	poller := NewPoller(nil, time.Hour)
+
+	updates, unsubscribe := poller.SubscribeQueue(2)
+	defer unsubscribe()
+
+	latest, _ := poller.Subscribe()
+
+	snapshots := []*model.Snapshot{{}, {}, {}}
+	for _, snapshot := range snapshots {
+		poller.notify(snapshot)
+	}
+
+	// Queued in order, the one over the size is dropped
+	assert.True(t, snapshots[0] == <-updates)
+	assert.True(t, snapshots[1] == <-updates)
+	assert.Len(t, updates, 0)
+
+	assert.True(t, snapshots[2] == <-latest)
+
+	poller.stop()
+	_, ok := <-updates
+	assert.False(t, ok)
+
+}
+
+func TestPoller_GetArbitrage_lifetime(t *testing.T) {
+
+	ctx := context.Background()
+
+	btc := model.Order{
+		Bid: model.Offer{Price: 3800},
+		Ask: model.Offer{Price: 3700},
+	}
+	eth := model.Order{
+		Bid: model.Offer{Price: 150},
+		Ask: model.Offer{Price: 100},
+	}
+
+	exmoApiMock := mock.NewExmo()
+	exmoApiMock.On("GetCurrencyList", testifyMock.Anything).Return([]model.Currency{"BTC", "ETH", "USD"}, nil)
+	exmoApiMock.On("GetPairList", testifyMock.Anything).Return(model.PairSettings{}, nil)
+	exmoApiMock.On("GetOrders", testifyMock.Anything, []model.Pair{}).Return(model.PairOrders{"BTC_USD": btc}, nil).Once()
+	exmoApiMock.On("GetOrders", testifyMock.Anything, []model.Pair{}).Return(model.PairOrders{"BTC_USD": btc, "ETH_USD": eth}, nil)
+
+	poller := NewPoller(NewArbitrage(exmoApiMock), time.Second)
+	assert.Nil(t, poller.Refresh(ctx))
+	time.Sleep(time.Millisecond)
+	assert.Nil(t, poller.Refresh(ctx))
+
+	// More profitable ETH route is first by profit
+	result, err := poller.GetArbitrage(ctx, ArbitrageQuery{Limit: 1})
+	assert.Nil(t, err)
+	if assert.Len(t, result, 1) {
+		assert.Contains(t, result[0].Route, model.Currency("ETH"))
+	}
+
+	for _, query := range []ArbitrageQuery{
+		{Limit: 1, Sort: SortLifetime},
+		{Limit: 1, Sort: SortLifetime, Base: "USD"},
+	} {
+		result, err := poller.GetArbitrage(ctx, query)
+		assert.Nil(t, err)
+		if assert.Len(t, result, 1) && assert.NotNil(t, result[0].Lifetime) {
+			assert.Contains(t, result[0].Route, model.Currency("BTC"))
+			assert.Equal(t, 2, result[0].Lifetime.Seen)
+		}
+	}
+
+}
+
+func TestPoller_GetArbitrage_cache(t *testing.T) {
+
+	ctx := context.Background()
+
+	exmoApiMock := mock.NewExmo()
+	exmoApiMock.On("GetCurrencyList", testifyMock.Anything).Return([]model.Currency{"BTC", "USD"}, nil)
+	exmoApiMock.On("GetPairList", testifyMock.Anything).Return(model.PairSettings{}, nil)
+	exmoApiMock.On("GetOrders", testifyMock.Anything, []model.Pair{}).Return(model.PairOrders{
+		"BTC_USD": model.Order{
+			Bid: model.Offer{Price: 3800},
+			Ask: model.Offer{Price: 3700},
+		},
+	}, nil)
+
+	poller := NewPoller(NewArbitrage(exmoApiMock), time.Second)
+	assert.Nil(t, poller.Refresh(ctx))
+
+	result, err := poller.GetArbitrage(ctx, ArbitrageQuery{Currencies: []model.Currency{"USD", "BTC"}})
+	assert.Nil(t, err)
+	assert.Len(t, result, 1)
+
+	// Same snapshot and query in another order are served from cache
+	cached, err := poller.GetArbitrage(ctx, ArbitrageQuery{Currencies: []model.Currency{"BTC", "USD"}})
+	assert.Nil(t, err)
+	if assert.Len(t, cached, 1) {
+		assert.True(t, &result[0] == &cached[0])
+	}
+
+	// New snapshot is searched again
+	assert.Nil(t, poller.Refresh(ctx))
+	cached, err = poller.GetArbitrage(ctx, ArbitrageQuery{Currencies: []model.Currency{"USD", "BTC"}})
+	assert.Nil(t, err)
+	if assert.Len(t, cached, 1) {
+		assert.False(t, &result[0] == &cached[0])
+	}
+
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Arbitrage search parameters, zero values mean no restriction
+type ArbitrageQuery struct {
+	Base      model.Currency // route start and end currency
+	MaxLegs   int            // max route legs, 0 - profitable cycles of any length
+	MinProfit *float64       // min net profit, percent
+	Limit     int            // max result count
+	Sort      string         // result order, SortProfit by default
+
+	Currencies        []model.Currency // route uses only these currencies
+	ExcludeCurrencies []model.Currency // route does not use these currencies
+	Pairs             []model.Pair     // route uses only these pairs
+	ExcludePairs      []model.Pair     // route does not use these pairs
+}
+
+// Max legs of a simple cycles query, the cycles count grows exponentially with legs
+const MaxQueryLegs = 5
+
+// Query result orders
+const (
+	SortProfit   = "profit"   // net profit descending
+	SortLifetime = "lifetime" // time the route has been seen for descending
+)
+
+// Query selects from all profitable cycles without restricting the search
+func (q ArbitrageQuery) unfiltered() bool {
+	return q.Base == "" && q.MaxLegs == 0 &&
+		len(q.Currencies) == 0 && len(q.ExcludeCurrencies) == 0 &&
+		len(q.Pairs) == 0 && len(q.ExcludePairs) == 0
+}
+
+func (q ArbitrageQuery) allowCurrency(currency model.Currency) bool {
+
+	if containsCurrency(q.ExcludeCurrencies, currency) {
+		return false
+	}
+
+	if len(q.Currencies) > 0 && currency != q.Base && !containsCurrency(q.Currencies, currency) {
+		return false
+	}
+
+	return true
+
+}
+
+func (q ArbitrageQuery) allowPair(pair model.Pair) bool {
+
+	base, quote, ok := pair.Split()
+	if !ok || !q.allowCurrency(base) || !q.allowCurrency(quote) {
+		return false
+	}
+
+	if containsPair(q.ExcludePairs, pair) {
+		return false
+	}
+
+	if len(q.Pairs) > 0 && !containsPair(q.Pairs, pair) {
+		return false
+	}
+
+	return true
+
+}
+
+// Currencies and orders the route search may use
+func (q ArbitrageQuery) filter(currencyList []model.Currency, pairOrders model.PairOrders) ([]model.Currency, model.PairOrders) {
+
+	currencies := make([]model.Currency, 0, len(currencyList))
+	for _, currency := range currencyList {
+		if q.allowCurrency(currency) {
+			currencies = append(currencies, currency)
+		}
+	}
+
+	orders := make(model.PairOrders, len(pairOrders))
+	for pair, order := range pairOrders {
+		if q.allowPair(pair) {
+			orders[pair] = order
+		}
+	}
+
+	return currencies, orders
+
+}
+
+// Keep routes through the base currency, rotated to start from it
+func (q ArbitrageQuery) rebase(list []model.Arbitrage) []model.Arbitrage {
+
+	if q.Base == "" {
+		return list
+	}
+
+	result := make([]model.Arbitrage, 0, len(list))
+	for _, arbitrage := range list {
+		if route, ok := rotateRoute(arbitrage.Route, q.Base); ok {
+			arbitrage.Route = route
+			result = append(result, arbitrage)
+		}
+	}
+
+	return result
+
+}
+
+// Apply min profit and limit to the sorted routes
+func (q ArbitrageQuery) apply(list []model.Arbitrage) (result []model.Arbitrage) {
+
+	for _, arbitrage := range list {
+
+		if q.MinProfit != nil && (arbitrage.NetProfit-1)*100 < *q.MinProfit {
+			continue
+		}
+
+		result = append(result, arbitrage)
+
+		if q.Limit > 0 && len(result) == q.Limit {
+			break
+		}
+
+	}
+
+	return
+
+}
+
+// Rotate closed route to start and end at base
+func rotateRoute(route []model.Currency, base model.Currency) ([]model.Currency, bool) {
+
+	if len(route) < 2 {
+		return nil, false
+	}
+
+	cycle := route[:len(route)-1]
+	for i, currency := range cycle {
+		if currency == base {
+			result := make([]model.Currency, 0, len(route))
+			result = append(result, cycle[i:]...)
+			result = append(result, cycle[:i]...)
+			result = append(result, base)
+			return result, true
+		}
+	}
+
+	return nil, false
+
+}
+
+func containsCurrency(list []model.Currency, currency model.Currency) bool {
+	for _, item := range list {
+		if item == currency {
+			return true
+		}
+	}
+	return false
+}
+
+func containsPair(list []model.Pair, pair model.Pair) bool {
+	for _, item := range list {
+		if item == pair {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/api/mock"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+func TestArbitrageService_GetArbitrage_Query(t *testing.T) {
+
+	ctx := context.Background()
+
+	currencyList := []model.Currency{"USD", "BTC", "ETH", "EUR"}
+	pairOrders := model.PairOrders{
+		"BTC_USD": model.Order{
+			Bid: model.Offer{Price: 4000},
+			Ask: model.Offer{Price: 4000},
+		},
+		"ETH_BTC": model.Order{
+			Bid: model.Offer{Price: 0.05},
+			Ask: model.Offer{Price: 0.05},
+		},
+		"ETH_USD": model.Order{
+			Bid: model.Offer{Price: 210},
+			Ask: model.Offer{Price: 210},
+		},
+		"BTC_EUR": model.Order{
+			Bid: model.Offer{Price: 3500},
+			Ask: model.Offer{Price: 3500},
+		},
+		"EUR_USD": model.Order{
+			Bid: model.Offer{Price: 1.2},
+			Ask: model.Offer{Price: 1.2},
+		},
+	}
+
+	minProfit := 4.0
+	highMinProfit := 6.0
+
+	testCase := []struct {
+		Query  ArbitrageQuery
+		Result [][]model.Currency
+	}{
+		{
+			Query: ArbitrageQuery{},
+			Result: [][]model.Currency{
+				{"BTC", "ETH", "USD", "BTC"},
+				{"BTC", "EUR", "USD", "BTC"},
+			},
+		},
+		{
+			Query: ArbitrageQuery{Base: "USD"},
+			Result: [][]model.Currency{
+				{"USD", "BTC", "ETH", "USD"},
+				{"USD", "BTC", "EUR", "USD"},
+			},
+		},
+		{
+			Query: ArbitrageQuery{Base: "ETH"},
+			Result: [][]model.Currency{
+				{"ETH", "USD", "BTC", "ETH"},
+			},
+		},
+		{
+			Query: ArbitrageQuery{ExcludeCurrencies: []model.Currency{"EUR"}},
+			Result: [][]model.Currency{
+				{"BTC", "ETH", "USD", "BTC"},
+			},
+		},
+		{
+			Query: ArbitrageQuery{Currencies: []model.Currency{"BTC", "EUR"}, Base: "USD"},
+			Result: [][]model.Currency{
+				{"USD", "BTC", "EUR", "USD"},
+			},
+		},
+		{
+			Query: ArbitrageQuery{ExcludePairs: []model.Pair{"ETH_USD"}},
+			Result: [][]model.Currency{
+				{"BTC", "EUR", "USD", "BTC"},
+			},
+		},
+		{
+			Query: ArbitrageQuery{Pairs: []model.Pair{"BTC_USD", "ETH_BTC", "ETH_USD"}},
+			Result: [][]model.Currency{
+				{"BTC", "ETH", "USD", "BTC"},
+			},
+		},
+		{
+			Query:  ArbitrageQuery{Limit: 1},
+			Result: [][]model.Currency{{}},
+		},
+		{
+			Query: ArbitrageQuery{MinProfit: &minProfit},
+			Result: [][]model.Currency{
+				{"BTC", "ETH", "USD", "BTC"},
+				{"BTC", "EUR", "USD", "BTC"},
+			},
+		},
+		{
+			Query:  ArbitrageQuery{MinProfit: &highMinProfit},
+			Result: nil,
+		},
+		{
+			Query: ArbitrageQuery{Base: "EUR", MaxLegs: 2},
+			Result: [][]model.Currency{
+				{"EUR", "USD", "EUR"},
+				{"EUR", "BTC", "EUR"},
+			},
+		},
+	}
+
+	for _, test := range testCase {
+
+		exmoApiMock := mock.NewExmo()
+		exmoApiMock.On("GetCurrencyList", ctx).Return(currencyList, nil)
+		exmoApiMock.On("GetPairList", ctx).Return(model.PairSettings{}, nil)
+		exmoApiMock.On("GetOrders", ctx, []model.Pair{}).Return(pairOrders, nil)
+
+		arbitrageService := NewArbitrage(exmoApiMock)
+
+		result, err := arbitrageService.GetArbitrage(ctx, test.Query)
+		if !assert.Nil(t, err) || !assert.Equal(t, len(test.Result), len(result), "%+v", test.Query) {
+			continue
+		}
+
+		if test.Query.Limit > 0 {
+			continue
+		}
+
+		routes := make([][]model.Currency, 0, len(result))
+		for _, arbitrage := range result {
+			routes = append(routes, arbitrage.Route)
+		}
+		assert.ElementsMatch(t, test.Result, routes, "%+v", test.Query)
+
+	}
+
+}
+
+func TestHistoryQuery_Match(t *testing.T) {
+
+	start := time.Date(2019, 2, 1, 10, 0, 0, 0, time.UTC)
+	opportunity := model.Opportunity{
+		Time:      start,
+		Route:     []model.Currency{"USD", "BTC", "USD"},
+		NetProfit: 1.01,
+	}
+
+	minProfit := 0.5
+	highMinProfit := 1.5
+
+	assert.True(t, HistoryQuery{}.Match(opportunity))
+	assert.True(t, HistoryQuery{From: start, To: start, Currency: "BTC", MinProfit: &minProfit}.Match(opportunity))
+	assert.False(t, HistoryQuery{From: start.Add(time.Second)}.Match(opportunity))
+	assert.False(t, HistoryQuery{To: start.Add(-time.Second)}.Match(opportunity))
+	assert.False(t, HistoryQuery{Currency: "ETH"}.Match(opportunity))
+	assert.False(t, HistoryQuery{MinProfit: &highMinProfit}.Match(opportunity))
+
+}
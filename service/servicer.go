@@ -2,10 +2,53 @@ package service
 
 import (
 	"context"
+	"time"
 	"github.com/tusupov/exmoarbitrage/model"
 )
 
 type Servicer interface {
 	GetCurrencyList(context.Context) ([]model.Currency, error)
-	GetArbitrage(context.Context) ([]model.Arbitrage, error)
+	GetPairList(context.Context) (model.PairSettings, error)
+	GetOrders(ctx context.Context, pairs ...model.Pair) (model.PairOrders, error)
+	GetArbitrage(context.Context, ArbitrageQuery) ([]model.Arbitrage, error)
+	GetCycles(ctx context.Context, maxLegs int, base model.Currency) ([]model.Arbitrage, error)
+	GetSnapshot(context.Context) (*model.Snapshot, error)
+}
+
+// Latest market snapshot source
+type Snapshotter interface {
+	GetSnapshot(context.Context) (*model.Snapshot, error)
+}
+
+// Snapshot updates source
+type Notifier interface {
+	Subscribe() (updates <-chan *model.Snapshot, unsubscribe func())
+}
+
+// Snapshot updates without loss while the subscriber keeps up
+type QueueNotifier interface {
+	SubscribeQueue(size int) (updates <-chan *model.Snapshot, unsubscribe func())
+}
+
+// Arbitrage route execution with orders
+type Executer interface {
+	Execute(ctx context.Context, route []model.Currency, volume float64) (model.Execution, error)
+	GetExecutions() []model.Execution
+	GetExecution(id int64) (model.Execution, bool)
+}
+
+// Saved arbitrage opportunities
+type Historian interface {
+	GetHistory(context.Context, HistoryQuery) ([]model.Opportunity, error)
+}
+
+// Background market data refresh state
+type Refresher interface {
+	Last() (*model.Snapshot, error)
+	Updated() time.Time // upstream data time
+}
+
+// Arbitrage across several exchanges
+type CrossServicer interface {
+	GetArbitrage(context.Context, ArbitrageQuery) ([]model.Arbitrage, error)
 }
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"sort"
+	"strings"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// Tracks lifetime of routes across consecutive snapshots.
+// A route missing from a snapshot starts a new lifetime when it appears again.
+type tracker struct {
+	routes map[string]model.Lifetime
+}
+
+func newTracker() *tracker {
+	return &tracker{
+		routes: map[string]model.Lifetime{},
+	}
+}
+
+// Update lifetimes with the snapshot routes and set them on the snapshot arbitrage
+func (t *tracker) update(snapshot *model.Snapshot) {
+
+	routes := make(map[string]model.Lifetime, len(snapshot.Arbitrage))
+
+	for i := range snapshot.Arbitrage {
+
+		arbitrage := &snapshot.Arbitrage[i]
+		key := CycleKey(arbitrage.Route)
+
+		lifetime, ok := t.routes[key]
+		if !ok {
+			lifetime = model.Lifetime{
+				FirstSeen:  snapshot.Time,
+				PeakProfit: arbitrage.NetProfit,
+			}
+		}
+
+		lifetime.LastSeen = snapshot.Time
+		lifetime.AvgProfit = (lifetime.AvgProfit*float64(lifetime.Seen) + arbitrage.NetProfit) / float64(lifetime.Seen+1)
+		lifetime.Seen++
+		if arbitrage.NetProfit > lifetime.PeakProfit {
+			lifetime.PeakProfit = arbitrage.NetProfit
+		}
+
+		routes[key] = lifetime
+		arbitrage.Lifetime = &lifetime
+
+	}
+
+	t.routes = routes
+
+}
+
+// Set lifetimes of tracked routes on list of another query
+func (t *tracker) annotate(list []model.Arbitrage) {
+	for i := range list {
+		if lifetime, ok := t.routes[CycleKey(list[i].Route)]; ok {
+			list[i].Lifetime = &lifetime
+		}
+	}
+}
+
+// Sort by lifetime descending, untracked routes last
+func sortLifetime(list []model.Arbitrage) {
+	sort.SliceStable(list, func(i, j int) bool {
+		return lifetimeOf(list[i]) > lifetimeOf(list[j])
+	})
+}
+
+func lifetimeOf(arbitrage model.Arbitrage) float64 {
+	if arbitrage.Lifetime == nil {
+		return -1
+	}
+	return arbitrage.Lifetime.Duration().Seconds()
+}
+
+// Key of closed route independent of the start currency
+func CycleKey(route []model.Currency) string {
+
+	if len(route) < 2 {
+		return ""
+	}
+
+	cycle := route[:len(route)-1]
+
+	start := 0
+	for i, currency := range cycle {
+		if currency < cycle[start] {
+			start = i
+		}
+	}
+
+	list := make([]string, 0, len(cycle))
+	for i := range cycle {
+		list = append(list, string(cycle[(start+i)%len(cycle)]))
+	}
+
+	return strings.Join(list, "-")
+
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+func TestTracker(t *testing.T) {
+
+	start := time.Date(2019, 2, 1, 10, 0, 0, 0, time.UTC)
+	snapshot := func(seconds int, list ...model.Arbitrage) *model.Snapshot {
+		return &model.Snapshot{
+			Time:      start.Add(time.Duration(seconds) * time.Second),
+			Arbitrage: list,
+		}
+	}
+
+	btc := []model.Currency{"USD", "BTC", "EUR", "USD"}
+	eth := []model.Currency{"USD", "ETH", "USD"}
+
+	tracker := newTracker()
+
+	first := snapshot(0, model.Arbitrage{Route: btc, NetProfit: 1.01}, model.Arbitrage{Route: eth, NetProfit: 1.02})
+	tracker.update(first)
+	assert.Equal(t, &model.Lifetime{
+		FirstSeen:  start,
+		LastSeen:   start,
+		PeakProfit: 1.01,
+		AvgProfit:  1.01,
+		Seen:       1,
+	}, first.Arbitrage[0].Lifetime)
+
+	// The same cycle from another start currency, eth route is gone
+	second := snapshot(5, model.Arbitrage{Route: []model.Currency{"EUR", "USD", "BTC", "EUR"}, NetProfit: 1.03})
+	tracker.update(second)
+	lifetime := second.Arbitrage[0].Lifetime
+	if assert.NotNil(t, lifetime) {
+		assert.Equal(t, start, lifetime.FirstSeen)
+		assert.Equal(t, 5*time.Second, lifetime.Duration())
+		assert.Equal(t, 1.03, lifetime.PeakProfit)
+		assert.InDelta(t, 1.02, lifetime.AvgProfit, 1e-9)
+		assert.Equal(t, 2, lifetime.Seen)
+	}
+
+	// Previous snapshot is not changed
+	assert.Equal(t, 1, first.Arbitrage[0].Lifetime.Seen)
+
+	// Reappeared route starts a new lifetime
+	third := snapshot(10, model.Arbitrage{Route: eth, NetProfit: 1.02})
+	tracker.update(third)
+	assert.Equal(t, 1, third.Arbitrage[0].Lifetime.Seen)
+	assert.Equal(t, third.Time, third.Arbitrage[0].Lifetime.FirstSeen)
+
+	list := []model.Arbitrage{{Route: []model.Currency{"ETH", "USD", "ETH"}}, {Route: btc}}
+	tracker.annotate(list)
+	assert.NotNil(t, list[0].Lifetime)
+	assert.Nil(t, list[1].Lifetime)
+
+}
+
+func TestCycleKey(t *testing.T) {
+	assert.Equal(t, "BTC-EUR-USD", CycleKey([]model.Currency{"USD", "BTC", "EUR", "USD"}))
+	assert.Equal(t, "BTC-EUR-USD", CycleKey([]model.Currency{"EUR", "USD", "BTC", "EUR"}))
+	assert.Equal(t, "BTC-USD-EUR", CycleKey([]model.Currency{"USD", "EUR", "BTC", "USD"}))
+	assert.Equal(t, "", CycleKey(nil))
+}
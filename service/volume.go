@@ -0,0 +1,432 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+// One exchange step of a route: from -> to through an order book side
+type leg struct {
+	pair   model.Pair
+	offers []model.Offer
+	sell   bool // true: sell `from` at bids, false: buy `to` at asks
+
+	transfer bool    // move between exchanges, no order book
+	withdraw float64 // fixed transfer fee
+}
+
+// Find leg between two currencies
+func newLeg(from, to model.Currency, pairOrders model.PairOrders) (l leg, ok bool) {
+
+	pair := model.Pair(from + "_" + to)
+	if order, exists := pairOrders.GetOrder(pair); exists {
+		l.pair, l.offers, l.sell = pair, order.Bids, true
+		if len(l.offers) == 0 {
+			l.offers = []model.Offer{order.Bid}
+		}
+		return l, true
+	}
+
+	if order, exists := pairOrders.GetOrder(pair.Reverse()); exists {
+		l.pair, l.offers, l.sell = pair.Reverse(), order.Asks, false
+		if len(l.offers) == 0 {
+			l.offers = []model.Offer{order.Ask}
+		}
+		return l, true
+	}
+
+	return
+}
+
+// Input and output capacity of an order book level
+func (l leg) level(offer model.Offer) (in, out float64) {
+	if l.sell {
+		return offer.Quantity, offer.Quantity * offer.Price
+	}
+	return offer.Quantity * offer.Price, offer.Quantity
+}
+
+// Maximum input the leg can absorb
+func (l leg) capacity() (in float64) {
+	if l.transfer {
+		return math.Inf(1)
+	}
+	for _, offer := range l.offers {
+		levelIn, _ := l.level(offer)
+		in += levelIn
+	}
+	return
+}
+
+// Output received for input, walking the ladder
+func (l leg) fill(in float64) (out float64) {
+
+	if l.transfer {
+		return math.Max(in-l.withdraw, 0)
+	}
+
+	for _, offer := range l.offers {
+
+		if in <= 0 {
+			break
+		}
+
+		levelIn, levelOut := l.level(offer)
+		if levelIn <= 0 {
+			continue
+		}
+
+		take := math.Min(in, levelIn)
+		out += levelOut * take / levelIn
+		in -= take
+
+	}
+
+	return
+}
+
+// Input required to receive out, walking the ladder
+func (l leg) inverse(out float64) (in float64) {
+
+	if math.IsInf(out, 1) {
+		return out
+	}
+
+	if l.transfer {
+		return out + l.withdraw
+	}
+
+	for _, offer := range l.offers {
+
+		if out <= 0 {
+			break
+		}
+
+		levelIn, levelOut := l.level(offer)
+		if levelOut <= 0 {
+			continue
+		}
+
+		take := math.Min(out, levelOut)
+		in += levelIn * take / levelOut
+		out -= take
+
+	}
+
+	return
+}
+
+// Rate of the order book level the input ends at, 0 past the book depth
+func (l leg) marginal(in float64) float64 {
+
+	if l.transfer {
+		return 1
+	}
+
+	for _, offer := range l.offers {
+
+		levelIn, levelOut := l.level(offer)
+		if levelIn <= 0 {
+			continue
+		}
+
+		if in < levelIn {
+			return levelOut / levelIn
+		}
+		in -= levelIn
+
+	}
+
+	return 0
+}
+
+// Top of book exchange rate
+func (l leg) rate() float64 {
+
+	if l.transfer {
+		return 1
+	}
+
+	if len(l.offers) == 0 || l.offers[0].Price == 0 {
+		return 0
+	}
+
+	if l.sell {
+		return l.offers[0].Price
+	}
+	return 1 / l.offers[0].Price
+}
+
+// Legs of the route, false if some pair is not traded
+func routeLegs(route []model.Currency, pairOrders model.PairOrders) (legs []leg, ok bool) {
+
+	if len(route) < 2 {
+		return
+	}
+
+	legs = make([]leg, 0, len(route)-1)
+	for i := 0; i+1 < len(route); i++ {
+		l, exists := newLeg(route[i], route[i+1], pairOrders)
+		if !exists {
+			return nil, false
+		}
+		legs = append(legs, l)
+	}
+
+	return legs, true
+}
+
+// Maximum input of the first leg executable through the whole route
+func routeCapacity(legs []leg) (volume float64) {
+
+	// Backward pass: each leg may take no more than it can absorb
+	// and no more than the next leg can absorb from its output
+	volume = math.Inf(1)
+	for i := len(legs) - 1; i >= 0; i-- {
+		volume = math.Min(legs[i].capacity(), legs[i].inverse(volume))
+	}
+
+	return
+}
+
+// Largest input of the first leg worth executing: order book levels
+// are consumed while the product of their rates along the route is above 1
+func routeProfitableVolume(legs []leg) (volume float64) {
+
+	capacity := routeCapacity(legs)
+
+	// Start volumes at which some leg moves to its next level,
+	// the marginal rate of the route is constant between them
+	bounds := []float64{capacity}
+	for i, l := range legs {
+
+		if l.transfer {
+			continue
+		}
+
+		in := 0.0
+		for _, offer := range l.offers {
+			levelIn, _ := l.level(offer)
+			in += levelIn
+			if bound := routeInverse(legs[:i], in); bound < capacity {
+				bounds = append(bounds, bound)
+			}
+		}
+
+	}
+	sort.Float64s(bounds)
+
+	for _, bound := range bounds {
+
+		if bound <= volume {
+			continue
+		}
+
+		if routeMarginal(legs, (volume+bound)/2) <= 1 {
+			break
+		}
+		volume = bound
+
+	}
+
+	return
+}
+
+// Product of the level rates the route input ends at on every leg
+func routeMarginal(legs []leg, amount float64) float64 {
+	rate := 1.0
+	for _, l := range legs {
+		rate *= l.marginal(amount)
+		amount = l.fill(amount)
+	}
+	return rate
+}
+
+// Execute amount through the whole route
+func routeFill(legs []leg, amount float64) float64 {
+	for _, l := range legs {
+		amount = l.fill(amount)
+	}
+	return amount
+}
+
+// Input of the first leg required to receive out from the whole route
+func routeInverse(legs []leg, out float64) float64 {
+	for i := len(legs) - 1; i >= 0; i-- {
+		out = legs[i].inverse(out)
+	}
+	return out
+}
+
+// Product of top of book rates along the route
+func routeRate(legs []leg) float64 {
+	rate := 1.0
+	for _, l := range legs {
+		rate *= l.rate()
+	}
+	return rate
+}
+
+// Maximum profitable volume of the route in its start currency
+// and the profit received at that volume
+func routeVolume(route []model.Currency, pairOrders model.PairOrders) (volume, profit float64) {
+
+	legs, ok := routeLegs(route, pairOrders)
+	if !ok {
+		return
+	}
+
+	volume = routeProfitableVolume(legs)
+	profit = routeFill(legs, volume) - volume
+
+	return
+}
+
+// Fill gross and net profit and volume fields of arbitrage list.
+// Volume is the profitable part of the order books with commission
+// applied, limited by the exchange pair limits.
+// Every leg trades on one exchange, only the route result leaves it:
+// with a profitable volume both net fields pay one withdrawal
+// of the start currency, the net profit spread over the volume.
+func fillProfit(list []model.Arbitrage, pairOrders, netOrders model.PairOrders, fee model.FeeSettings, pairSettings model.PairSettings) {
+
+	for i := range list {
+
+		route := list[i].Route
+
+		grossLegs, ok := routeLegs(route, pairOrders)
+		if !ok {
+			continue
+		}
+
+		netLegs, ok := routeLegs(route, netOrders)
+		if !ok {
+			continue
+		}
+
+		volume, violations := applyLimits(grossLegs, netLegs, routeProfitableVolume(netLegs), pairSettings)
+
+		list[i].Profit = routeRate(grossLegs)
+		list[i].NetProfit = routeRate(netLegs)
+		list[i].Volume = volume
+		list[i].VolumeProfit = routeFill(grossLegs, volume) - volume
+		list[i].NetVolumeProfit = 0
+		list[i].Violations = violations
+
+		if volume > 0 {
+			withdraw := fee.GetWithdraw(route[0])
+			list[i].NetProfit -= withdraw / volume
+			list[i].NetVolumeProfit = routeFill(netLegs, volume) - volume - withdraw
+		}
+
+	}
+
+}
+
+// Amounts held at every route step when volume of the start currency
+// is executed through the order books with trading fee.
+// Volume is reduced to the books depth, false if some pair is not traded.
+func (s *ArbitrageService) RouteAmounts(pairOrders model.PairOrders, route []model.Currency, volume float64) (amounts []float64, ok bool) {
+
+	legs, ok := routeLegs(route, applyFee(routeOrders(pairOrders, route), s.fee))
+	if !ok {
+		return
+	}
+
+	volume = math.Min(volume, routeCapacity(legs))
+
+	amounts = make([]float64, 0, len(route))
+	amounts = append(amounts, volume)
+	for _, l := range legs {
+		volume = l.fill(volume)
+		amounts = append(amounts, volume)
+	}
+
+	return amounts, true
+
+}
+
+// Order books left after the route is executed with volume of its start currency:
+// the taken part of every level is removed. Books are not changed if some pair is not traded.
+func (s *ArbitrageService) ConsumeOrders(pairOrders model.PairOrders, route []model.Currency, volume float64) (result model.PairOrders) {
+
+	result = make(model.PairOrders, len(pairOrders))
+	for pair, order := range pairOrders {
+		result[pair] = order
+	}
+
+	amounts, ok := s.RouteAmounts(pairOrders, route, volume)
+	if !ok {
+		return
+	}
+
+	legs, _ := routeLegs(route, applyFee(routeOrders(pairOrders, route), s.fee))
+
+	for i, l := range legs {
+
+		order := result[l.pair]
+
+		side, top := order.Asks, order.Ask
+		if l.sell {
+			side, top = order.Bids, order.Bid
+		}
+		if len(side) == 0 {
+			side = []model.Offer{top}
+		}
+
+		// Levels match the net leg levels one by one
+		in := amounts[i]
+		left := make([]model.Offer, 0, len(side))
+		for j, offer := range side {
+
+			levelIn, _ := l.level(l.offers[j])
+			if in > 0 && levelIn > 0 {
+				take := math.Min(in, levelIn)
+				in -= take
+				k := 1 - take/levelIn
+				offer.Quantity *= k
+				offer.Amount *= k
+				if k <= profitEPS {
+					continue
+				}
+			}
+
+			left = append(left, offer)
+
+		}
+
+		top = model.Offer{}
+		if len(left) > 0 {
+			top = left[0]
+		}
+
+		if l.sell {
+			order.Bids, order.Bid = left, top
+		} else {
+			order.Asks, order.Ask = left, top
+		}
+		result[l.pair] = order
+
+	}
+
+	return
+
+}
+
+// Order books of the route pairs, the fee is applied to them only
+func routeOrders(pairOrders model.PairOrders, route []model.Currency) model.PairOrders {
+
+	result := model.PairOrders{}
+	for i := 0; i+1 < len(route); i++ {
+		pair := model.Pair(route[i] + "_" + route[i+1])
+		for _, p := range []model.Pair{pair, pair.Reverse()} {
+			if order, exists := pairOrders.GetOrder(p); exists {
+				result[p] = order
+			}
+		}
+	}
+
+	return result
+
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"github.com/tusupov/exmoarbitrage/model"
+)
+
+func TestRouteVolume(t *testing.T) {
+
+	testCase := []struct {
+		Route        []model.Currency
+		PairOrders   model.PairOrders
+		Volume       float64
+		VolumeProfit float64
+	}{
+		{
+			// Second ask level costs more than the bids pay
+			Route: []model.Currency{"USD", "BTC", "USD"},
+			PairOrders: model.PairOrders{
+				"BTC_USD": model.Order{
+					Asks: []model.Offer{{Price: 3500, Quantity: 0.5}, {Price: 3700, Quantity: 1}},
+					Bids: []model.Offer{{Price: 3600, Quantity: 1}, {Price: 3400, Quantity: 1}},
+				},
+			},
+			Volume:       1750,
+			VolumeProfit: 50,
+		},
+		{
+			// Second bid level pays less than the asks cost
+			Route: []model.Currency{"USD", "BTC", "USD"},
+			PairOrders: model.PairOrders{
+				"BTC_USD": model.Order{
+					Asks: []model.Offer{{Price: 3500, Quantity: 2}},
+					Bids: []model.Offer{{Price: 3600, Quantity: 0.5}, {Price: 3400, Quantity: 1}},
+				},
+			},
+			Volume:       1750,
+			VolumeProfit: 50,
+		},
+		{
+			// Every level pair is profitable, the whole book depth
+			Route: []model.Currency{"USD", "BTC", "USD"},
+			PairOrders: model.PairOrders{
+				"BTC_USD": model.Order{
+					Asks: []model.Offer{{Price: 3500, Quantity: 0.5}, {Price: 3550, Quantity: 1}},
+					Bids: []model.Offer{{Price: 3600, Quantity: 1}, {Price: 3560, Quantity: 1}},
+				},
+			},
+			Volume:       5300,
+			VolumeProfit: 80,
+		},
+		{
+			// Unprofitable at the top of book
+			Route: []model.Currency{"BTC", "USD", "BTC"},
+			PairOrders: model.PairOrders{
+				"BTC_USD": model.Order{
+					Asks: []model.Offer{{Price: 4000, Quantity: 1}},
+					Bids: []model.Offer{{Price: 3600, Quantity: 2}},
+				},
+			},
+		},
+		{
+			Route:      []model.Currency{"USD", "EUR", "USD"},
+			PairOrders: model.PairOrders{},
+		},
+	}
+
+	for _, test := range testCase {
+		volume, profit := routeVolume(test.Route, test.PairOrders)
+		assert.InDelta(t, test.Volume, volume, 1e-9)
+		assert.InDelta(t, test.VolumeProfit, profit, 1e-9)
+		if volume > 0 {
+			assert.True(t, profit > 0)
+		}
+	}
+
+}
+
+func TestArbitrageService_ConsumeOrders(t *testing.T) {
+
+	pairOrders := model.PairOrders{
+		"BTC_USD": model.Order{
+			Ask:  model.Offer{Price: 3500, Quantity: 0.5, Amount: 1750},
+			Bid:  model.Offer{Price: 3600, Quantity: 1, Amount: 3600},
+			Asks: []model.Offer{{Price: 3500, Quantity: 0.5, Amount: 1750}, {Price: 3550, Quantity: 1, Amount: 3550}},
+			Bids: []model.Offer{{Price: 3600, Quantity: 1, Amount: 3600}},
+		},
+		"ETH_USD": model.Order{},
+	}
+
+	s := NewArbitrage(nil)
+	s.SetFee(model.FeeSettings{Fee: model.Fee{Taker: 0.1}})
+
+	// 1750 + 355 USD buy 0.5 + 0.1 BTC less fee, 0.54 BTC sold at bid
+	left := s.ConsumeOrders(pairOrders, []model.Currency{"USD", "BTC", "USD"}, 2105)
+
+	order := left["BTC_USD"]
+	if assert.Len(t, order.Asks, 1) {
+		assert.InDelta(t, 0.9, order.Asks[0].Quantity, 1e-9)
+		assert.InDelta(t, 0.9*3550, order.Asks[0].Amount, 1e-9)
+		assert.Equal(t, order.Asks[0], order.Ask)
+	}
+	if assert.Len(t, order.Bids, 1) {
+		assert.InDelta(t, 0.46, order.Bids[0].Quantity, 1e-9)
+		assert.Equal(t, order.Bids[0], order.Bid)
+	}
+	assert.Equal(t, model.Order{}, left["ETH_USD"])
+
+	// Source books are not changed
+	assert.Len(t, pairOrders["BTC_USD"].Asks, 2)
+	assert.Equal(t, 1.0, pairOrders["BTC_USD"].Bids[0].Quantity)
+
+}
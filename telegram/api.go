@@ -0,0 +1,125 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Bot API error response
+type ApiError struct {
+	Code        int
+	Description string
+}
+
+func (e *ApiError) Error() string {
+	return fmt.Sprintf("telegram: error %d: %s", e.Code, e.Description)
+}
+
+type apiResponse struct {
+	Ok          bool            `json:"ok"`
+	ErrorCode   int             `json:"error_code"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+type update struct {
+	UpdateId int64    `json:"update_id"`
+	Message  *message `json:"message"`
+}
+
+type message struct {
+	MessageId int64  `json:"message_id"`
+	Chat      chat   `json:"chat"`
+	Text      string `json:"text"`
+}
+
+type chat struct {
+	Id       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+type sendMessageRequest struct {
+	ChatId string `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+// Updates after offset, waits up to timeout seconds for new ones
+func (b *Bot) getUpdates(ctx context.Context, offset int64, timeout int) (updates []update, err error) {
+
+	values := url.Values{}
+	values.Set("offset", strconv.FormatInt(offset, 10))
+	values.Set("timeout", strconv.Itoa(timeout))
+	values.Set("allowed_updates", `["message"]`)
+
+	req, err := http.NewRequest(http.MethodGet, b.methodUrl("getUpdates")+"?"+values.Encode(), nil)
+	if err != nil {
+		return
+	}
+
+	err = b.do(ctx, req, &updates)
+
+	return
+
+}
+
+func (b *Bot) sendMessage(ctx context.Context, chatId, text string) error {
+
+	body, err := json.Marshal(sendMessageRequest{
+		ChatId: chatId,
+		Text:   text,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, b.methodUrl("sendMessage"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	return b.do(ctx, req, nil)
+
+}
+
+// Send request and decode result into v if it is not nil
+func (b *Bot) do(ctx context.Context, req *http.Request, v interface{}) error {
+
+	resp, err := b.client.Do(req.WithContext(ctx))
+	if err != nil {
+		// Request url contains the token
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("telegram: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var apiResp apiResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return fmt.Errorf("telegram: %s: %v", resp.Status, err)
+	}
+
+	if !apiResp.Ok {
+		return &ApiError{
+			Code:        apiResp.ErrorCode,
+			Description: apiResp.Description,
+		}
+	}
+
+	if v == nil {
+		return nil
+	}
+
+	return json.Unmarshal(apiResp.Result, v)
+
+}
+
+func (b *Bot) methodUrl(method string) string {
+	return b.baseUrl + "/bot" + b.token + "/" + method
+}
@@ -0,0 +1,162 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+	"github.com/tusupov/exmoarbitrage/alert"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+)
+
+var ErrTokenEmpty = errors.New("Не указан токен Telegram бота")
+
+// Max routes in a notification message
+const notifyRoutes = 10
+
+// Telegram bot: posts new profitable routes to the chat
+// and answers commands of the chat members
+type Bot struct {
+	baseUrl string
+	client  *http.Client
+	token   string
+	chatId  string // numeric id or @channel
+
+	service service.Servicer
+	alerter *alert.Alerter
+
+	pollTimeout int           // long polling timeout, seconds
+	retryDelay  time.Duration // delay after a failed request
+}
+
+func NewBot(baseUrl string, client *http.Client, token, chatId string, service service.Servicer) *Bot {
+	return &Bot{
+		baseUrl:     baseUrl,
+		client:      client,
+		token:       token,
+		chatId:      chatId,
+		service:     service,
+		alerter:     alert.NewAlerter(nil, nil, model.AlertRule{Name: "telegram"}),
+		pollTimeout: 30,
+		retryDelay:  5 * time.Second,
+	}
+}
+
+// Set min net profit of notified routes, percent
+func (b *Bot) SetMinProfit(minProfit float64) {
+	b.alerter = alert.NewAlerter(nil, nil, model.AlertRule{Name: "telegram", MinProfit: minProfit})
+}
+
+// Set long polling timeout of command updates, client timeout must be longer
+func (b *Bot) SetPollTimeout(timeout time.Duration) {
+	b.pollTimeout = int(timeout / time.Second)
+}
+
+// Notify about snapshots of notifier and answer commands until ctx is done
+func (b *Bot) Run(ctx context.Context, notifier service.Notifier) error {
+
+	if b.token == "" {
+		return ErrTokenEmpty
+	}
+
+	go b.runCommands(ctx)
+
+	updates, unsubscribe := notifier.Subscribe()
+	defer unsubscribe()
+
+	for {
+		select {
+
+		case <-ctx.Done():
+			return nil
+
+		case snapshot, ok := <-updates:
+			if !ok {
+				return nil
+			}
+
+			if err := b.notify(ctx, snapshot); err != nil && ctx.Err() == nil {
+				log.Printf("Telegram error: %v\n", err)
+			}
+
+		}
+	}
+
+}
+
+// Post routes of the snapshot not posted before
+func (b *Bot) notify(ctx context.Context, snapshot *model.Snapshot) error {
+
+	alerts := b.alerter.Evaluate(snapshot)
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	return b.sendMessage(ctx, b.chatId, formatAlerts(alerts, notifyRoutes))
+
+}
+
+func (b *Bot) runCommands(ctx context.Context) {
+
+	var offset int64
+	for ctx.Err() == nil {
+
+		next, err := b.poll(ctx, offset)
+		if err != nil {
+
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Telegram error: %v\n", err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(b.retryDelay):
+			}
+
+		}
+		offset = next
+
+	}
+
+}
+
+// Answer commands of one updates batch, returns offset of the next batch
+func (b *Bot) poll(ctx context.Context, offset int64) (int64, error) {
+
+	updates, err := b.getUpdates(ctx, offset, b.pollTimeout)
+	if err != nil {
+		return offset, err
+	}
+
+	for _, u := range updates {
+
+		offset = u.UpdateId + 1
+
+		// Only the configured chat is answered
+		if u.Message == nil || !b.isChat(u.Message.Chat) {
+			continue
+		}
+
+		reply, ok := b.command(ctx, u.Message.Text)
+		if !ok {
+			continue
+		}
+
+		if err := b.sendMessage(ctx, strconv.FormatInt(u.Message.Chat.Id, 10), reply); err != nil {
+			return offset, err
+		}
+
+	}
+
+	return offset, nil
+
+}
+
+func (b *Bot) isChat(c chat) bool {
+	return b.chatId == strconv.FormatInt(c.Id, 10) || (c.Username != "" && b.chatId == "@"+c.Username)
+}
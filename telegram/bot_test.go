@@ -0,0 +1,173 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	testifyMock "github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+	"github.com/tusupov/exmoarbitrage/service/mock"
+)
+
+const testToken = "123:secret"
+
+// Bot API stand-in, returns updates once and records sent messages
+type testBotApi struct {
+	mu       sync.Mutex
+	updates  []update
+	offsets  []string
+	messages []sendMessageRequest
+}
+
+func newTestBotApi(updates ...update) (*testBotApi, *httptest.Server) {
+
+	botApi := &testBotApi{updates: updates}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bot"+testToken+"/getUpdates", func(w http.ResponseWriter, r *http.Request) {
+		botApi.mu.Lock()
+		defer botApi.mu.Unlock()
+
+		botApi.offsets = append(botApi.offsets, r.URL.Query().Get("offset"))
+		writeResult(w, botApi.updates)
+		botApi.updates = nil
+	})
+	mux.HandleFunc("/bot"+testToken+"/sendMessage", func(w http.ResponseWriter, r *http.Request) {
+		botApi.mu.Lock()
+		defer botApi.mu.Unlock()
+
+		var req sendMessageRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		botApi.messages = append(botApi.messages, req)
+		writeResult(w, map[string]int{"message_id": len(botApi.messages)})
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"ok":false,"error_code":401,"description":"Unauthorized"}`))
+	})
+
+	return botApi, httptest.NewServer(mux)
+
+}
+
+func writeResult(w http.ResponseWriter, result interface{}) {
+	raw, _ := json.Marshal(result)
+	json.NewEncoder(w).Encode(apiResponse{Ok: true, Result: raw})
+}
+
+func testUpdate(id, chatId int64, text string) update {
+	return update{
+		UpdateId: id,
+		Message: &message{
+			MessageId: id,
+			Chat:      chat{Id: chatId},
+			Text:      text,
+		},
+	}
+}
+
+var testArbitrageList = []model.Arbitrage{
+	{Route: []model.Currency{"USD", "BTC", "EUR", "USD"}, Profit: 1.012, NetProfit: 1.006, Volume: 250},
+	{Route: []model.Currency{"USD", "ETH", "USD"}, Profit: 1.008, NetProfit: 1.002, Volume: 100},
+}
+
+func TestBot_poll(t *testing.T) {
+
+	botApi, server := newTestBotApi(
+		testUpdate(10, 42, "/top 2"),
+		testUpdate(11, 42, "hello"),
+		testUpdate(12, 7, "/top"),
+		testUpdate(13, 42, "/route@ArbBot usd"),
+		testUpdate(14, 42, "/pairs btc"),
+		testUpdate(15, 42, "/top x"),
+	)
+	defer server.Close()
+
+	serviceMock := mock.NewArbitrage()
+	serviceMock.On("GetArbitrage", testifyMock.Anything, service.ArbitrageQuery{Limit: 2}).Return(testArbitrageList, nil)
+	serviceMock.On("GetArbitrage", testifyMock.Anything, service.ArbitrageQuery{Base: "USD", Limit: defaultCount}).Return([]model.Arbitrage{}, nil)
+	serviceMock.On("GetPairList", testifyMock.Anything).Return(model.PairSettings{"BTC_USD": {}, "ETH_USD": {}, "ETH_BTC": {}}, nil)
+	serviceMock.On("GetOrders", testifyMock.Anything, []model.Pair{"BTC_USD", "ETH_BTC"}).Return(model.PairOrders{
+		"BTC_USD": model.Order{Bid: model.Offer{Price: 3800}, Ask: model.Offer{Price: 3810}},
+	}, nil)
+
+	bot := NewBot(server.URL, server.Client(), testToken, "42", serviceMock)
+
+	offset, err := bot.poll(context.Background(), 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(16), offset)
+
+	botApi.mu.Lock()
+	defer botApi.mu.Unlock()
+
+	assert.Equal(t, []string{"0"}, botApi.offsets)
+	if assert.Len(t, botApi.messages, 4) {
+		for _, m := range botApi.messages {
+			assert.Equal(t, "42", m.ChatId)
+		}
+		assert.Equal(t, "1. USD → BTC → EUR → USD\nПрофит 0.6000 % (без комиссии 1.2000 %), объем 250.00000000\n"+
+			"2. USD → ETH → USD\nПрофит 0.2000 % (без комиссии 0.8000 %), объем 100.00000000", botApi.messages[0].Text)
+		assert.Equal(t, "Прибыльных цепочек нет", botApi.messages[1].Text)
+		assert.Equal(t, "BTC_USD: покупка 3800, продажа 3810\nETH_BTC: нет ордеров", botApi.messages[2].Text)
+		assert.Contains(t, botApi.messages[3].Text, "Ошибка")
+	}
+
+}
+
+func TestBot_notify(t *testing.T) {
+
+	botApi, server := newTestBotApi()
+	defer server.Close()
+
+	bot := NewBot(server.URL, server.Client(), testToken, "@desk", mock.NewArbitrage())
+	bot.SetMinProfit(0.5)
+
+	ctx := context.Background()
+	snapshot := &model.Snapshot{Time: time.Now(), Arbitrage: testArbitrageList}
+
+	assert.Nil(t, bot.notify(ctx, snapshot))
+	assert.Nil(t, bot.notify(ctx, snapshot))
+
+	botApi.mu.Lock()
+	defer botApi.mu.Unlock()
+
+	// Posted once, only the route above min profit
+	if assert.Len(t, botApi.messages, 1) {
+		assert.Equal(t, "@desk", botApi.messages[0].ChatId)
+		assert.Contains(t, botApi.messages[0].Text, "USD → BTC → EUR → USD")
+		assert.NotContains(t, botApi.messages[0].Text, "ETH")
+	}
+
+}
+
+func TestBot_errors(t *testing.T) {
+
+	_, server := newTestBotApi()
+	defer server.Close()
+
+	bot := NewBot(server.URL, server.Client(), "wrong", "42", mock.NewArbitrage())
+	err := bot.sendMessage(context.Background(), "42", "test")
+	assert.Equal(t, &ApiError{Code: 401, Description: "Unauthorized"}, err)
+
+	bot = NewBot(server.URL, server.Client(), "", "42", mock.NewArbitrage())
+	assert.Equal(t, ErrTokenEmpty, bot.Run(context.Background(), nil))
+
+}
+
+func TestFormatAlerts(t *testing.T) {
+
+	alerts := make([]model.Alert, 3)
+	for i := range alerts {
+		alerts[i] = model.Alert{Route: []model.Currency{"USD", "BTC", "USD"}, Profit: 1.01, NetProfit: 1.005}
+	}
+
+	text := formatAlerts(alerts, 2)
+	assert.Contains(t, text, "и еще 1")
+
+}
@@ -0,0 +1,205 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"github.com/tusupov/exmoarbitrage/model"
+	"github.com/tusupov/exmoarbitrage/service"
+)
+
+// Routes count of /top and /route
+const (
+	defaultCount = 5
+	maxCount     = 20
+)
+
+const helpText = `/top [N] - N самых прибыльных цепочек
+/route USD [N] - цепочки через валюту
+/pairs BTC - лучшие цены пар валюты`
+
+// Reply to the message text, not ok if the text is not a command
+func (b *Bot) command(ctx context.Context, text string) (reply string, ok bool) {
+
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return "", false
+	}
+
+	// "/top@SomeBot" in group chats
+	name := strings.ToLower(strings.SplitN(fields[0], "@", 2)[0])
+	args := fields[1:]
+
+	var err error
+	switch name {
+	case "/start", "/help":
+		reply = helpText
+	case "/top":
+		reply, err = b.top(ctx, args)
+	case "/route":
+		reply, err = b.route(ctx, args)
+	case "/pairs":
+		reply, err = b.pairs(ctx, args)
+	default:
+		reply = "Неизвестная команда\n" + helpText
+	}
+
+	if err != nil {
+		reply = "Ошибка: " + err.Error()
+	}
+
+	return reply, true
+
+}
+
+func (b *Bot) top(ctx context.Context, args []string) (string, error) {
+
+	count, err := parseCount(args, 0)
+	if err != nil {
+		return "", err
+	}
+
+	list, err := b.service.GetArbitrage(ctx, service.ArbitrageQuery{Limit: count})
+	if err != nil {
+		return "", err
+	}
+
+	return formatArbitrage(list), nil
+
+}
+
+func (b *Bot) route(ctx context.Context, args []string) (string, error) {
+
+	if len(args) == 0 {
+		return "", fmt.Errorf("укажите валюту: /route USD")
+	}
+
+	count, err := parseCount(args, 1)
+	if err != nil {
+		return "", err
+	}
+
+	list, err := b.service.GetArbitrage(ctx, service.ArbitrageQuery{
+		Base:  model.Currency(strings.ToUpper(args[0])),
+		Limit: count,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return formatArbitrage(list), nil
+
+}
+
+func (b *Bot) pairs(ctx context.Context, args []string) (string, error) {
+
+	if len(args) == 0 {
+		return "", fmt.Errorf("укажите валюту: /pairs BTC")
+	}
+	currency := model.Currency(strings.ToUpper(args[0]))
+
+	pairList, err := b.service.GetPairList(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	pairs := make([]model.Pair, 0)
+	for pair := range pairList {
+		if base, quote, ok := pair.Split(); ok && (base == currency || quote == currency) {
+			pairs = append(pairs, pair)
+		}
+	}
+
+	if len(pairs) == 0 {
+		return fmt.Sprintf("Нет пар с %s", currency), nil
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i] < pairs[j]
+	})
+
+	pairOrders, err := b.service.GetOrders(ctx, pairs...)
+	if err != nil {
+		return "", err
+	}
+
+	lines := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		if order, ok := pairOrders.GetOrder(pair); ok {
+			lines = append(lines, fmt.Sprintf("%s: покупка %.8g, продажа %.8g", pair, order.Bid.Price, order.Ask.Price))
+		} else {
+			lines = append(lines, fmt.Sprintf("%s: нет ордеров", pair))
+		}
+	}
+
+	return strings.Join(lines, "\n"), nil
+
+}
+
+// Count argument at i, default if missing
+func parseCount(args []string, i int) (int, error) {
+
+	if len(args) <= i {
+		return defaultCount, nil
+	}
+
+	count, err := strconv.Atoi(args[i])
+	if err != nil || count <= 0 {
+		return 0, fmt.Errorf("количество должно быть положительным числом")
+	}
+
+	if count > maxCount {
+		count = maxCount
+	}
+
+	return count, nil
+
+}
+
+func formatArbitrage(list []model.Arbitrage) string {
+
+	if len(list) == 0 {
+		return "Прибыльных цепочек нет"
+	}
+
+	lines := make([]string, 0, len(list))
+	for i, arbitrage := range list {
+		lines = append(lines, fmt.Sprintf("%d. %s\n%s", i+1, formatRoute(arbitrage.Route), formatProfit(arbitrage.Profit, arbitrage.NetProfit, arbitrage.Volume)))
+	}
+
+	return strings.Join(lines, "\n")
+
+}
+
+// Notification of new routes, up to max of them
+func formatAlerts(alerts []model.Alert, max int) string {
+
+	lines := []string{"Новые прибыльные цепочки:"}
+	for i, alert := range alerts {
+		if i == max {
+			lines = append(lines, fmt.Sprintf("и еще %d", len(alerts)-max))
+			break
+		}
+		lines = append(lines, formatRoute(alert.Route)+"\n"+formatProfit(alert.Profit, alert.NetProfit, alert.Volume))
+	}
+
+	return strings.Join(lines, "\n")
+
+}
+
+func formatRoute(route []model.Currency) string {
+
+	list := make([]string, 0, len(route))
+	for _, currency := range route {
+		list = append(list, string(currency))
+	}
+
+	return strings.Join(list, " → ")
+
+}
+
+func formatProfit(profit, netProfit, volume float64) string {
+	return fmt.Sprintf("Профит %.4f %% (без комиссии %.4f %%), объем %.8f", (netProfit-1)*100, (profit-1)*100, volume)
+}